@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// accessStats counts the cold and warm accesses of a phase against an access
+// list kept for the duration of every batch, as the EIP-2929 gas schedule
+// would charge them if the batch was a single block.
+type accessStats struct {
+	ColdAccounts int64  `json:"coldAccounts"`
+	WarmAccounts int64  `json:"warmAccounts"`
+	ColdSlots    int64  `json:"coldSlots"`
+	WarmSlots    int64  `json:"warmSlots"`
+	Gas          uint64 `json:"gas"` // Access cost of the phase under EIP-2929
+}
+
+// account records an access of addr, adding it to the access list of statedb
+// if it was cold.
+func (s *accessStats) account(statedb *state.StateDB, addr common.Address) {
+	if statedb.AddressInAccessList(addr) {
+		s.WarmAccounts++
+		s.Gas += params.WarmStorageReadCostEIP2929
+		return
+	}
+	s.ColdAccounts++
+	s.Gas += params.ColdAccountAccessCostEIP2929
+	statedb.AddAddressToAccessList(addr)
+}
+
+// slot records an access of the slot key of addr, adding it to the access list
+// of statedb if it was cold.
+func (s *accessStats) slot(statedb *state.StateDB, addr common.Address, key common.Hash) {
+	if _, warm := statedb.SlotInAccessList(addr, key); warm {
+		s.WarmSlots++
+		s.Gas += params.WarmStorageReadCostEIP2929
+		return
+	}
+	s.ColdSlots++
+	s.Gas += params.ColdSloadCostEIP2929
+	statedb.AddSlotToAccessList(addr, key)
+}
+
+// accessWriter forwards the slot writes to the wrapped destination, recording
+// them in the access list of statedb on the way.
+type accessWriter struct {
+	storageWriter
+	statedb *state.StateDB
+	stats   *accessStats
+}
+
+// SetState implements storageWriter.
+func (w accessWriter) SetState(addr common.Address, key, value common.Hash) common.Hash {
+	w.stats.slot(w.statedb, addr, key)
+	return w.storageWriter.SetState(addr, key, value)
+}
+
+// touchAccount records an access of the account at addr, if the accesses of
+// the current phase are tracked. The access list lives in the StateDB, so it
+// starts out empty again after every commit.
+func (b *bench) touchAccount(addr common.Address) {
+	if b.access != nil {
+		b.access.account(b.statedb, addr)
+	}
+}
+
+// touchSlot records an access of the slot key of addr not going through the
+// storage writer, if the accesses of the current phase are tracked.
+func (b *bench) touchSlot(addr common.Address, key common.Hash) {
+	if b.access != nil {
+		b.access.slot(b.statedb, addr, key)
+	}
+}
+
+// printAccess writes the access counts of a phase into w, if tracked.
+func printAccess(w io.Writer, a *accessStats) {
+	if a == nil {
+		return
+	}
+	fmt.Fprintf(w, "Access List: %d cold / %d warm accounts, %d cold / %d warm slots | %d gas\n",
+		a.ColdAccounts, a.WarmAccounts, a.ColdSlots, a.WarmSlots, a.Gas)
+}
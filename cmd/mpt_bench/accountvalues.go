@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/holiman/uint256"
+)
+
+const (
+	balanceDistFixed  = "fixed"
+	balanceDistRandom = "random"
+)
+
+const (
+	nonceDistIndex  = "index"
+	nonceDistZero   = "zero"
+	nonceDistRandom = "random"
+)
+
+// fixedBalance is the balance of every account created in phase 1 by default,
+// borrowed from the C# version.
+const fixedBalance = 1e18
+
+// accountValues draws the balances and nonces of the accounts created in phase
+// 1 and counts the empty-ish ones among them. The values are drawn from their
+// own source, keeping the slots identical regardless of the distributions.
+type accountValues struct {
+	cfg   *config
+	r     *rand.Rand
+	stats *accountStats
+}
+
+// newAccountValues returns the drawer of the balances and nonces of cfg, nil
+// if every account gets the fixed balance and its index as nonce.
+func newAccountValues(cfg *config) *accountValues {
+	if cfg.balanceDist == balanceDistFixed && cfg.nonceDist == nonceDistIndex && cfg.zeroBalance == 0 {
+		return nil
+	}
+	return &accountValues{
+		cfg: cfg,
+		r:   cfg.rng.newRand("account", creationSeed+2),
+		stats: &accountStats{
+			Balance:     cfg.balanceDist,
+			Nonce:       cfg.nonceDist,
+			ZeroPercent: cfg.zeroBalance * 100,
+		},
+	}
+}
+
+// draw returns the balance and nonce of the i-th account, tallying them along
+// with whether the account is given code.
+func (v *accountValues) draw(i int, contract bool) (*uint256.Int, uint64) {
+	if v == nil {
+		return uint256.NewInt(fixedBalance), uint64(i)
+	}
+	var (
+		balance = uint256.NewInt(fixedBalance)
+		nonce   = uint64(i)
+	)
+	if v.cfg.balanceDist == balanceDistRandom {
+		// Spread the magnitudes from a single wei to ~1e28, varying the
+		// encoded length of the balances instead of drawing 32 byte ones
+		buf := make([]byte, 1+v.r.Intn(12))
+		v.r.Read(buf)
+		balance.SetBytes(buf)
+	}
+	if v.cfg.zeroBalance > 0 && v.r.Float64() < v.cfg.zeroBalance {
+		balance.Clear()
+	}
+	switch v.cfg.nonceDist {
+	case nonceDistZero:
+		nonce = 0
+	case nonceDistRandom:
+		nonce = uint64(v.r.Int63n(1 << (1 + v.r.Intn(24))))
+	}
+	if v.stats.add(balance, nonce, contract) {
+		v.stats.empties = append(v.stats.empties, i)
+	}
+	return balance, nonce
+}
+
+// summary returns the tally of the drawn values, nil if not drawn.
+func (v *accountValues) summary() *accountStats {
+	if v == nil {
+		return nil
+	}
+	return v.stats
+}
+
+// accountStats counts the empty-ish accounts created in phase 1 under the
+// configured balance and nonce distributions. An account without balance,
+// nonce and code is empty by EIP-161, whatever its storage.
+type accountStats struct {
+	Balance     string  `json:"balanceDist"`
+	Nonce       string  `json:"nonceDist"`
+	ZeroPercent float64 `json:"zeroBalancePercent,omitempty"`
+	ZeroBalance int     `json:"zeroBalance"` // Accounts created without balance
+	ZeroNonce   int     `json:"zeroNonce"`
+	Empty       int     `json:"empty"`  // Accounts created empty by EIP-161
+	Pruned      int     `json:"pruned"` // Empty accounts missing from the state after phase 1
+	Checked     bool    `json:"checked"`
+
+	empties []int // Indexes of the empty accounts
+}
+
+// add tallies the values of an account, returning whether it is empty.
+func (s *accountStats) add(balance *uint256.Int, nonce uint64, contract bool) bool {
+	if balance.IsZero() {
+		s.ZeroBalance++
+	}
+	if nonce == 0 {
+		s.ZeroNonce++
+	}
+	if balance.IsZero() && nonce == 0 && !contract {
+		s.Empty++
+		return true
+	}
+	return false
+}
+
+// checkEmpty counts the empty accounts of phase 1 the commits pruned from the
+// state at the current root. The commits keep the empty objects, so they are
+// all expected to be found.
+func (b *bench) checkEmpty(s *accountStats) error {
+	if s == nil || len(s.empties) == 0 {
+		return nil
+	}
+	statedb, err := state.New(b.root, b.sdb)
+	if err != nil {
+		return fmt.Errorf("failed to open state: %v", err)
+	}
+	for _, i := range s.empties {
+		if !statedb.Exist(b.addrs[i]) {
+			s.Pruned++
+		}
+	}
+	if err := statedb.Error(); err != nil {
+		return fmt.Errorf("failed to read state: %v", err)
+	}
+	s.Checked = true
+	return nil
+}
+
+// printAccountValues writes the balance and nonce distributions of phase 1 and
+// the empty-ish accounts they produced into w, if configured.
+func printAccountValues(w io.Writer, s *accountStats) {
+	if s == nil {
+		return
+	}
+	balance := s.Balance
+	if s.ZeroPercent > 0 {
+		balance = fmt.Sprintf("%s, %v%% zeroed", balance, s.ZeroPercent)
+	}
+	fmt.Fprintf(w, "Account Values: balance %s, nonce %s | %d zero balances, %d zero nonces, %d empty accounts",
+		balance, s.Nonce, s.ZeroBalance, s.ZeroNonce, s.Empty)
+	if s.Checked {
+		fmt.Fprintf(w, " (%d pruned on commit)", s.Pruned)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// logicalAccountBytes is the logical size of an account: its address,
+	// nonce, balance, storage root and code hash.
+	logicalAccountBytes = common.AddressLength + 8 + 32 + 2*common.HashLength
+
+	// logicalSlotBytes is the logical size of a storage slot, its key and value.
+	logicalSlotBytes = 2 * common.HashLength
+)
+
+// amplification relates the bytes written to disk in a phase to the logical
+// size of the state changes made.
+type amplification struct {
+	LogicalBytes int64   `json:"logicalBytes"`
+	WrittenBytes int64   `json:"writtenBytes"` // Bytes physically written by Pebble: WAL, flushes and compactions
+	DiskGrowth   int64   `json:"diskGrowthBytes"`
+	Write        float64 `json:"writeAmplification"` // Written bytes over the logical bytes
+	Space        float64 `json:"spaceAmplification"` // Disk growth over the logical bytes
+}
+
+// logicalBytes estimates the logical size of the state changes made to the
+// given number of accounts and slots, plus the code deployed.
+func logicalBytes(accounts int, slots int64, code int64) int64 {
+	return int64(accounts)*logicalAccountBytes + slots*logicalSlotBytes + code
+}
+
+// trackWrites starts measuring the bytes written to disk, returning a function
+// that computes the amplification of the logical bytes written since. Nothing
+// is written in dry-run mode, nil is reported there.
+func (b *bench) trackWrites() func(logical int64) *amplification {
+	if b.cfg.dryRun {
+		return func(int64) *amplification { return nil }
+	}
+	var (
+		written = b.kvdb.WriteStats().Total()
+		disk    = getDirSize(b.cfg.dbPath)
+	)
+	return func(logical int64) *amplification {
+		a := &amplification{
+			LogicalBytes: logical,
+			WrittenBytes: int64(b.kvdb.WriteStats().Total() - written),
+			DiskGrowth:   getDirSize(b.cfg.dbPath) - disk,
+		}
+		if logical > 0 {
+			a.Write = float64(a.WrittenBytes) / float64(logical)
+			a.Space = float64(a.DiskGrowth) / float64(logical)
+		}
+		return a
+	}
+}
+
+// printAmplification writes the amplification of a phase into w, if it was
+// measured.
+func printAmplification(w io.Writer, a *amplification) {
+	if a == nil {
+		return
+	}
+	fmt.Fprintf(w, "Amplification: %.2f MB logical | %.2f MB written (%.2fx) | %.2f MB disk growth (%.2fx)\n",
+		float64(a.LogicalBytes)/1024/1024, float64(a.WrittenBytes)/1024/1024, a.Write,
+		float64(a.DiskGrowth)/1024/1024, a.Space)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// batchReadStats compares reading the slots of an account in batches through
+// StateDB.GetStorageBatch against reading the same kind of batches with one
+// GetState call per slot.
+type batchReadStats struct {
+	Size    int           `json:"batchSize"`
+	Reads   int           `json:"reads"` // Slots read by each of the passes
+	Single  batchReadPass `json:"single"`
+	Batched batchReadPass `json:"batched"`
+}
+
+// batchReadPass contains the measurements of one pass over the batches.
+type batchReadPass struct {
+	Found      int           `json:"found"` // Reads that returned a non-empty value
+	Elapsed    time.Duration `json:"elapsedNs"`
+	Throughput float64       `json:"readsPerSecond"`
+	Latency    latencyStats  `json:"batchLatency"`
+}
+
+// compareBatchReads reads n random slots in batches of size slots of one
+// account each, once with a GetState call per slot and once with a single
+// GetStorageBatch call per batch. Every pass opens the state fresh at the
+// latest root and draws its slots from its own seed, so that neither reads
+// the slots the other one cached.
+func (b *bench) compareBatchReads(n, size int) (*batchReadStats, error) {
+	res := &batchReadStats{Size: size, Reads: n}
+	for _, pass := range []struct {
+		name    string
+		seed    int64
+		batched bool
+		res     *batchReadPass
+	}{
+		{"single", 44, false, &res.Single},
+		{"batched", 45, true, &res.Batched},
+	} {
+		if err := b.timeBatchReads(pass.name, pass.seed, n, size, pass.batched, pass.res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// timeBatchReads performs one pass of compareBatchReads into res.
+func (b *bench) timeBatchReads(name string, seed int64, n, size int, batched bool, res *batchReadPass) error {
+	candidates := b.readCandidates()
+	if len(candidates) == 0 {
+		return fmt.Errorf("no slots available to read")
+	}
+	statedb, err := state.New(b.root, b.sdb)
+	if err != nil {
+		return fmt.Errorf("failed to open state: %v", err)
+	}
+	var (
+		r         = b.cfg.rng.newRand("batch-read-"+name, seed)
+		keys      = make([]common.Hash, 0, size)
+		latencies = make([]time.Duration, 0, (n+size-1)/size)
+		start     = time.Now()
+	)
+	for batch, read := 1, 0; read < n; batch, read = batch+1, read+size {
+		var (
+			accountIdx = candidates[r.Intn(len(candidates))]
+			addr       = b.addrs[accountIdx]
+		)
+		keys = keys[:0]
+		for i := 0; i < min(size, n-read); i++ {
+			keys = append(keys, slotKey(accountIdx, r.Intn(b.slotCounts[accountIdx])))
+		}
+		batchStart := time.Now()
+		if batched {
+			for _, val := range statedb.GetStorageBatch(addr, keys) {
+				if val != (common.Hash{}) {
+					res.Found++
+				}
+			}
+		} else {
+			for _, key := range keys {
+				if statedb.GetState(addr, key) != (common.Hash{}) {
+					res.Found++
+				}
+			}
+		}
+		latencies = append(latencies, time.Since(batchStart))
+
+		if done := read + len(keys); batch%100 == 0 || done == n {
+			b.progress.update("batch reads", 0, int64(done), "...read %d/%d slots in %s batches (%.1f%%)", done, n, name, float64(done)/float64(n)*100)
+		}
+	}
+	res.Elapsed = time.Since(start)
+	if err := statedb.Error(); err != nil {
+		return fmt.Errorf("failed to read state: %v", err)
+	}
+	if secs := res.Elapsed.Seconds(); secs > 0 {
+		res.Throughput = float64(n) / secs
+	}
+	res.Latency = summarize(latencies)
+	return nil
+}
+
+// printBatchReads writes the comparison of the batched and the single reads
+// into w, if measured.
+func printBatchReads(w io.Writer, r *batchReadStats) {
+	if r == nil {
+		return
+	}
+	fmt.Fprintf(w, "Batch Reads:   %d slots in batches of %d, batched %.2f reads/s vs single %.2f reads/s (%.2fx)\n",
+		r.Reads, r.Size, r.Batched.Throughput, r.Single.Throughput, r.Batched.Throughput/r.Single.Throughput)
+	fmt.Fprintf(w, "               batched latency: %v\n", r.Batched.Latency)
+	fmt.Fprintf(w, "               single latency:  %v\n", r.Single.Latency)
+}
@@ -0,0 +1,1226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	ethpebble "github.com/ethereum/go-ethereum/ethdb/pebble"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/hashdb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// errInterrupted is returned by the phases stopped early by a cancelled context.
+var errInterrupted = errors.New("interrupted")
+
+// run executes the benchmark described by cfg, printing the human-readable
+// progress into out and returning the collected measurements. If ctx is
+// cancelled, the measurements of the work completed so far are returned,
+// flagged as interrupted.
+func run(ctx context.Context, cfg *config, out io.Writer) (*result, error) {
+	start := time.Now()
+
+	if cfg.clear {
+		fmt.Fprintf(out, "Cleaning up old database at %s...\n", cfg.dbPath)
+		os.RemoveAll(cfg.dbPath)
+	}
+
+	if cfg.noWAL {
+		fmt.Fprintln(out, "WARNING: Pebble write-ahead log disabled, the database is NOT crash-safe and must be discarded after the run")
+	}
+	if cfg.shards > 1 {
+		return runShards(ctx, cfg, out, start)
+	}
+	if cfg.instances > 1 {
+		return runInstances(ctx, cfg, out, start)
+	}
+
+	// 1. Initialize Pebble
+	fmt.Fprintf(out, "Initializing Pebble at %s (Cache: %d MB, Compression: %s)...\n", cfg.dbPath, cfg.cacheMB, cfg.compression)
+	// 2. Initialize TrieDB and StateDB
+	if cfg.scheme == rawdb.HashScheme {
+		fmt.Fprintln(out, "Initializing TrieDB with HashDB (Pruning: Off)...")
+	} else {
+		pruning := "On"
+		if cfg.archive {
+			pruning = "Off, archive"
+		}
+		fmt.Fprintf(out, "Initializing TrieDB with PathDB (Pruning: %s, History: %s, Dirty Cache: %d MB, Clean Cache: %d MB)...\n", pruning, historyString(cfg.history), cfg.dirtyCacheMB, cfg.cleanCacheMB)
+	}
+	flushes, layers := pathdb.ReadNodeStats().Flushes, trackLayers()
+	historyWrites := pathdb.ReadHistoryWriteStats()
+	stores, err := openStores(cfg, cfg.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.dryRun {
+		fmt.Fprintln(out, "Dry run: roots are computed in memory, nothing is committed to disk")
+	}
+
+	root := types.EmptyRootHash
+	if cfg.verkle {
+		root = types.EmptyVerkleHash
+	}
+	if cfg.resumeRoot != nil {
+		root = *cfg.resumeRoot
+	}
+	tree, err := treeType(stores.sdb, root)
+	if err != nil {
+		stores.diskdb.Close()
+		return nil, err
+	}
+	if cfg.verkle && tree == treeMPT {
+		stores.diskdb.Close()
+		return nil, fmt.Errorf("verkle state is not supported by this build, the trie database still opens merkle tries")
+	}
+	statedb, err := openState(stores.sdb, root, cfg.resumeRoot != nil)
+	if err != nil {
+		stores.diskdb.Close()
+		return nil, err
+	}
+
+	b := &bench{
+		cfg:      cfg,
+		out:      out,
+		progress: newProgress(cfg, out),
+		stores:   *stores,
+		statedb:  statedb,
+	}
+	if cfg.flatStorage {
+		fmt.Fprintln(out, "Flat storage: slots are written as plain key-values, only the account trie is built")
+		b.flat = newFlatStorage(b.diskdb)
+	}
+	// The databases may be swapped out between the phases, close the last ones
+	defer func() { b.diskdb.Close() }()
+	if cfg.resumeRoot != nil {
+		// Phase 1 is skipped, number the blocks as if it ran
+		b.root, b.next = root, uint64((cfg.accounts+cfg.batch-1)/cfg.batch)
+	}
+	if cfg.csvPath != "" {
+		b.csv, err = newCSVWriter(cfg.csvPath, cfg.dryRun, cfg.sysstat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CSV file: %v", err)
+		}
+		defer b.csv.Close()
+	}
+	res := &result{
+		Started:      start,
+		DBPath:       cfg.dbPath,
+		Scheme:       b.trieDB.Scheme(),
+		Tree:         tree,
+		Params:       cfg.params(),
+		DryRun:       cfg.dryRun,
+		CacheMB:      cfg.cacheMB,
+		Compression:  cfg.compression,
+		BatchMB:      cfg.batchMB,
+		NoWAL:        cfg.noWAL,
+		SyncCommit:   cfg.syncCommit,
+		CommitLimit:  cfg.commitWorkers,
+		FlatStorage:  cfg.flatStorage,
+		NoHashCache:  cfg.noHashCache,
+		InsertOrder:  cfg.insertOrder,
+		KeyScheme:    cfg.keyScheme,
+		AddrMode:     cfg.addrMode,
+		SlotDist:     cfg.slotDist,
+		SlotCap:      cfg.totalSlots,
+		BatchSlots:   cfg.batchSlots,
+		Prealloc:     cfg.prealloc,
+		Distribution: cfg.dist,
+		ModSeed:      cfg.modSeed,
+		ModDelete:    cfg.modDelete,
+		ModMode:      cfg.modMode,
+		MaxHeapMB:    cfg.maxHeapMB,
+		Creation:     &phaseResult{Name: "creation", Accounts: cfg.accounts},
+		Modification: &phaseResult{Name: "modification"},
+	}
+
+	preimages := b.trackPreimages()
+	err = b.runPhases(ctx, res)
+	if b.snaps != nil {
+		defer b.snaps.Release()
+	}
+	if err := finishPhases(out, res, err); err != nil {
+		return nil, err
+	}
+	res.Root = b.root
+	res.Dereferenced = b.derefs
+	res.HeapGuard = b.heap.triggers
+	res.Failures = b.failures
+	if res.Preimages = preimages(); res.Preimages != nil && !cfg.dryRun {
+		b.trieDB.WritePreimages() // Left out of the disk usage otherwise
+	}
+	// Iterating the whole account trie is costly, only done when asked for
+	if cfg.depthReport {
+		depths, err := leafDepths(b.trieDB, b.root)
+		if err != nil {
+			return nil, err
+		}
+		res.LeafDepth, res.Depths = depths.Avg, &depthReport{Accounts: depths}
+		if cfg.depthTries {
+			fmt.Fprintf(out, "\nIterating the storage tries for their leaf depths...\n")
+			if err := b.storageDepths(res.Depths); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Only the storage tries on disk can be iterated for their nodes
+	if res.Largest = largestAccount(b.addrs, b.slotCounts); res.Largest != nil && !cfg.dryRun && !cfg.flatStorage && !cfg.verkle {
+		res.Largest.Nodes, err = b.countStorageNodes(b.root, map[common.Address]struct{}{res.Largest.Address: {}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if b.trieDB.Scheme() == rawdb.PathScheme && !cfg.dryRun {
+		res.History = cfg.history
+		res.DirtyCacheMB = cfg.dirtyCacheMB
+		res.CleanCacheMB = cfg.cleanCacheMB
+		res.HistoryEntries, err = b.trieDB.HistoryCount()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count state histories: %v", err)
+		}
+		if res.HistoryBytes, err = b.trieDB.HistorySize(); err != nil {
+			return nil, fmt.Errorf("failed to measure state histories: %v", err)
+		}
+		res.Archive = cfg.archive
+		if cfg.freezer {
+			if res.Freezer, err = b.freezerStats(historyWrites, res.HistoryEntries); err != nil {
+				return nil, err
+			}
+		}
+		// Persist the buffered layers, otherwise the final state is lost on
+		// close and can't be resumed from.
+		if res.Journal, err = b.journal(); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "Journaled %.2f MB in %v\n", float64(res.Journal.Bytes)/1024/1024, res.Journal.Elapsed)
+		res.CapLayers = cfg.capLayers
+		res.BufferFlushes = pathdb.ReadNodeStats().Flushes - flushes
+		layers(res)
+	}
+	if !cfg.dryRun {
+		res.DiskSize = getDirSize(cfg.dbPath)
+		switch {
+		case cfg.compactTrie:
+			fmt.Fprintf(out, "Compacting the database (%.2f MB) between two full trie checks...\n", float64(res.DiskSize)/1024/1024)
+			if res.Compaction, err = b.compactVerified(res.DiskSize); err != nil {
+				return nil, err
+			}
+			res.DiskSize = getDirSize(cfg.dbPath)
+		case cfg.compact:
+			fmt.Fprintf(out, "Compacting the database (%.2f MB)...\n", float64(res.DiskSize)/1024/1024)
+			if res.Compaction, err = b.compact(res.DiskSize); err != nil {
+				return nil, err
+			}
+			res.DiskSize = getDirSize(cfg.dbPath)
+		}
+		res.LSM = inspectLSM(b.kvdb)
+		if cfg.codeSize > 0 || cfg.preimages || cfg.inspect {
+			res.Breakdown = inspectUsage(b.diskdb)
+		}
+		if cfg.checksum {
+			res.Checksum = checksumState(b.diskdb)
+		}
+	}
+	res.Elapsed = time.Since(start)
+	return res, nil
+}
+
+// runPhases executes the benchmark phases in order, collecting the results in
+// res. If ctx is cancelled, the running phase stops at its next batch boundary
+// after committing the pending changes, and errInterrupted is returned.
+func (b *bench) runPhases(ctx context.Context, res *result) error {
+	var (
+		cfg = b.cfg
+		out = b.out
+		err error
+	)
+	// Warm up the caches, excluded from the measurements below
+	if cfg.warmup > 0 {
+		fmt.Fprintf(out, "Warming up with %d accounts...\n", cfg.warmup)
+		res.Warmup = &phaseResult{Name: "warmup", Accounts: cfg.warmup}
+		if err := b.warmup(ctx, res.Warmup, cfg.warmup); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "\nWarm-up finished in %v (excluded from throughput)\n", res.Warmup.Elapsed)
+	}
+
+	if cfg.replayPath != "" {
+		return b.replayPhase(ctx, res)
+	}
+	// 3. Phase 1: Creation
+	if cfg.resumeRoot == nil {
+		switch {
+		case cfg.duration > 0:
+			fmt.Fprintf(out, "Phase 1: Creating accounts for %v with variable slots (avg %d, k=%d, workers=%d)...\n", cfg.duration, cfg.slots, cfg.batch, cfg.workers)
+		case cfg.targetSize > 0:
+			fmt.Fprintf(out, "Phase 1: Creating accounts until the database reaches %v GB with variable slots (avg %d, k=%d, workers=%d)...\n", cfg.targetSize, cfg.slots, cfg.batch, cfg.workers)
+			res.Target = newSizeTarget(cfg.targetSize)
+			b.target = res.Target
+		default:
+			fmt.Fprintf(out, "Phase 1: Creating %d accounts with variable slots (avg %d, k=%d, workers=%d)...\n", cfg.accounts, cfg.slots, cfg.batch, cfg.workers)
+		}
+		nodes, writes, allocs := b.trackNodes(), b.trackWrites(), trackAllocs()
+		if cfg.readers > 0 {
+			b.load = startReadLoad(cfg, b.sdb, cfg.readers)
+		}
+		if cfg.trackAccess {
+			res.Creation.Access = new(accessStats)
+			b.access = res.Creation.Access
+		}
+		if cfg.growth {
+			res.Creation.Growth = newGrowthCurve()
+		}
+		err := b.createAccounts(ctx, res.Creation)
+		b.access = nil
+		if b.load != nil {
+			res.ReadLoad, b.load = b.load.stop(res.Creation), nil
+		}
+		if err != nil {
+			return err
+		}
+		res.Creation.Allocs = allocs(res.Creation.Slots)
+		res.Creation.Nodes = nodes()
+		if cfg.verkle {
+			res.Creation.Commitment = commitments(res.Creation.Nodes)
+		}
+		res.Creation.Writes = writes(logicalBytes(res.Creation.Accounts, res.Creation.Slots, int64(res.Creation.Contracts*cfg.codeSize)))
+		if !cfg.dryRun {
+			if err := b.checkEmpty(res.Creation.AcctValues); err != nil {
+				return err
+			}
+		}
+
+		// The remaining phases operate on the accounts actually created
+		cfg.accounts = res.Creation.Accounts
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Creation finished in %v. Final Root: %x\n", res.Creation.Elapsed, b.root)
+		b.milestone(res.Creation)
+		fmt.Fprintf(out, "Total Slots Created: %d | Throughput: %.2f slots/s\n", res.Creation.Slots, res.Creation.Throughput)
+		printSlotCap(out, cfg.totalSlots, res.Creation.Slots)
+		if cfg.codeSize > 0 {
+			fmt.Fprintf(out, "Contracts Created: %d/%d | Code Size: %d bytes each\n", res.Creation.Contracts, res.Creation.Accounts, cfg.codeSize)
+		}
+		printAccountValues(out, res.Creation.AcctValues)
+		if cfg.duration > 0 || cfg.targetSize > 0 || cfg.slots == 0 {
+			fmt.Fprintf(out, "Accounts Created: %d | Throughput: %.2f accounts/s\n", res.Creation.Accounts, float64(res.Creation.Accounts)/res.Creation.Elapsed.Seconds())
+		}
+		if cfg.duration > 0 && !cfg.dryRun || cfg.targetSize > 0 {
+			printDiskGrowth(out, res.Creation)
+		}
+		printTarget(out, res.Target)
+		fmt.Fprintf(out, "Commit Latency: %v\n", res.Creation.Latency)
+		if l := res.ReadLoad; l != nil {
+			fmt.Fprintf(out, "Concurrent Reads: %d readers | %.2f lookups/s over %v | %d stale retries\n", l.Readers, l.Throughput, l.Active.Round(time.Millisecond), l.Stale)
+			fmt.Fprintf(out, "Write Throughput: %.2f slots/s with readers | %.2f slots/s without\n", l.WriteLoaded, l.WriteBaseline)
+		}
+		printHashing(out, res.Creation)
+		printBreakdown(out, res.Creation)
+		printSys(out, res.Creation)
+		printOpens(out, res.Creation)
+		printNodes(out, res.Creation.Nodes)
+		printAmplification(out, res.Creation.Writes)
+		printAccess(out, res.Creation.Access)
+		if err := checkRoot("creation", cfg.expectRoot, b.root); err != nil {
+			return err
+		}
+		if cfg.verifyTrie {
+			fmt.Fprintf(out, "Verifying trie at root %x...\n", b.root)
+			if res.Creation.Verify, err = b.verifyTrie(); err != nil {
+				return err
+			}
+			fmt.Fprintln(out)
+			printVerify(out, res.Creation.Verify)
+		}
+	} else {
+		fmt.Fprintf(out, "Phase 1: Skipped, resuming from root %x\n", b.root)
+		res.Creation = nil
+		res.Resumed = true
+		b.replayCreation()
+	}
+	res.SlotsPerAcct = summarizeCounts(b.slotCounts)
+	if res.Creation != nil {
+		if res.AccountRLP, err = b.sampleAccountSizes(); err != nil {
+			return err
+		}
+	}
+	if cfg.dumpPath != "" {
+		if res.Dump, err = b.dumpKeys(cfg.dumpPath, cfg.dumpSample, cfg.dumpSlots); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Dumped %d addresses and %d slot keys to %s\n", res.Dump.Accounts, res.Dump.Slots, res.Dump.Path)
+	}
+	if cfg.zeroNoop > 0 && len(b.addrs) > 0 {
+		fmt.Fprintf(out, "Zeroing %d absent slots at root %x...\n", cfg.zeroNoop, b.root)
+		if res.ZeroNoop, err = b.assertZeroNoop(cfg.zeroNoop); err != nil {
+			return fmt.Errorf("zero write check failed: %v", err)
+		}
+		fmt.Fprintf(out, "Zero No-Op: root and %d storage nodes of %d accounts unchanged | %v\n", res.ZeroNoop.Nodes, res.ZeroNoop.Accounts, res.ZeroNoop.Elapsed)
+	}
+
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if cfg.reopen {
+		fmt.Fprintf(out, "\nReopening the databases at root %x...\n", b.root)
+		if res.ReopenJournal, err = b.reopen(); err != nil {
+			return err
+		}
+		res.ColdCaches = true
+		if j := res.ReopenJournal; j != nil {
+			fmt.Fprintf(out, "Journaled %.2f MB in %v, replayed in %v until the first read\n", float64(j.Bytes)/1024/1024, j.Elapsed, j.Replay)
+		}
+	}
+	if cfg.snapshot {
+		fmt.Fprintf(out, "\nGenerating snapshot of root %x...\n", b.root)
+		res.Snapshot, err = b.generateSnapshot()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Snapshot generated in %v. Accounts: %.2f MB | Storage: %.2f MB | Disk Growth: %.2f MB\n",
+			res.Snapshot.Elapsed, float64(res.Snapshot.AccountBytes)/1024/1024, float64(res.Snapshot.StorageBytes)/1024/1024, float64(res.Snapshot.DiskGrowth)/1024/1024)
+	}
+
+	// 4. Phase 2: Modification
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	switch {
+	case cfg.slots == 0 && cfg.modMode != modModeBalance:
+		fmt.Fprintln(out, "\nPhase 2: Skipped, the accounts have no slots to modify")
+	case cfg.modify > 0 || cfg.blocks > 0:
+		if err := b.modificationPhase(ctx, res); err != nil {
+			return err
+		}
+	default:
+		fmt.Fprintln(out, "\nPhase 2: Skipped, no accounts to modify")
+	}
+	if err := checkRoot("modification", cfg.expectModRoot, b.root); err != nil {
+		return err
+	}
+	if cfg.verifyTrie {
+		fmt.Fprintf(out, "Verifying trie at root %x...\n", b.root)
+		if res.Modification.Verify, err = b.verifyTrie(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		printVerify(out, res.Modification.Verify)
+	}
+	if cfg.rehash {
+		fmt.Fprintf(out, "Rebuilding the full trie at root %x from scratch...\n", b.root)
+		if res.Modification.Rehash, err = b.rehashTrie(); err != nil {
+			return fmt.Errorf("full rehash check failed: %v", err)
+		}
+		fmt.Fprintln(out)
+		printRehash(out, res.Modification.Rehash)
+	}
+	// Chain reorgs on top of the modified state
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if cfg.reorgs > 0 {
+		fmt.Fprintf(out, "\nApplying %d reorgs of depth %d (k=%d)...\n", cfg.reorgs, cfg.reorgDepth, cfg.batch)
+		res.Reorg = &phaseResult{Name: "reorg", Accounts: cfg.reorgs * cfg.reorgDepth * cfg.batch}
+		res.Reorgs = &reorgStats{Depth: cfg.reorgDepth}
+		nodes, allocs := b.trackNodes(), trackAllocs()
+		if err := b.applyReorgs(ctx, res.Reorg, res.Reorgs, cfg.reorgs, cfg.reorgDepth); err != nil {
+			return err
+		}
+		res.Reorg.Allocs = allocs(res.Reorg.Slots)
+		res.Reorg.Nodes = nodes()
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Reorgs finished in %v. Final Root: %x\n", res.Reorgs.Total, b.root)
+		b.milestone(res.Reorg)
+		printReorgs(out, res.Reorgs)
+		fmt.Fprintf(out, "Commit Latency: %v\n", res.Reorg.Latency)
+		printBreakdown(out, res.Reorg)
+		printSys(out, res.Reorg)
+		printOpens(out, res.Reorg)
+		printNodes(out, res.Reorg.Nodes)
+	}
+
+	// 5. Phase 3: Random reads
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if cfg.reads > 0 {
+		fmt.Fprintf(out, "\nPhase 3: Randomly reading %d slots...\n", cfg.reads)
+		if cfg.prewarm {
+			fmt.Fprintf(out, "Reading on cold caches, then prewarming them...\n")
+			if res.Prewarm, err = b.prewarm(cfg.reads); err != nil {
+				return err
+			}
+		}
+		nodes, bloom := b.trackNodes(), b.trackBloom()
+		res.Reads, err = b.readSlots(cfg.reads)
+		if err != nil {
+			return err
+		}
+		res.Reads.Nodes = nodes()
+		res.Reads.Bloom = bloom()
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Reads finished in %v. Found: %d/%d\n", res.Reads.Elapsed, res.Reads.Found, res.Reads.Reads)
+		fmt.Fprintf(out, "Throughput: %.2f reads/s | P95 Latency: %v\n", res.Reads.Throughput, res.Reads.P95)
+		fmt.Fprintf(out, "Reader Cache: account %d hit / %d miss, storage %d hit / %d miss\n",
+			res.Reads.AccountCacheHit, res.Reads.AccountCacheMiss, res.Reads.StorageCacheHit, res.Reads.StorageCacheMiss)
+		printNodes(out, res.Reads.Nodes)
+		printBloom(out, res.Reads.Bloom)
+		printPrewarm(out, res.Prewarm, res.Reads)
+	}
+	if cfg.batchReads > 0 {
+		fmt.Fprintf(out, "\nReading %d slots in batches of %d, single and batched...\n", cfg.reads, cfg.batchReads)
+		if res.BatchReads, err = b.compareBatchReads(cfg.reads, cfg.batchReads); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		printBatchReads(out, res.BatchReads)
+	}
+	if cfg.historical > 0 {
+		fmt.Fprintf(out, "\nReading %d slots at the root %d commits back...\n", cfg.reads, cfg.historical)
+		if res.Historical, err = b.readHistorical(cfg.historical, cfg.reads); err != nil {
+			return err
+		}
+		printHistorical(out, res.Historical, res.Reads)
+	}
+	// Proof generation and verification
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if cfg.proofs > 0 {
+		kind := "inclusion"
+		if cfg.proofMissing {
+			kind = "exclusion"
+		}
+		fmt.Fprintf(out, "\nGenerating %d %s proofs (verify=%v)...\n", cfg.proofs, kind, cfg.verify)
+		res.Proofs, err = b.generateProofs(cfg.proofs, cfg.proofMissing, cfg.verify)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Proofs finished in %v. Throughput: %.2f proofs/s\n", res.Proofs.Elapsed, res.Proofs.Throughput)
+		fmt.Fprintf(out, "Account Proofs: avg %.1f nodes, %.0f bytes | Storage Proofs: avg %.1f nodes, %.0f bytes\n",
+			res.Proofs.AccountNodes, res.Proofs.AccountBytes, res.Proofs.StorageNodes, res.Proofs.StorageBytes)
+		if cfg.verify {
+			fmt.Fprintf(out, "Verification finished in %v. Throughput: %.2f proofs/s\n", res.Proofs.VerifyElapsed, res.Proofs.VerifyThroughput)
+		}
+	}
+	// Full state iteration
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if cfg.iterate {
+		fmt.Fprintf(out, "\nIterating the state at root %x...\n", b.root)
+		if res.Iterate, err = b.iterateState(cfg.iterateFrom); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		printIterate(out, res.Iterate)
+	}
+	// Storage destruction of accounts kept alive
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if cfg.destroy > 0 {
+		mDestroy := min(cfg.destroy, cfg.accounts)
+		fmt.Fprintf(out, "\nDestroying the storage of %d accounts (mode=%s, k=%d)...\n", mDestroy, cfg.destroyMode, cfg.batch)
+
+		res.Destruction = &phaseResult{Name: "destruction", Accounts: mDestroy}
+		res.Destroy = &destroyStats{Mode: cfg.destroyMode}
+		storeBefore, historyBefore := b.storeSizes()
+		nodes, allocs := b.trackNodes(), trackAllocs()
+		addrs, err := b.destroyStorage(ctx, res.Destruction, mDestroy)
+		if err != nil {
+			return err
+		}
+		res.Destruction.Allocs = allocs(res.Destruction.Slots)
+		res.Destruction.Nodes = nodes()
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Destruction finished in %v. Final Root: %x\n", res.Destruction.Elapsed, b.root)
+		b.milestone(res.Destruction)
+		fmt.Fprintf(out, "Total Slots Cleared: %d | Throughput: %.2f slots/s\n", res.Destruction.Slots, res.Destruction.Throughput)
+		if !cfg.dryRun {
+			store, history := b.storeSizes()
+			res.Destroy.StoreReclaimed, res.Destroy.HistoryGrowth = storeBefore-store, history-historyBefore
+			if cfg.scheme == rawdb.PathScheme {
+				res.Destroy.Checked, res.Destroy.OnDisk = len(addrs), b.countStorageRoots(addrs)
+			}
+			printDestroy(out, res.Destroy, cfg.scheme)
+		}
+		fmt.Fprintf(out, "Commit Latency: %v\n", res.Destruction.Latency)
+		printHashing(out, res.Destruction)
+		printBreakdown(out, res.Destruction)
+		printSys(out, res.Destruction)
+		printOpens(out, res.Destruction)
+		printNodes(out, res.Destruction.Nodes)
+	}
+	// 6. Phase 4: Account deletion
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if cfg.delete > 0 {
+		mDelete := min(cfg.delete, cfg.accounts)
+		fmt.Fprintf(out, "\nPhase 4: Deleting %d accounts (mode=%s, k=%d)...\n", mDelete, cfg.deleteMode, cfg.batch)
+
+		res.Deletion = &phaseResult{Name: "deletion", Accounts: mDelete}
+		res.DeleteMode = cfg.deleteMode
+		diskBefore := getDirSize(cfg.dbPath)
+		nodes, allocs := b.trackNodes(), trackAllocs()
+		if err := b.deleteAccounts(ctx, res.Deletion, mDelete); err != nil {
+			return err
+		}
+		res.Deletion.Allocs = allocs(res.Deletion.Slots)
+		res.Deletion.Nodes = nodes()
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Deletion finished in %v. Final Root: %x\n", res.Deletion.Elapsed, b.root)
+		b.milestone(res.Deletion)
+		if cfg.dryRun {
+			fmt.Fprintf(out, "Total Slots Cleared: %d\n", res.Deletion.Slots)
+		} else {
+			// The deleted nodes only free space once compacted away, until
+			// then the tombstones and the new nodes grow the database. The
+			// hash scheme never deletes them at all, leaving them for pruning.
+			if res.DiskReclaimed = diskBefore - getDirSize(cfg.dbPath); res.DiskReclaimed >= 0 {
+				fmt.Fprintf(out, "Total Slots Cleared: %d | Disk Reclaimed: %.2f MB\n", res.Deletion.Slots, float64(res.DiskReclaimed)/1024/1024)
+			} else {
+				pending := "compaction pending"
+				if cfg.scheme == rawdb.HashScheme {
+					pending = "deleted nodes left for pruning"
+				}
+				fmt.Fprintf(out, "Total Slots Cleared: %d | Disk Growth: %.2f MB (%s)\n", res.Deletion.Slots, float64(-res.DiskReclaimed)/1024/1024, pending)
+			}
+		}
+		fmt.Fprintf(out, "Commit Latency: %v\n", res.Deletion.Latency)
+		printHashing(out, res.Deletion)
+		printBreakdown(out, res.Deletion)
+		printSys(out, res.Deletion)
+		printOpens(out, res.Deletion)
+		printNodes(out, res.Deletion.Nodes)
+	}
+	return nil
+}
+
+// modificationPhase runs phase 2, either applying blocks of random slot writes
+// or modifying random accounts in batches, and prints its measurements.
+func (b *bench) modificationPhase(ctx context.Context, res *result) error {
+	var (
+		cfg = b.cfg
+		out = b.out
+	)
+	if cfg.dist == distZipf {
+		res.ZipfS, res.ZipfV = cfg.zipfS, cfg.zipfV
+	}
+	nodes, writes, allocs := b.trackNodes(), b.trackWrites(), trackAllocs()
+	if cfg.verifyMods > 0 {
+		b.mods = newModSample(cfg.verifyMods)
+	}
+	if cfg.trackAccess {
+		res.Modification.Access = new(accessStats)
+		b.access = res.Modification.Access
+	}
+	first, err := cfg.phase2Start(b.next)
+	if err != nil {
+		return err
+	}
+	if cfg.blocks > 0 {
+		res.Blocks, res.TxsPerBlock = cfg.blocks, cfg.txsPerBlock
+
+		if cfg.workload == workloadMixed {
+			res.Mix, res.OpsPerTx = &cfg.mix, cfg.opsPerTx
+			fmt.Fprintf(out, "\nPhase 2: Applying %d blocks of %d mixed transactions from block %d (mix=%s, dist=%s, caches=%s)...\n", cfg.blocks, cfg.txsPerBlock, first, cfg.mix, cfg.dist, cacheState(res.ColdCaches))
+			if err := b.applyMixedBlocks(ctx, res.Modification, first); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintf(out, "\nPhase 2: Applying %d blocks of %d slot writes from block %d (dist=%s, caches=%s)...\n", cfg.blocks, cfg.txsPerBlock, first, cfg.dist, cacheState(res.ColdCaches))
+			if err := b.applyBlocks(ctx, res.Modification, first); err != nil {
+				return err
+			}
+		}
+	} else {
+		mModify := min(cfg.modify, cfg.accounts)
+		res.Modification.Accounts = mModify
+
+		fmt.Fprintf(out, "\nPhase 2: Randomly modifying %d accounts from block %d (mode=%s, k=%d, dist=%s, caches=%s)...\n", mModify, first, cfg.modMode, cfg.batch, cfg.dist, cacheState(res.ColdCaches))
+		if err := b.modifyAccounts(ctx, res.Modification, mModify, first); err != nil {
+			return err
+		}
+	}
+	res.Modification.Allocs = allocs(res.Modification.Slots)
+	res.Modification.Nodes = nodes()
+	sample := b.mods
+	b.mods, b.access = nil, nil
+	if cfg.verkle {
+		res.Modification.Commitment = commitments(res.Modification.Nodes)
+	}
+	res.Modification.Writes = writes(logicalBytes(res.Modification.Accounts, res.Modification.Slots, 0))
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Modification finished in %v. Final New Root: %x\n", res.Modification.Elapsed, b.root)
+	b.milestone(res.Modification)
+	fmt.Fprintf(out, "Total Slots Modified: %d | Throughput: %.2f slots/s\n", res.Modification.Slots, res.Modification.Throughput)
+	if cfg.blocks == 0 {
+		fmt.Fprintf(out, "Accounts Modified: %d | Throughput: %.2f accounts/s\n", res.Modification.Accounts, float64(res.Modification.Accounts)/res.Modification.Elapsed.Seconds())
+	}
+	if cfg.modDelete > 0 {
+		printDeletes(out, res.Modification.Values)
+	}
+	if res.Modification.BlockTime != nil {
+		fmt.Fprintf(out, "Block Commit Latency: %v\n", res.Modification.Latency)
+		fmt.Fprintf(out, "Block Time: %v\n", *res.Modification.BlockTime)
+	} else {
+		fmt.Fprintf(out, "Commit Latency: %v\n", res.Modification.Latency)
+	}
+	printMix(out, res.Modification.Mix)
+	printHashing(out, res.Modification)
+	printBreakdown(out, res.Modification)
+	printSys(out, res.Modification)
+	printOpens(out, res.Modification)
+	printNodes(out, res.Modification.Nodes)
+	printAmplification(out, res.Modification.Writes)
+	printAccess(out, res.Modification.Access)
+	if sample != nil {
+		fmt.Fprintf(out, "Reading back %d sampled slot writes at root %x...\n", len(sample.slots), b.root)
+		check, err := b.verifyMods(sample)
+		if err != nil {
+			return err
+		}
+		check.Writes = res.Modification.Slots
+		res.Modification.ModCheck = check
+		fmt.Fprintf(out, "Mods Verified: %d sampled slots hold their last written value, out of %d writes | %v\n", check.Slots, check.Writes, check.Elapsed)
+	}
+	return nil
+}
+
+// openDatabase opens the Pebble store at path along with the freezer, which is
+// required for pathdb to persist the state histories.
+func openDatabase(cfg *config, path string) (ethdb.Database, *ethpebble.Database, error) {
+	pdb, err := ethpebble.NewCustom(path, "eth/db/chaindata/", func(options *pebble.Options) {
+		for i := range options.Levels {
+			options.Levels[i].Compression = compressions[cfg.compression]
+		}
+		options.Cache = pebble.NewCache(int64(cfg.cacheMB) * 1024 * 1024)
+		options.DisableWAL = cfg.noWAL
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open Pebble: %v", err)
+	}
+	pdb.SetSyncWrites(cfg.syncCommit)
+	diskdb, err := rawdb.Open(pdb, rawdb.OpenOptions{Ancient: filepath.Join(path, "ancient")})
+	if err != nil {
+		pdb.Close()
+		return nil, nil, fmt.Errorf("failed to open freezer: %v", err)
+	}
+	return diskdb, pdb, nil
+}
+
+// stores are the databases a state lives in, from the key-value store up to the
+// state database on top of the tries.
+type stores struct {
+	diskdb ethdb.Database
+	kvdb   *ethpebble.Database // Pebble store underneath diskdb, for its write statistics
+	trieDB *triedb.Database
+	sdb    *state.CachingDB
+}
+
+// openStores opens the key-value store at path and the trie and state databases
+// on top of it, as configured by cfg. Only the key-value store needs closing,
+// the trie database holds nothing unless it is journaled first.
+func openStores(cfg *config, path string) (*stores, error) {
+	diskdb, kvdb, err := openDatabase(cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	trieDB := triedb.NewDatabase(diskdb, newTrieConfig(cfg))
+	return &stores{
+		diskdb: diskdb,
+		kvdb:   kvdb,
+		trieDB: trieDB,
+		sdb:    newStateDatabase(cfg, trieDB, nil),
+	}, nil
+}
+
+// The orders the changed slots are inserted into the storage tries in.
+const (
+	insertOrderRandom = "random" // Map order, as tracked by the StateDB
+	insertOrderSorted = "sorted" // Hashed key order, the layout of the tries
+)
+
+// The layouts of the state trie keys.
+const (
+	keySchemeSecure = "secure" // Keccak256 of the addresses and slots, as on mainnet
+	keySchemeRaw    = "raw"    // The addresses and slots themselves, the legacy non-secure trie
+)
+
+// leafOwner returns the owner of the storage trie of the account found at the
+// given account trie leaf key. Owners are always the hashed address, which the
+// key already is unless the keys are raw.
+func (b *bench) leafOwner(key []byte) common.Hash {
+	if b.cfg.keyScheme == keySchemeRaw {
+		return crypto.Keccak256Hash(key)
+	}
+	return common.BytesToHash(key)
+}
+
+// newStateDatabase creates the state database on top of trieDB and the optional
+// snapshot, limiting the storage tries committed concurrently and ordering
+// their updates as configured.
+func newStateDatabase(cfg *config, trieDB *triedb.Database, snaps *snapshot.Tree) *state.CachingDB {
+	sdb := state.NewDatabase(trieDB, snaps)
+	sdb.SetCommitWorkers(cfg.commitWorkers)
+	sdb.SetSortedStorageUpdates(cfg.insertOrder == insertOrderSorted)
+	return sdb
+}
+
+// newTrieConfig returns the trie database configuration of the selected scheme.
+func newTrieConfig(cfg *config) *triedb.Config {
+	config := &triedb.Config{
+		Preimages:         cfg.preimages,
+		PreimageCacheSize: cfg.preimageMB * 1024 * 1024,
+		SecKeyCacheLimit:  cfg.secKeyLimit,
+		NoHashCache:       cfg.noHashCache,
+		RawKeys:           cfg.keyScheme == keySchemeRaw,
+	}
+	if cfg.preimageMB == 0 {
+		config.PreimageCacheSize = -1
+	}
+	if cfg.scheme == rawdb.HashScheme {
+		hashConfig := *hashdb.Defaults
+		hashConfig.BatchSize = int(cfg.batchMB * 1024 * 1024)
+		config.HashDB = &hashConfig
+		return config
+	}
+	pathConfig := *pathdb.Defaults
+	pathConfig.StateHistory = cfg.history
+	pathConfig.WriteBufferSize = cfg.dirtyCacheMB * 1024 * 1024
+	pathConfig.TrieCleanSize = cfg.cleanCacheMB * 1024 * 1024
+	pathConfig.StateCleanSize = cfg.cleanCacheMB * 1024 * 1024
+	pathConfig.EnableStateIndexing = cfg.historical > 0
+	config.PathDB, config.IsVerkle = &pathConfig, cfg.verkle
+	return config
+}
+
+// bench holds the databases shared by the benchmark phases.
+type bench struct {
+	stores
+
+	cfg     *config
+	out     io.Writer
+	snaps   *snapshot.Tree // Snapshot tree, nil unless enabled
+	statedb *state.StateDB
+	flat    *flatStorage // Destination of the slot writes in flat storage mode, nil otherwise
+	load    *readLoad    // Concurrent readers of the committed state, nil unless running
+	mods    *modSample   // Sample of the phase 2 writes to read back, nil unless enabled
+	access  *accessStats // Access counts of the current phase, nil unless tracked
+	root    common.Hash
+	roots   []common.Hash // Roots committed by the run in order, the targets of the historical reads
+	derefs  int           // Number of stale roots dereferenced in hash mode
+	next    uint64        // Number of the block following the last committed one
+	target  *sizeTarget   // Database size phase 1 writes up to, nil unless set
+	heap    heapGuard
+
+	failures []batchFailure // Batches skipped with -continue-on-error
+
+	addrs      []common.Address // Addresses of the accounts created in phase 1
+	slotCounts []int            // Number of slots created per account in phase 1
+
+	csv        *csvWriter // Optional per-batch metrics sink, nil if disabled
+	progress   *progress  // Printer of the running progress of the phase loops
+	batchStart time.Time  // Timestamp the current batch started at
+}
+
+// commit flushes the pending changes of the current batch to disk, re-creates
+// the statedb on top of the new root and records a sample of the batch into
+// the given phase.
+func (b *bench) commit(phase *phaseResult, block uint64, accounts int, slots int64) (batchSample, error) {
+	commitStart := time.Now()
+	if b.cfg.dryRun {
+		return b.hash(phase, block, accounts, slots, commitStart)
+	}
+	// The flat slots are part of the commit, the trie only holds the accounts
+	if b.flat != nil {
+		if err := b.flat.commit(); err != nil {
+			return b.failed(phase, block, accounts, slots, err)
+		}
+	}
+	// Hash the pending changes upfront if requested, leaving the commit with
+	// the database writes only
+	var hashTime time.Duration
+	if b.cfg.measureHash {
+		b.statedb.IntermediateRoot(false)
+		hashTime = time.Since(commitStart)
+		commitStart = time.Now()
+	}
+	var (
+		root      common.Hash
+		released  bool
+		err       error
+		breakdown *commitSteps
+	)
+	if b.cfg.detailed {
+		breakdown = new(commitSteps)
+		root, released, err = b.commitDetailed(block, breakdown)
+	} else {
+		root, released, err = commitState(b.statedb, b.trieDB, b.root, block, b.cfg.capLayers)
+	}
+	if err != nil {
+		return b.failed(phase, block, accounts, slots, err)
+	}
+	commitTime := time.Since(commitStart)
+	if breakdown != nil {
+		breakdown.Total = hashTime + commitTime
+		breakdown.Other = max(0, breakdown.Total-breakdown.Finalise-breakdown.StorageHash-breakdown.AccountUpdate-breakdown.AccountHash-
+			breakdown.TrieCommit-breakdown.LayerUpdate-breakdown.SnapshotUpdate-breakdown.Flush)
+	}
+	if released {
+		b.derefs++
+	}
+	if root != b.root {
+		b.roots = append(b.roots, root)
+	}
+	b.root = root
+	if b.load != nil {
+		// Move the readers off the superseded root before it goes stale
+		b.load.update(root, accounts)
+	}
+
+	// Borrowed from C#: Memory monitoring
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	sample := batchSample{
+		Batch:     len(phase.Batches) + 1,
+		Block:     block,
+		Accounts:  accounts,
+		Slots:     slots,
+		Root:      root,
+		DiskSize:  getDirSize(b.cfg.dbPath),
+		MemAlloc:  mem.Alloc,
+		HeapInuse: mem.HeapInuse,
+		NumGC:     mem.NumGC,
+		Duration:  time.Since(b.batchStart),
+		Hash:      hashTime,
+		Commit:    commitTime,
+		Readers:   b.load != nil && b.load.active.Load(),
+		Breakdown: breakdown,
+	}
+	// Re-create statedb from the new root to release memory of dirty objects,
+	// outside of the batch time
+	openStart := time.Now()
+	b.statedb, _ = state.New(root, b.sdb)
+	if b.cfg.measureOpen {
+		sample.Open = time.Since(openStart)
+	}
+	if err := b.record(phase, &sample); err != nil {
+		return batchSample{}, err
+	}
+	if !b.cfg.noForcedGC {
+		runtime.GC() // Suggest GC to clean up
+	}
+	if b.cfg.maxHeapMB > 0 {
+		if err := b.guardHeap(phase); err != nil {
+			return batchSample{}, err
+		}
+	}
+	b.batchStart = time.Now()
+	return sample, nil
+}
+
+// openState opens the state at root. If probe is set, the state is also read
+// from, as opening it only resolves the root node. Any account lookup walks
+// the trie down from the root, whether the account exists or not.
+func openState(sdb *state.CachingDB, root common.Hash, probe bool) (*state.StateDB, error) {
+	statedb, err := state.New(root, sdb)
+	if err != nil {
+		return nil, fmt.Errorf("state %x is not available: %v", root, err)
+	}
+	if probe {
+		statedb.GetBalance(common.Address{})
+		if err := statedb.Error(); err != nil {
+			return nil, fmt.Errorf("state %x is not readable: %v", root, err)
+		}
+	}
+	return statedb, nil
+}
+
+// reopen closes the trie and key-value databases and opens them again at the
+// current root, dropping every in-memory cache. In path mode the buffered
+// layers are journaled first, so they can be recovered on reopen, and the
+// journal statistics are returned.
+func (b *bench) reopen() (*journalStats, error) {
+	var (
+		journal *journalStats
+		err     error
+	)
+	if b.trieDB.Scheme() == rawdb.PathScheme {
+		if journal, err = b.journal(); err != nil {
+			return nil, err
+		}
+	}
+	// The journal is loaded when the trie database is opened, but only a read
+	// proves the state usable again
+	start := time.Now()
+	if err := b.reopenStores(); err != nil {
+		return nil, err
+	}
+	if b.statedb, err = openState(b.sdb, b.root, true); err != nil {
+		return nil, err
+	}
+	if journal != nil {
+		journal.Replay = time.Since(start)
+	}
+	return journal, nil
+}
+
+// reopenStores closes the trie and key-value databases and opens them again,
+// leaving the journaling of the buffered layers to the caller in path mode.
+func (b *bench) reopenStores() error {
+	if err := b.trieDB.Close(); err != nil {
+		return fmt.Errorf("failed to close TrieDB: %v", err)
+	}
+	if err := b.diskdb.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %v", err)
+	}
+	stores, err := openStores(b.cfg, b.cfg.dbPath)
+	if err != nil {
+		return err
+	}
+	b.stores = *stores
+	if b.flat != nil {
+		b.flat = newFlatStorage(b.diskdb)
+	}
+	return nil
+}
+
+// journalStats contains the size of the pathdb journal and the time taken to
+// write and load it.
+type journalStats struct {
+	Bytes   int           `json:"bytes"`
+	Elapsed time.Duration `json:"elapsedNs"`          // Time taken to write the journal
+	Replay  time.Duration `json:"replayNs,omitempty"` // Time from reopening until the first read succeeded
+}
+
+// journal persists the in-memory layers of the path database up to the current
+// root into its journal, measuring the size of the written journal.
+func (b *bench) journal() (*journalStats, error) {
+	start := time.Now()
+	if err := b.trieDB.Journal(b.root); err != nil {
+		return nil, fmt.Errorf("failed to journal state: %v", err)
+	}
+	return &journalStats{
+		Bytes:   len(rawdb.ReadTrieJournal(b.diskdb)),
+		Elapsed: time.Since(start),
+	}, nil
+}
+
+// commitState commits the pending changes of statedb and flushes them into
+// trieDB. In path mode, a non-zero layer count keeps that many diff layers in
+// memory instead of flattening all of them. It returns the new root and
+// whether the superseded root prev was released.
+func commitState(statedb *state.StateDB, trieDB *triedb.Database, prev common.Hash, block uint64, layers int) (common.Hash, bool, error) {
+	root, err := statedb.Commit(block, false, false)
+	if err != nil {
+		return common.Hash{}, false, fmt.Errorf("failed to commit StateDB: %v", err)
+	}
+	released, err := flushState(trieDB, prev, root, layers)
+	return root, released, err
+}
+
+// flushState commits the new root handed to trieDB by the StateDB commit, or
+// caps the diff layers on top of it, releasing the superseded root prev in
+// hash mode. It returns whether prev was released.
+func flushState(trieDB *triedb.Database, prev common.Hash, root common.Hash, layers int) (bool, error) {
+	// A batch leaving the trie untouched, e.g. only writing flat storage, has
+	// nothing to flush, and pathdb refuses to commit its disk layer again
+	if root == prev {
+		return false, nil
+	}
+	// The hash scheme keeps the nodes reference counted in memory, pin the
+	// new root before flushing it and release the one it supersedes, similar
+	// to how the blockchain garbage collects the stale tries.
+	hashMode := trieDB.Scheme() == rawdb.HashScheme
+	if hashMode {
+		trieDB.Reference(root, common.Hash{})
+	}
+	if layers > 0 && !hashMode {
+		if err := trieDB.CapLayers(root, layers); err != nil {
+			return false, fmt.Errorf("failed to cap TrieDB: %v", err)
+		}
+	} else if err := trieDB.Commit(root, false); err != nil {
+		return false, fmt.Errorf("failed to commit TrieDB: %v", err)
+	}
+	if hashMode && prev != (common.Hash{}) && prev != root {
+		trieDB.Dereference(prev)
+		return true, nil
+	}
+	return false, nil
+}
+
+// batchFailure describes a batch that failed to commit.
+type batchFailure struct {
+	Phase string `json:"phase"`
+	Batch int    `json:"batch"` // Index the batch would have been recorded under
+	Block uint64 `json:"block"`
+	First int    `json:"firstAccount"` // Accounts processed in the phase before the batch
+	Last  int    `json:"lastAccount"`  // Accounts processed in the phase including the batch
+	Error string `json:"error"`
+}
+
+// failed handles the commit error of the current batch. It wraps the error
+// with the position of the batch, aborting the run unless -continue-on-error
+// is set. Then the batch is tallied and its changes dropped instead, the
+// returned sample describes the state the next batch continues from, the last
+// committed one.
+func (b *bench) failed(phase *phaseResult, block uint64, accounts int, slots int64, err error) (batchSample, error) {
+	f := batchFailure{
+		Phase: phase.Name,
+		Batch: len(phase.Batches) + 1,
+		Block: block,
+		First: phase.committed(),
+		Last:  accounts,
+	}
+	if n := len(b.failures); n > 0 && b.failures[n-1].Phase == phase.Name {
+		f.First = max(f.First, b.failures[n-1].Last) // Not undone by the previous failure
+	}
+	err = fmt.Errorf("failed to commit %s batch %d (block %d, accounts %d-%d): %v", f.Phase, f.Batch, f.Block, f.First+1, f.Last, err)
+	if !b.cfg.continueOnError {
+		return batchSample{}, err
+	}
+	// A commit failing after the trie database flattened the last root can't
+	// be rolled back
+	statedb, serr := state.New(b.root, b.sdb)
+	if serr != nil {
+		return batchSample{}, fmt.Errorf("%v, not skipped as the last committed root is gone: %v", err, serr)
+	}
+	b.statedb = statedb
+	f.Error = err.Error()
+	b.failures = append(b.failures, f)
+	fmt.Fprintf(b.out, "\n%v, skipping the batch\n", err)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	sample := batchSample{
+		Batch:     f.Batch,
+		Block:     block,
+		Accounts:  accounts,
+		Slots:     slots,
+		Root:      b.root,
+		DiskSize:  getDirSize(b.cfg.dbPath),
+		MemAlloc:  mem.Alloc,
+		HeapInuse: mem.HeapInuse,
+		NumGC:     mem.NumGC,
+		Duration:  time.Since(b.batchStart),
+	}
+	b.batchStart = time.Now()
+	return sample, nil
+}
+
+// printFailures writes the batches skipped with -continue-on-error into w.
+func printFailures(w io.Writer, failures []batchFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Failures:      %d batches failed to commit and were skipped\n", len(failures))
+	for _, f := range failures {
+		fmt.Fprintf(w, "               %s\n", f.Error)
+	}
+}
+
+// hash computes the root of the pending changes in memory without committing
+// anything, used in dry-run mode. The statedb is kept as is, so the following
+// batches build on top of the dirty state.
+func (b *bench) hash(phase *phaseResult, block uint64, accounts int, slots int64, start time.Time) (batchSample, error) {
+	root := b.statedb.IntermediateRoot(false)
+	hashTime := time.Since(start)
+	b.root = root
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	sample := batchSample{
+		Batch:     len(phase.Batches) + 1,
+		Block:     block,
+		Accounts:  accounts,
+		Slots:     slots,
+		Root:      root,
+		MemAlloc:  mem.Alloc,
+		HeapInuse: mem.HeapInuse,
+		NumGC:     mem.NumGC,
+		Duration:  time.Since(b.batchStart),
+		Commit:    hashTime,
+	}
+	if err := b.record(phase, &sample); err != nil {
+		return batchSample{}, err
+	}
+	if b.cfg.maxHeapMB > 0 {
+		if err := b.guardHeap(phase); err != nil {
+			return batchSample{}, err
+		}
+	}
+	b.batchStart = time.Now()
+	return sample, nil
+}
+
+// record appends the sample to the phase, the CSV file and the live metrics
+// if enabled, and logs the random draws of the batch. The resource usage of
+// the process is sampled into it with -sysstat. It fails once the draws
+// diverged from the verified log.
+func (b *bench) record(phase *phaseResult, sample *batchSample) error {
+	if b.cfg.sys != nil {
+		sys, err := b.cfg.sys.sample()
+		if err != nil {
+			return err
+		}
+		sample.Sys = sys
+	}
+	phase.Batches = append(phase.Batches, *sample)
+	b.next = sample.Block + 1
+	if err := b.cfg.rng.checkpoint(phase.Name, sample.Batch); err != nil {
+		return err
+	}
+	if b.cfg.live != nil {
+		b.cfg.live.update(phase, *sample)
+	}
+	if b.csv != nil {
+		if err := b.csv.write(phase.Name, *sample); err != nil {
+			return fmt.Errorf("failed to write CSV row: %v", err)
+		}
+	}
+	return nil
+}
+
+// usage returns the human-readable resource usage after a batch. The disk
+// size is omitted in dry-run mode, as nothing is written there.
+func (b *bench) usage(sample batchSample) string {
+	mem := fmt.Sprintf("MemAlloc: %.2f MB", float64(sample.MemAlloc)/1024/1024)
+	if b.cfg.noForcedGC {
+		mem += fmt.Sprintf(" | HeapInuse: %.2f MB | GCs: %d", float64(sample.HeapInuse)/1024/1024, sample.NumGC)
+	}
+	if sample.Sys != nil {
+		mem += fmt.Sprintf(" | RSS: %.2f MB | CPU: %.0f%%", float64(sample.Sys.RSS)/1024/1024, cpuPercent(sample.Sys.CPU, sample.Sys.Wall))
+	}
+	if b.cfg.dryRun {
+		return mem
+	}
+	return fmt.Sprintf("Disk: %.2f MB | %s", float64(sample.DiskSize)/1024/1024, mem)
+}
+
+// checkRoot compares the root produced by a phase against the expected one,
+// if any was configured.
+func checkRoot(phase string, want *common.Hash, have common.Hash) error {
+	if want == nil || *want == have {
+		return nil
+	}
+	return fmt.Errorf("%s root mismatch\n  expected: %x\n  computed: %x", phase, *want, have)
+}
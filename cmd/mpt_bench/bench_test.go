@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+func TestCommitStateNoChangeCapLayers(t *testing.T) {
+	var (
+		trieDB = triedb.NewDatabase(rawdb.NewMemoryDatabase(), &triedb.Config{PathDB: pathdb.Defaults})
+		sdb    = state.NewDatabase(trieDB, nil)
+		addr   = common.HexToAddress("0x01")
+	)
+	defer trieDB.Close()
+
+	statedb, err := state.New(types.EmptyRootHash, sdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb.SetState(addr, common.HexToHash("0x01"), common.HexToHash("0x02"))
+	root, _, err := commitState(statedb, trieDB, types.EmptyRootHash, 1, 2)
+	if err != nil {
+		t.Fatalf("failed to commit batch: %v", err)
+	}
+	// Flatten the state into the disk layer, like a resumed or reopened run
+	if err := trieDB.Commit(root, false); err != nil {
+		t.Fatal(err)
+	}
+	if statedb, err = state.New(root, sdb); err != nil {
+		t.Fatal(err)
+	}
+	have, _, err := commitState(statedb, trieDB, root, 2, 2)
+	if err != nil {
+		t.Fatalf("failed to commit batch leaving the state untouched: %v", err)
+	}
+	if have != root {
+		t.Fatalf("root changed by an empty batch: have %x, want %x", have, root)
+	}
+	// Capping the disk layer without knowing the previous root is a no-op too
+	if _, err := flushState(trieDB, common.Hash{}, root, 2); err != nil {
+		t.Fatalf("failed to cap the disk layer: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// benchPkg is the package the benchmark lines are attributed to.
+const benchPkg = "github.com/ethereum/go-ethereum/cmd/mpt_bench"
+
+// printBenchHeader writes the configuration lines opening a file of benchmark
+// results into w, as go test -bench does.
+func printBenchHeader(w io.Writer) {
+	fmt.Fprintf(w, "goos: %s\n", runtime.GOOS)
+	fmt.Fprintf(w, "goarch: %s\n", runtime.GOARCH)
+	fmt.Fprintf(w, "pkg: %s\n", benchPkg)
+}
+
+// printBench writes the phases of r that ran into w, one benchmark line each
+// in the format of go test -bench, so that repeated runs of a scenario can be
+// compared with benchstat. A phase operation is a single slot write, or a
+// single read for the read phase.
+func printBench(w io.Writer, r *result) {
+	phases := []struct {
+		name  string
+		phase *phaseResult
+	}{
+		{"Replay", r.Replay},
+		{"Create", r.Creation},
+		{"Modify", r.Modification},
+		{"Reorg", r.Reorg},
+		{"Destroy", r.Destruction},
+		{"Delete", r.Deletion},
+	}
+	for _, p := range phases {
+		if p.phase == nil || p.phase.Slots == 0 {
+			continue
+		}
+		metrics := []benchMetric{
+			{p.phase.Throughput, "slots/s"},
+			{float64(p.phase.Latency.P50), "ns/commit-p50"},
+			{float64(p.phase.Latency.P99), "ns/commit-p99"},
+		}
+		if a := p.phase.Allocs; a != nil {
+			metrics = append(metrics, benchMetric{a.BytesPerSlot, "B/slot"}, benchMetric{a.PerSlot, "allocs/slot"})
+		}
+		printBenchLine(w, benchName(p.name, r), p.phase.Slots, p.phase.Elapsed, metrics)
+	}
+	if r.Reads != nil && r.Reads.Reads > 0 {
+		printBenchLine(w, benchName("Read", r), int64(r.Reads.Reads), r.Reads.Elapsed, []benchMetric{
+			{r.Reads.Throughput, "reads/s"},
+			{float64(r.Reads.P95), "ns/read-p95"},
+		})
+	}
+}
+
+// benchMetric is a single value of a benchmark line along with its unit.
+type benchMetric struct {
+	value float64
+	unit  string
+}
+
+// benchName returns the name of the benchmark of a phase of r. The state
+// layout is part of it in the key=value form of sub-benchmarks, keeping the
+// runs of a comparison apart while benchstat can still group by the keys.
+func benchName(phase string, r *result) string {
+	parts := []string{"Benchmark" + phase, "scheme=" + r.Scheme, "tree=" + r.Tree}
+	if r.FlatStorage {
+		parts = append(parts, "storage=flat")
+	}
+	if r.Snapshot != nil {
+		parts = append(parts, "snapshot=on")
+	}
+	if r.CommitLimit > 0 {
+		parts = append(parts, fmt.Sprintf("commit-workers=%d", r.CommitLimit))
+	}
+	name := strings.Join(parts, "/")
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		name += fmt.Sprintf("-%d", procs)
+	}
+	return name
+}
+
+// printBenchLine writes a single benchmark line of n operations taking elapsed
+// in total into w, followed by the extra metrics.
+func printBenchLine(w io.Writer, name string, n int64, elapsed time.Duration, metrics []benchMetric) {
+	fmt.Fprintf(w, "%s\t%d\t%.2f ns/op", name, n, float64(elapsed)/float64(n))
+	for _, m := range metrics {
+		fmt.Fprintf(w, "\t%.2f %s", m.value, m.unit)
+	}
+	fmt.Fprintln(w)
+}
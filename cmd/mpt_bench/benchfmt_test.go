@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintBench(t *testing.T) {
+	r := &result{
+		Scheme:      "hash",
+		Tree:        treeMPT,
+		FlatStorage: true,
+		Creation:    &phaseResult{Slots: 1000, Elapsed: time.Second, Throughput: 1000},
+		Modification: &phaseResult{
+			Slots:      500,
+			Elapsed:    time.Second,
+			Throughput: 500,
+			Allocs:     &allocStats{PerSlot: 2, BytesPerSlot: 64},
+		},
+		Deletion: &phaseResult{}, // Skipped, nothing deleted
+	}
+	var buf bytes.Buffer
+	printBench(&buf, r)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("have %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	suffix := ""
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		suffix = fmt.Sprintf("-%d", procs)
+	}
+	for i, want := range []string{
+		"BenchmarkCreate/scheme=hash/tree=mpt/storage=flat" + suffix + "\t1000\t1000000.00 ns/op\t1000.00 slots/s",
+		"BenchmarkModify/scheme=hash/tree=mpt/storage=flat" + suffix + "\t500\t2000000.00 ns/op\t500.00 slots/s",
+	} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("line %d: have %q, want prefix %q", i, lines[i], want)
+		}
+	}
+	if !strings.HasSuffix(lines[1], "\t64.00 B/slot\t2.00 allocs/slot") {
+		t.Errorf("allocations missing: %q", lines[1])
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// commitSteps splits the time of the commits into their steps, as timed by
+// the StateDB. The storage tries are hashed and committed concurrently to one
+// another and the account trie, those steps count the wall time until the
+// slowest one finished.
+type commitSteps struct {
+	Finalise       time.Duration `json:"finaliseNs"`       // Moving the dirty objects into the pending set
+	StorageHash    time.Duration `json:"storageHashNs"`    // Updating and hashing the storage tries
+	AccountUpdate  time.Duration `json:"accountUpdateNs"`  // Writing the accounts into the account trie
+	AccountHash    time.Duration `json:"accountHashNs"`    // Hashing the account trie
+	TrieCommit     time.Duration `json:"trieCommitNs"`     // Collecting the dirty nodes of all the tries
+	LayerUpdate    time.Duration `json:"layerUpdateNs"`    // Handing the nodes to the trie database
+	SnapshotUpdate time.Duration `json:"snapshotUpdateNs"` // Updating the snapshot tree, if enabled
+	Flush          time.Duration `json:"flushNs"`          // Committing or capping the trie database
+	Other          time.Duration `json:"otherNs"`          // Untimed remainder, e.g. destructions and code writes
+	Total          time.Duration `json:"totalNs"`          // Hashing included if measured apart
+}
+
+// add accumulates the steps of another commit into b.
+func (b *commitSteps) add(o *commitSteps) {
+	b.Finalise += o.Finalise
+	b.StorageHash += o.StorageHash
+	b.AccountUpdate += o.AccountUpdate
+	b.AccountHash += o.AccountHash
+	b.TrieCommit += o.TrieCommit
+	b.LayerUpdate += o.LayerUpdate
+	b.SnapshotUpdate += o.SnapshotUpdate
+	b.Flush += o.Flush
+	b.Other += o.Other
+	b.Total += o.Total
+}
+
+// commitDetailed commits the current statedb like commitState, timing the
+// steps of the commit into breakdown. The StateDB timers are read from the
+// statedb of the batch, which is replaced after every commit, so they only
+// cover this one. Hashing done by an earlier IntermediateRoot is included.
+func (b *bench) commitDetailed(block uint64, breakdown *commitSteps) (common.Hash, bool, error) {
+	start := time.Now()
+	b.statedb.Finalise(false) // Done by the commit too, timed apart here
+	breakdown.Finalise = time.Since(start)
+
+	root, err := b.statedb.Commit(block, false, false)
+	if err != nil {
+		return common.Hash{}, false, fmt.Errorf("failed to commit StateDB: %v", err)
+	}
+	flushStart := time.Now()
+	released, err := flushState(b.trieDB, b.root, root, b.cfg.capLayers)
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+	breakdown.Flush = time.Since(flushStart)
+	readCommitTimers(b.statedb, breakdown)
+	return root, released, nil
+}
+
+// readCommitTimers copies the commit timers of statedb into breakdown.
+func readCommitTimers(statedb *state.StateDB, breakdown *commitSteps) {
+	breakdown.StorageHash = statedb.StorageUpdates
+	breakdown.AccountUpdate = statedb.AccountUpdates
+	breakdown.AccountHash = statedb.AccountHashes
+	breakdown.TrieCommit = max(statedb.AccountCommits, statedb.StorageCommits)
+	breakdown.LayerUpdate = statedb.TrieDBCommits
+	breakdown.SnapshotUpdate = statedb.SnapshotCommits
+}
+
+// printBreakdown writes the share of the commit time of a phase spent in every
+// step into w, if it was broken down.
+func printBreakdown(w io.Writer, p *phaseResult) {
+	c := p.Breakdown
+	if c == nil || c.Total == 0 {
+		return
+	}
+	steps := []struct {
+		name string
+		time time.Duration
+	}{
+		{"finalise", c.Finalise},
+		{"storage hash", c.StorageHash},
+		{"account update", c.AccountUpdate},
+		{"account hash", c.AccountHash},
+		{"trie commit", c.TrieCommit},
+		{"layer update", c.LayerUpdate},
+		{"snapshot", c.SnapshotUpdate},
+		{"flush", c.Flush},
+		{"other", c.Other},
+	}
+	shares := make([]string, 0, len(steps))
+	for _, s := range steps {
+		if s.time == 0 {
+			continue
+		}
+		shares = append(shares, fmt.Sprintf("%s %.1f%%", s.name, float64(s.time)/float64(c.Total)*100))
+	}
+	fmt.Fprintf(w, "Commit Breakdown: %v total | %s\n", c.Total.Round(time.Millisecond), strings.Join(shares, ", "))
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+)
+
+// report is the final outcome of a command, printed once it finishes.
+type report interface {
+	// print writes the report into w in the requested format.
+	print(w io.Writer, format string) error
+
+	// exitCode returns the exit code of the process reporting it.
+	exitCode() int
+}
+
+// command is a subcommand of the benchmark, running a subset of its phases
+// with the flags relevant to them.
+type command struct {
+	name  string
+	usage string
+	flags func(f *cliFlags)                                                     // Registers the flags of the command
+	check func(cfg *config) error                                               // Checks the requirements of the command, nil if none
+	run   func(ctx context.Context, cfg *config, out io.Writer) (report, error) // Runs the command
+}
+
+// commands are the subcommands of the benchmark. The first one runs if none is
+// named on the command line, keeping the single flat flag set running all the
+// phases at once available.
+var commands = []*command{
+	{
+		name:  "run",
+		usage: "Run any combination of the phases, the default if no command is given",
+		flags: func(f *cliFlags) {
+			f.stateFlags()
+			f.writeFlags()
+			f.queryFlags()
+			f.checkFlags()
+			f.storeFlags()
+			f.layoutFlags()
+			f.outputFlags()
+			f.metricFlags()
+			f.compareVar("compare", "", "Run the workload under two schemes in temporary databases and compare the results (e.g. path:hash, path:flat for flat storage, hash:snapshot for snapshot commits, path:sync for synced commits, path:account for per-account commits, path:prealloc for pre-sized maps, path:nocache for cold hashing, path:sorted for sorted trie insertion, path:preimages for preimage recording, path:archive for archive retention, or path:raw for unhashed trie keys)")
+			f.fs.BoolVar(&f.commitSnap, "commit-snapshot", false, "Compare the workload committed without and with a snapshot updated by every phase 2 commit, reporting the throughput delta (shorthand for -compare hash:snapshot)")
+			f.fs.BoolVar(&f.commitEach, "commit-every-account", false, "Compare the workload committed every -k accounts and after every single account, stressing the diff layer management with many tiny layers (shorthand for -compare <scheme>:account)")
+			f.fs.BoolVar(&f.cfg.sweepWorkers, "commit-workers-sweep", f.cfg.sweepWorkers, "Run the workload in temporary databases once per -commit-workers from 1 to the number of CPUs, reporting the throughput scaling")
+			f.fs.IntVar(&f.cfg.iterations, "iterations", f.cfg.iterations, "Run the whole workload this many times, clearing the database between the runs unless resuming, reporting the mean and spread of the throughput and disk usage and checking that the roots match")
+			f.fs.BoolVar(&f.cfg.recover, "recover", f.cfg.recover, "Reopen a database left by -inject-crash-after and report the root recovered instead of running the phases (requires -clear=false)")
+		},
+		run: func(ctx context.Context, cfg *config, out io.Writer) (report, error) {
+			switch {
+			case cfg.recover:
+				return runRecover(cfg, out)
+			case len(cfg.compare) > 0:
+				return runCompare(ctx, cfg, out)
+			case cfg.sweepWorkers:
+				return runSweep(ctx, cfg, out)
+			case cfg.iterations > 1:
+				return runIterations(ctx, cfg, out)
+			}
+			return runBenchmark(ctx, cfg, out)
+		},
+	},
+	{
+		name:  "write",
+		usage: "Create, modify and delete accounts, measuring the write phases only",
+		flags: func(f *cliFlags) {
+			f.stateFlags()
+			f.writeFlags()
+			f.checkFlags()
+			f.storeFlags()
+			f.layoutFlags()
+			f.outputFlags()
+			f.metricFlags()
+		},
+		run: func(ctx context.Context, cfg *config, out io.Writer) (report, error) {
+			return runBenchmark(ctx, cfg, out)
+		},
+	},
+	{
+		name:  "read",
+		usage: "Read, prove and iterate the state left at -root by a previous write, with the same -n and slot flags",
+		flags: func(f *cliFlags) {
+			f.cfg.clear, f.cfg.modify = false, 0
+			f.stateFlags()
+			f.queryFlags()
+			f.storeFlags()
+			f.outputFlags()
+			f.rootVar("root", "Root of the existing state to read from")
+		},
+		check: func(cfg *config) error {
+			if cfg.resumeRoot == nil {
+				return fmt.Errorf("reading needs the root of an existing state, set -root")
+			}
+			if cfg.reads == 0 && cfg.proofs == 0 && !cfg.iterate {
+				return fmt.Errorf("nothing to read, set -reads, -proofs or -iterate")
+			}
+			return nil
+		},
+		run: func(ctx context.Context, cfg *config, out io.Writer) (report, error) {
+			return runBenchmark(ctx, cfg, out)
+		},
+	},
+	{
+		name:  "verify",
+		usage: "Check the integrity of every trie node of the state left at -root",
+		flags: func(f *cliFlags) {
+			f.cfg.clear, f.cfg.verifyTrie = false, true
+			f.storeFlags()
+			f.outputFlags()
+			f.rootVar("root", "Root of the existing state to verify")
+		},
+		check: func(cfg *config) error {
+			if cfg.resumeRoot == nil {
+				return fmt.Errorf("verifying needs the root of an existing state, set -root")
+			}
+			return nil
+		},
+		run: func(ctx context.Context, cfg *config, out io.Writer) (report, error) {
+			return runVerify(cfg, out)
+		},
+	},
+	{
+		name:  "compare",
+		usage: "Run the write workload under two schemes in temporary databases and compare the results",
+		flags: func(f *cliFlags) {
+			f.stateFlags()
+			f.writeFlags()
+			f.queryFlags()
+			f.checkFlags()
+			f.storeFlags()
+			f.outputFlags()
+			f.compareVar("schemes", defaultCompareSchemes, "Schemes to run the workload under, separated by a colon (path:hash, path:flat for flat storage, hash:snapshot for snapshot commits, path:sync for synced commits, path:account for per-account commits, path:prealloc for pre-sized maps, path:nocache for cold hashing, path:sorted for sorted trie insertion, path:preimages for preimage recording, path:archive for archive retention, or path:raw for unhashed trie keys)")
+		},
+		run: func(ctx context.Context, cfg *config, out io.Writer) (report, error) {
+			return runCompare(ctx, cfg, out)
+		},
+	},
+	{
+		name:  "recover",
+		usage: "Reopen the database left by a run with -inject-crash-after and report the root recovered",
+		flags: func(f *cliFlags) {
+			f.cfg.clear, f.cfg.recover = false, true
+			f.storeFlags()
+			f.outputFlags()
+			f.fs.BoolVar(&f.cfg.verifyTrie, "verify-trie", f.cfg.verifyTrie, "Check the integrity of every trie node of the recovered root")
+		},
+		run: func(ctx context.Context, cfg *config, out io.Writer) (report, error) {
+			return runRecover(cfg, out)
+		},
+	},
+}
+
+// defaultCompareSchemes are the schemes the compare command runs under by default.
+const defaultCompareSchemes = "path:hash"
+
+// findCommand returns the command named by the first argument along with the
+// arguments left for its flags. If the first argument is a flag or missing,
+// the default command is returned.
+func findCommand(args []string) (*command, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commands[0], args, nil
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd, args[1:], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("unknown command %q", args[0])
+}
+
+// parseCommand parses the flags of cmd out of args, returning the validated
+// configuration to run it with.
+func parseCommand(cmd *command, args []string) (*config, error) {
+	f := newCLIFlags(cmd.name)
+	cmd.flags(f)
+	f.fs.Usage = func() {
+		w := f.fs.Output()
+		fmt.Fprintf(w, "Usage: mpt_bench %s [flags]\n\n%s.\n\nFlags:\n", cmd.name, cmd.usage)
+		f.fs.PrintDefaults()
+	}
+	cfg, err := f.parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.check != nil {
+		if err := cmd.check(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// printCommands writes the list of the commands into w.
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// runBenchmark runs the phases described by cfg on a single database.
+func runBenchmark(ctx context.Context, cfg *config, out io.Writer) (*result, error) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	res, err := run(ctx, cfg, out)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.noForcedGC {
+		res.GC = gcSince(&mem)
+	}
+	return res, nil
+}
+
+// printUsage writes the general usage of the benchmark into w.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: mpt_bench [command] [flags]\n\n")
+	printCommands(w)
+	fmt.Fprintf(w, "\nRun mpt_bench <command> -h for the flags of a command.\n")
+}
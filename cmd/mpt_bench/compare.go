@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// compareFlat is the pseudo scheme of -compare running the workload with flat
+// storage under the configured scheme.
+const compareFlat = "flat"
+
+// compareSnapshot is the pseudo scheme of -compare running the workload under
+// the configured scheme with a snapshot generated after phase 1, which every
+// later commit updates. Against the plain scheme, it measures the cost of the
+// snapshot maintenance on the commits.
+const compareSnapshot = "snapshot"
+
+// compareSync is the pseudo scheme of -compare running the workload under the
+// configured scheme with every Pebble write synced to disk. Against the plain
+// scheme, it measures the cost of durable commits.
+const compareSync = "sync"
+
+// compareAccount is the pseudo scheme of -compare running the workload under
+// the configured scheme with a commit after every single account. Against the
+// plain scheme, it measures the cost of many tiny diff layers.
+const compareAccount = "account"
+
+// comparePrealloc is the pseudo scheme of -compare running the workload under
+// the configured scheme with the StateDB maps of phase 1 pre-sized. Against
+// the plain scheme, it measures the cost of growing the maps on demand.
+const comparePrealloc = "prealloc"
+
+// compareNoHashCache is the pseudo scheme of -compare running the workload
+// under the configured scheme with the cached node hashes bypassed. Against
+// the plain scheme, it measures the savings of the hash cache.
+const compareNoHashCache = "nocache"
+
+// compareSorted is the pseudo scheme of -compare running the workload under the
+// configured scheme with the slots inserted into the storage tries in hashed
+// key order. Against the plain scheme, it measures the effect of the insertion
+// order on the trie updates, the roots being the same.
+const compareSorted = "sorted"
+
+// comparePreimages is the pseudo scheme of -compare running the workload under
+// the configured scheme with the preimages of the hashed trie keys recorded.
+// Against the plain scheme, it measures the disk the preimage store takes up
+// besides the trie nodes, both runs breaking their disk usage down.
+const comparePreimages = "preimages"
+
+// compareArchive is the pseudo scheme of -compare running the workload under
+// the path scheme retaining the state history of every block. Against the
+// plain scheme, pruning the history past -history, it measures the disk the
+// archive retention takes up.
+const compareArchive = "archive"
+
+// compareRaw is the pseudo scheme of -compare running the workload under the
+// configured scheme with the state trie keys inserted unhashed. Against the
+// plain scheme, it measures how the key layout changes the trie nodes and the
+// disk usage, both runs checking their tries for the node counts. The roots
+// necessarily differ and are never compared.
+const compareRaw = "raw"
+
+// comparison contains the results of the same workload run under several
+// state schemes.
+type comparison struct {
+	Schemes     []string  `json:"schemes"`
+	Runs        []*result `json:"runs"`
+	Interrupted bool      `json:"interrupted,omitempty"` // Whether the remaining runs were skipped
+	Mismatches  []string  `json:"mismatches,omitempty"`  // Roots differing across the schemes
+}
+
+// parseSchemes parses the colon separated list of schemes to compare, returning
+// nil if none was given.
+func parseSchemes(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	schemes := strings.Split(s, ":")
+	if len(schemes) != 2 {
+		return nil, fmt.Errorf("want two schemes separated by a colon, e.g. path:hash")
+	}
+	return schemes, nil
+}
+
+// runCompare runs the workload described by cfg once per scheme to compare, in
+// a fresh temporary database each, and checks that all of them end up with the
+// same roots. The workload is deterministic, so any difference is a bug. Flat
+// storage roots only cover the accounts and are never compared.
+func runCompare(ctx context.Context, cfg *config, out io.Writer) (*comparison, error) {
+	dir, err := os.MkdirTemp("", "mpt_bench_compare")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmp := &comparison{Schemes: cfg.compare}
+	for i, scheme := range cfg.compare {
+		// Every run starts from a pristine copy, as the runs update the config
+		sub := *cfg
+		sub.scheme, sub.compare = scheme, nil
+		switch scheme {
+		case compareFlat:
+			sub.scheme, sub.flatStorage = cfg.scheme, true
+		case compareSnapshot:
+			sub.scheme, sub.snapshot = cfg.scheme, true
+		case compareSync:
+			sub.scheme, sub.syncCommit = cfg.scheme, true
+		case compareAccount:
+			sub.scheme, sub.batch = cfg.scheme, 1
+		case comparePrealloc:
+			sub.scheme, sub.prealloc = cfg.scheme, true
+		case compareNoHashCache:
+			sub.scheme, sub.noHashCache = cfg.scheme, true
+		case compareSorted:
+			sub.scheme, sub.insertOrder = cfg.scheme, insertOrderSorted
+		case comparePreimages:
+			sub.scheme, sub.preimages = cfg.scheme, true
+		case compareArchive:
+			sub.scheme, sub.archive, sub.history = cfg.scheme, true, 0
+		case compareRaw:
+			sub.scheme, sub.keyScheme = cfg.scheme, keySchemeRaw
+		}
+		if slices.Contains(cfg.compare, comparePreimages) {
+			sub.inspect = true
+		}
+		if slices.Contains(cfg.compare, compareRaw) && !cfg.dryRun {
+			sub.verifyTrie = true
+		}
+		if err := sub.validate(); err != nil {
+			return nil, fmt.Errorf("%s run: %v", scheme, err)
+		}
+		sub.dbPath = filepath.Join(dir, fmt.Sprintf("%d-%s", i, scheme))
+		sub.clear = true
+		if sub.csvPath != "" {
+			sub.csvPath = fmt.Sprintf("%s.%s", cfg.csvPath, scheme)
+		}
+		fmt.Fprintf(out, "\n=== Run %d/%d: %s scheme ===\n", i+1, len(cfg.compare), scheme)
+		res, err := run(ctx, &sub, out)
+		if err != nil {
+			return nil, fmt.Errorf("%s scheme: %v", scheme, err)
+		}
+		cmp.Runs = append(cmp.Runs, res)
+		if res.Interrupted {
+			cmp.Interrupted = true
+			return cmp, nil
+		}
+	}
+	first := cmp.Runs[0]
+	for i, res := range cmp.Runs[1:] {
+		if first.FlatStorage != res.FlatStorage || first.KeyScheme != res.KeyScheme {
+			continue
+		}
+		check := func(phase string, want, have *phaseResult) {
+			if want != nil && have != nil && want.Root != have.Root {
+				cmp.Mismatches = append(cmp.Mismatches, fmt.Sprintf("%s root: %s %x, %s %x",
+					phase, cmp.Schemes[0], want.Root, cmp.Schemes[i+1], have.Root))
+			}
+		}
+		check("creation", first.Creation, res.Creation)
+		check("modification", first.Modification, res.Modification)
+		check("destruction", first.Destruction, res.Destruction)
+		check("deletion", first.Deletion, res.Deletion)
+		if first.Root != res.Root {
+			cmp.Mismatches = append(cmp.Mismatches, fmt.Sprintf("final root: %s %x, %s %x",
+				cmp.Schemes[0], first.Root, cmp.Schemes[i+1], res.Root))
+		}
+	}
+	return cmp, nil
+}
+
+// print writes the side-by-side comparison into w in the requested format.
+func (c *comparison) print(w io.Writer, format string) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	if format == outputBench {
+		printBenchHeader(w)
+		for _, r := range c.Runs {
+			printBench(w, r)
+		}
+		return nil
+	}
+	fmt.Fprintf(w, "\n--- Scheme Comparison ---\n")
+	row := func(name string, value func(r *result) string) {
+		fmt.Fprintf(w, "%-24s", name)
+		for _, r := range c.Runs {
+			fmt.Fprintf(w, " %20s", value(r))
+		}
+		fmt.Fprintln(w)
+	}
+	throughput := func(p *phaseResult) string {
+		if p == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", p.Throughput)
+	}
+	root := func(p *phaseResult) string {
+		if p == nil {
+			return "-"
+		}
+		return shortRoot(p.Root)
+	}
+	fmt.Fprintf(w, "%-24s", "")
+	for i, r := range c.Runs {
+		label := c.Schemes[i]
+		if label != r.Scheme { // Pseudo schemes run under a real one
+			label = fmt.Sprintf("%s (%s)", label, r.Scheme)
+		}
+		fmt.Fprintf(w, " %20s", label)
+	}
+	fmt.Fprintln(w)
+	if c.Runs[0].Replay != nil {
+		row("Replay (slots/s)", func(r *result) string { return throughput(r.Replay) })
+	}
+	row("Creation (slots/s)", func(r *result) string { return throughput(r.Creation) })
+	row("Modification (slots/s)", func(r *result) string { return throughput(r.Modification) })
+	row("Destruction (slots/s)", func(r *result) string { return throughput(r.Destruction) })
+	row("Deletion (slots/s)", func(r *result) string { return throughput(r.Deletion) })
+	delta := func(phase func(r *result) *phaseResult) func(r *result) string {
+		return func(r *result) string {
+			base, have := phase(c.Runs[0]), phase(r)
+			switch {
+			case r == c.Runs[0]:
+				return "baseline"
+			case base == nil || have == nil || base.Throughput == 0:
+				return "-"
+			}
+			return fmt.Sprintf("%+.2f%%", (have.Throughput/base.Throughput-1)*100)
+		}
+	}
+	row("Creation Delta", delta(func(r *result) *phaseResult { return r.Creation }))
+	row("Modification Delta", delta(func(r *result) *phaseResult { return r.Modification }))
+	row("Diff Layers", func(r *result) string {
+		if r.Scheme != rawdb.PathScheme {
+			return "-"
+		}
+		return fmt.Sprintf("%d (%d merged)", r.LayersCreated, r.LayersMerged)
+	})
+	row("Disk Usage (MB)", func(r *result) string { return fmt.Sprintf("%.2f", float64(r.DiskSize)/(1024*1024)) })
+	if c.Runs[0].KeyScheme != c.Runs[len(c.Runs)-1].KeyScheme {
+		row("Disk Delta", func(r *result) string {
+			base := c.Runs[0].DiskSize
+			switch {
+			case r == c.Runs[0]:
+				return "baseline"
+			case base == 0:
+				return "-"
+			}
+			return fmt.Sprintf("%+.2f%%", (float64(r.DiskSize)/float64(base)-1)*100)
+		})
+		row("Trie Nodes", func(r *result) string {
+			if v := finalVerify(r); v != nil {
+				return fmt.Sprintf("%d", v.AccountNodes+v.StorageNodes)
+			}
+			return "-"
+		})
+		row("  Account / Storage", func(r *result) string {
+			if v := finalVerify(r); v != nil {
+				return fmt.Sprintf("%d / %d", v.AccountNodes, v.StorageNodes)
+			}
+			return "-"
+		})
+	}
+	if c.Runs[0].Breakdown != nil {
+		usage := func(size func(u *diskBreakdown) int64) func(r *result) string {
+			return func(r *result) string {
+				if r.Breakdown == nil {
+					return "-"
+				}
+				return fmt.Sprintf("%.2f", float64(size(r.Breakdown))/(1024*1024))
+			}
+		}
+		row("State (MB)", usage(func(u *diskBreakdown) int64 { return u.state() }))
+		row("Preimages (MB)", usage(func(u *diskBreakdown) int64 { return u.Preimages }))
+	}
+	row("State History", func(r *result) string {
+		if r.Scheme != rawdb.PathScheme {
+			return "-"
+		}
+		return fmt.Sprintf("%d (%.2f MB)", r.HistoryEntries, float64(r.HistoryBytes)/(1024*1024))
+	})
+	row("Elapsed", func(r *result) string { return r.Elapsed.Round(1e6).String() })
+	row("Creation Root", func(r *result) string { return root(r.Creation) })
+	row("Modification Root", func(r *result) string { return root(r.Modification) })
+	row("Final Root", func(r *result) string { return shortRoot(r.Root) })
+
+	switch {
+	case c.Interrupted:
+		fmt.Fprintf(w, "Interrupted, the remaining schemes were skipped\n")
+	case c.Runs[0].FlatStorage != c.Runs[1].FlatStorage:
+		fmt.Fprintf(w, "Roots not compared, flat storage has no storage tries\n")
+	case c.Runs[0].KeyScheme != c.Runs[1].KeyScheme:
+		fmt.Fprintf(w, "Roots not compared, raw keys lay the tries out differently\n")
+	case len(c.Mismatches) > 0:
+		fmt.Fprintf(w, "ROOT MISMATCH, the schemes disagree on the identical workload:\n")
+		for _, m := range c.Mismatches {
+			fmt.Fprintf(w, "  %s\n", m)
+		}
+	default:
+		fmt.Fprintf(w, "Roots match across all schemes\n")
+	}
+	return nil
+}
+
+// exitCode implements report, failing on any mismatch between the runs.
+func (c *comparison) exitCode() int {
+	switch {
+	case len(c.Mismatches) > 0:
+		return 1
+	case c.Interrupted:
+		return exitInterrupted
+	}
+	return 0
+}
+
+// finalVerify returns the trie check of the last phase of r checking its
+// tries, nil if none did.
+func finalVerify(r *result) *verifyResult {
+	switch {
+	case r.Modification != nil && r.Modification.Verify != nil:
+		return r.Modification.Verify
+	case r.Creation != nil:
+		return r.Creation.Verify
+	}
+	return nil
+}
+
+// shortRoot returns the abbreviated hex form of a root for tabular output.
+func shortRoot(root common.Hash) string {
+	return root.Hex()[:18]
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputBench = "benchfmt" // Benchmark lines of go test -bench, for benchstat
+)
+
+const (
+	compressionNone   = "none"
+	compressionSnappy = "snappy"
+	compressionZstd   = "zstd"
+)
+
+// maxDirtyCacheMB is the largest write buffer pathdb accepts, bigger values are
+// silently capped by the database.
+const maxDirtyCacheMB = 256
+
+// maxCapLayers is the number of diff layers pathdb keeps in memory at most,
+// any layer beyond is flattened into the disk layer on update.
+const maxCapLayers = 128
+
+// compressions maps the supported compression flag values to Pebble's types.
+var compressions = map[string]pebble.Compression{
+	compressionNone:   pebble.NoCompression,
+	compressionSnappy: pebble.SnappyCompression,
+	compressionZstd:   pebble.ZstdCompression,
+}
+
+// config contains all the parameters of a benchmark run.
+type config struct {
+	// Workload parameters
+	addrMode      string        // Derivation of the account addresses (hashed|sequential|prefixed)
+	accounts      int           // Number of accounts to create
+	slots         int           // Average number of slots per account
+	totalSlots    int64         // Total slots written in phase 1 at most, the accounts past it getting fewer or none, 0 to disable
+	batchSlots    bool          // Write the slots of every created account with a single SetStorageBatch call
+	prealloc      bool          // Pre-size the statedb maps of every creation batch to its accounts and slots
+	duration      time.Duration // Time budget of the creation phase, overrides the account count if set
+	targetSize    float64       // Database size in GB the creation phase writes up to, overrides the account count if set
+	codeSize      int           // Bytes of code per contract account, 0 to create EOAs only
+	contractRatio float64       // Fraction of the accounts created as contracts
+	modify        int           // Number of accounts to modify after creation
+	modSeed       int64         // Seed of the random source driving the modifications
+	modDelete     float64       // Fraction of the phase 2 slot writes zeroing the slot instead
+	modMode       string        // Account fields the batched modifications update (storage|balance|mixed)
+	blocks        int           // Number of blocks to apply instead of the batched modifications, 0 to disable
+	txsPerBlock   int           // Number of slot writes, or mixed transactions, per block
+	phase2Block   int64         // First block number of phase 2, -1 to continue right after phase 1
+	workload      string        // Shape of the phase 2 blocks (bulk|mixed)
+	mix           mixWeights    // Weights of the operations of the mixed transactions
+	opsPerTx      int           // Number of operations per mixed transaction
+	batch         int           // Number of accounts per commit/flush
+	workers       int           // Number of goroutines deriving the slot keys
+	commitWorkers int           // Number of storage tries hashed and committed concurrently, 0 for no limit
+	sweepWorkers  bool          // Run the workload once per commit worker count from 1 to the number of CPUs
+	iterations    int           // Number of times to run the whole workload, reporting the spread of the results
+	warmup        int           // Number of accounts written before the measurements start
+	reads         int           // Number of random slot reads after modification, 0 to skip
+	batchReads    int           // Number of slots per batch of the batched reads compared to the single ones, 0 to skip
+	prewarm       bool          // Read on cold caches, then fill the caches with the read slots before the measured reads
+	historical    int           // Number of commits back from the final root to repeat the reads at, 0 to skip
+	readers       int           // Number of goroutines reading the committed state during phase 1, 0 to disable
+	proofs        int           // Number of account and storage proofs to generate, 0 to skip
+	verify        bool          // Whether to verify the generated proofs
+	proofMissing  bool          // Whether to prove absent keys instead of existing ones
+	iterate       bool          // Whether to iterate the whole state after the reads and proofs
+	iterateFrom   []byte        // Hashed account key to start the iteration at, nil for the first one
+	destroy       int           // Number of accounts whose storage is cleared before the deletion, 0 to skip
+	destroyMode   string        // How the storage is cleared (zero|wipe)
+	delete        int           // Number of accounts to delete at the end, 0 to skip
+	deleteMode    string        // How accounts are deleted (selfdestruct|emptyaccount)
+	reorgs        int           // Number of chain reorgs applied after phase 2, 0 to skip
+	reorgDepth    int           // Number of batches every reorg rolls back and re-executes
+	replayPath    string        // Path of an operations file replayed instead of phases 1 and 2, empty if disabled
+
+	// Slot count distribution of the creation phase
+	slotDist    string  // Distribution of the slots per account (uniform|normal|pareto)
+	slotStddev  float64 // Standard deviation of the normal distribution, relative to the mean
+	paretoAlpha float64 // Shape of the pareto distribution, must be > 1
+
+	// Account values of the creation phase
+	balanceDist string  // Distribution of the account balances (fixed|random)
+	nonceDist   string  // Distribution of the account nonces (index|zero|random)
+	zeroBalance float64 // Fraction of the accounts created without balance, whatever the distribution
+
+	// Slot access distribution of the modification phase
+	dist  string  // Distribution of the modified slots (uniform|zipf)
+	zipfS float64 // Zipf s parameter, must be > 1
+	zipfV float64 // Zipf v parameter, must be >= 1
+
+	// Regression checks
+	verifyTrie    bool         // Check the integrity of every trie node after phases 1 and 2
+	rehash        bool         // Rebuild the trie from its leaves after phase 2, comparing the roots
+	verifyMods    int          // Number of sampled phase 2 writes read back from the final root, 0 to skip
+	zeroNoop      int          // Number of absent slots zeroed after phase 1, asserting the state is unchanged, 0 to skip
+	expectRoot    *common.Hash // Expected root after the creation phase, nil if unchecked
+	expectModRoot *common.Hash // Expected root after the modification phase, nil if unchecked
+	rngLogPath    string       // Path of the log of the workload's random draws to write, empty if disabled
+	rngVerifyPath string       // Path of a log of random draws to compare the workload against, empty if disabled
+	rng           *rngLog      // Log of rngLogPath and rngVerifyPath, opened by execute and shared by all the runs
+
+	// Database parameters
+	dbPath      string       // Path to database
+	clear       bool         // Whether to clear the database before starting
+	reopen      bool         // Reopen the databases after phase 1 to start phase 2 with cold caches
+	cacheMB     int          // Size of the Pebble block cache in megabytes
+	compression string       // Compression of the Pebble tables (none|snappy|zstd)
+	noWAL       bool         // Disable the Pebble write-ahead log, giving up crash safety
+	syncCommit  bool         // Sync the write-ahead log on every Pebble write, making each commit durable
+	flatStorage bool         // Write the slots as flat key-values instead of into storage tries
+	noHashCache bool         // Rehash every trie node in memory on each hashing, not only the changed ones
+	insertOrder string       // Order the changed slots are inserted into the storage tries in (random|sorted)
+	keyScheme   string       // Layout of the state trie keys (secure|raw)
+	verkle      bool         // Build the state in the verkle mode of the trie database
+	resumeRoot  *common.Hash // Root of an existing state to continue from, nil to start empty
+	dryRun      bool         // Compute the roots in memory only, never committing to disk
+	shards      int          // Number of independent states the accounts are spread over
+	instances   int          // Number of states sharing the key-value store, each running the whole workload
+	scheme      string       // State scheme of the trie database (path|hash)
+	compare     []string     // State schemes to run the workload under side by side, nil to run once
+	snapshot    bool         // Generate a snapshot after the creation phase (hash scheme only)
+	history     uint64       // Number of recent blocks to keep state history for, 0: keep all
+	archive     bool         // Retain the state history of every block, overriding history (path scheme only)
+	freezer     bool         // Measure the state history freezer apart from the live key-value store (path scheme only)
+
+	// Fault injection
+	crashAfter      int  // Number of phase 1 batches to kill the process after, 0 to disable
+	recover         bool // Recover the database left by a crashed run instead of running the phases
+	continueOnError bool // Skip the batches failing to commit, tallying them, instead of aborting
+
+	// Path scheme cache sizes
+	dirtyCacheMB int // Size of the pathdb write buffer in megabytes
+	cleanCacheMB int // Size of each of the pathdb clean trie and state caches in megabytes
+	capLayers    int // Number of diff layers kept in memory, 0 to flatten them every batch
+
+	// Key-value store batches
+	batchMB float64 // Size of the batches hashdb writes the committed trie nodes in, in megabytes, 0 for the default
+
+	// Preimage recording and its caches
+	preimages   bool // Record the preimages of the hashed trie keys
+	preimageMB  int  // Preimages cached by the trie database before flushing in megabytes, 0 to flush every commit
+	secKeyLimit int  // Preimages a state trie caches before handing them over, 0 to hold them until its commit
+
+	// Reporting parameters
+	measureHash bool   // Time IntermediateRoot apart from the commit of every batch
+	detailed    bool   // Time the steps of every commit, from finalising to flushing the trie database
+	measureOpen bool   // Time reopening the state at the new root after every batch
+	output      string // Output format of the final report
+	csvPath     string // Path of the per-batch CSV metrics file, empty if disabled
+	logJournal  bool   // Send the phase milestones and the summary to the systemd journal
+	tracePath   string // Path of the Go execution trace file, empty if disabled
+	noForcedGC  bool   // Skip the garbage collection forced after every batch
+	compact     bool   // Compact the whole key-value store before measuring the final disk usage
+	compactTrie bool   // Iterate the full trie before and after the compaction, checking that no node was lost
+	checksum    bool   // Hash the state entries of the key-value store after the run
+	depthReport bool   // Report the distribution of the leaf depths of the account trie at the final root
+	depthTries  bool   // Include the leaves of all the storage tries into the depth report
+	trackAccess bool   // Count the cold and warm accesses against an access list kept per batch
+	maxHeapMB   int    // Abort when the heap stays above this many megabytes after a batch, 0 to disable
+	growth      bool   // Sample the throughput and disk usage of phase 1 at logarithmically spaced account counts
+	inspect     bool   // Break the final disk usage down by kind of entry, implied by code and preimages
+	sysstat     bool   // Sample the RSS, CPU time and disk I/O of the process after every batch
+
+	// Progress output of the phase loops
+	progressInterval time.Duration // Interval of the structured progress records on stderr, 0 to print progress lines
+	metricsAddr      string        // Address serving the live metrics over HTTP, empty if disabled
+	live             *liveMetrics  // Server of metricsAddr, started by execute and shared by all the runs
+	sys              *sysSampler   // Sampler of -sysstat, started by execute and shared by all the runs
+	journal          *journalConn  // Journal of -log-journal, opened by execute and shared by all the runs
+
+	// Key dump of the creation phase
+	dumpPath   string  // Path of the dump file, empty if disabled
+	dumpSlots  bool    // Whether to dump the slot keys along with the addresses
+	dumpSample float64 // Percentage of the accounts to dump
+}
+
+// defaultConfig returns the configuration of a run without any flags set.
+func defaultConfig() *config {
+	return &config{
+		addrMode:      addrModeHashed,
+		accounts:      100,
+		slots:         1000,
+		contractRatio: 1,
+		modify:        10,
+		modSeed:       42,
+		modMode:       modModeStorage,
+		txsPerBlock:   100,
+		phase2Block:   -1,
+		workload:      workloadBulk,
+		mix:           defaultMix,
+		opsPerTx:      8,
+		batch:         50,
+		workers:       runtime.NumCPU(),
+		reorgDepth:    1,
+		iterations:    1,
+		insertOrder:   insertOrderRandom,
+		keyScheme:     keySchemeSecure,
+		destroyMode:   destroyModeZero,
+		deleteMode:    deleteModeSelfDestruct,
+		slotDist:      slotDistUniform,
+		balanceDist:   balanceDistFixed,
+		nonceDist:     nonceDistIndex,
+		slotStddev:    0.5,
+		paretoAlpha:   1.5,
+		dist:          distUniform,
+		zipfS:         1.1,
+		zipfV:         1,
+		dbPath:        "mpt_bench_db",
+		clear:         true,
+		cacheMB:       256,
+		compression:   compressionNone,
+		shards:        1,
+		instances:     1,
+		scheme:        rawdb.PathScheme,
+		history:       pathdb.Defaults.StateHistory,
+		dirtyCacheMB:  pathdb.Defaults.WriteBufferSize / 1024 / 1024,
+		cleanCacheMB:  pathdb.Defaults.TrieCleanSize / 1024 / 1024,
+		preimageMB:    4,
+		output:        outputText,
+		dumpSample:    100,
+	}
+}
+
+// phase2Start returns the first block number of phase 2, given the number of
+// the block following the last one committed by phase 1.
+func (c *config) phase2Start(next uint64) (uint64, error) {
+	if c.phase2Block < 0 {
+		return next, nil
+	}
+	if uint64(c.phase2Block) < next {
+		return 0, fmt.Errorf("phase 2 block offset %d overlaps phase 1, which committed blocks up to %d", c.phase2Block, next-1)
+	}
+	return uint64(c.phase2Block), nil
+}
+
+// params returns the workload parameters recorded into the report.
+func (c *config) params() runParams {
+	return runParams{
+		Accounts: c.accounts,
+		Slots:    c.slots,
+		Modify:   c.modify,
+		Workload: c.workload,
+		Batch:    c.batch,
+		Workers:  c.workers,
+	}
+}
+
+// parseRoot parses an optional hex encoded root hash, returning nil if none
+// was given.
+func parseRoot(s string) (*common.Hash, error) {
+	if s == "" {
+		return nil, nil
+	}
+	blob, err := hexutil.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) != common.HashLength {
+		return nil, fmt.Errorf("invalid root length %d", len(blob))
+	}
+	root := common.BytesToHash(blob)
+	return &root, nil
+}
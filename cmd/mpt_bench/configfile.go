@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/naoina/toml"
+)
+
+// configFlag is the name of the flag pointing to the configuration file, which
+// can't be set from within the file itself.
+const configFlag = "config"
+
+// applyConfigFile loads the TOML or JSON file at path, picked by extension, and
+// sets the flags of fs named by its keys. Flags already set on the command line
+// take precedence over the file, unknown keys are rejected.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]interface{})
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(blob))
+		dec.UseNumber()
+		err = dec.Decode(&values)
+	} else {
+		err = toml.Unmarshal(blob, &values)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	// Apply the keys in order, so that the first bad key is always reported
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		if key == configFlag || fs.Lookup(key) == nil {
+			errs = append(errs, fmt.Errorf("unknown key %q", key))
+			continue
+		}
+		if explicit[key] {
+			continue
+		}
+		switch value := values[key].(type) {
+		case string, bool, int64, float64, json.Number:
+			if err := fs.Set(key, fmt.Sprint(value)); err != nil {
+				errs = append(errs, fmt.Errorf("key %q: %v", key, err))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("key %q: unsupported value %v", key, value))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFlags() (*flag.FlagSet, *int, *string, *bool, *time.Duration) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	n := fs.Int("n", 100, "")
+	scheme := fs.String("scheme", "path", "")
+	dryRun := fs.Bool("dry-run", false, "")
+	duration := fs.Duration("duration", 0, "")
+	fs.String(configFlag, "", "")
+	return fs, n, scheme, dryRun, duration
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"bench.toml": "n = 5000\nscheme = \"hash\"\ndry-run = true\nduration = \"10m\"\n",
+		"bench.json": `{"n": 5000, "scheme": "hash", "dry-run": true, "duration": "10m"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fs, n, scheme, dryRun, duration := newTestFlags()
+		if err := fs.Parse([]string{"-scheme", "path"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyConfigFile(fs, path); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if *n != 5000 || !*dryRun || *duration != 10*time.Minute {
+			t.Errorf("%s: file values not applied: n=%d dry-run=%v duration=%v", name, *n, *dryRun, *duration)
+		}
+		if *scheme != "path" {
+			t.Errorf("%s: command line not preferred: scheme=%s", name, *scheme)
+		}
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"accounts = 1\n":        "unknown key",
+		"config = \"x.toml\"\n": "unknown key",
+		"n = \"many\"\n":        `key "n"`,
+		"n = [1, 2]\n":          "unsupported value",
+	}
+	for content, want := range tests {
+		path := filepath.Join(t.TempDir(), "bench.toml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fs, _, _, _, _ := newTestFlags()
+		err := applyConfigFile(fs, path)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: have error %v, want %q", content, err, want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// crashMarker records the batches committed before an injected crash, so that
+// the recovery run knows which roots it may find and which one it should.
+type crashMarker struct {
+	Scheme    string       `json:"scheme"`
+	CapLayers int          `json:"capLayers,omitempty"`
+	Batches   []crashBatch `json:"batches"`
+}
+
+// crashBatch is a batch of phase 1 committed before the crash.
+type crashBatch struct {
+	Root     common.Hash `json:"root"`
+	Accounts int         `json:"accounts"` // Accounts created up to the batch
+	Slots    int64       `json:"slots"`    // Slots created up to the batch
+}
+
+// crashMarkerPath returns the path of the crash marker of the database at
+// dbPath. It is kept next to the database, so it is not part of its size.
+func crashMarkerPath(dbPath string) string {
+	return dbPath + ".crash.json"
+}
+
+// crash kills the process right after the batches of phase committed so far,
+// without journaling, flushing or closing anything, leaving the databases as a
+// crashed node would. The committed roots are written into the crash marker
+// first, for the recovery run to check against. It only returns on failing to
+// write the marker.
+func (b *bench) crash(phase *phaseResult) error {
+	marker := &crashMarker{Scheme: b.trieDB.Scheme(), CapLayers: b.cfg.capLayers}
+	for _, batch := range phase.Batches {
+		marker.Batches = append(marker.Batches, crashBatch{Root: batch.Root, Accounts: batch.Accounts, Slots: batch.Slots})
+	}
+	blob, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode crash marker: %v", err)
+	}
+	path := crashMarkerPath(b.cfg.dbPath)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create crash marker: %v", err)
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write crash marker: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write crash marker: %v", err)
+	}
+	f.Close()
+
+	fmt.Fprintf(b.out, "\nInjecting crash after batch %d at root %x, recover with: -recover -clear=false -db %s\n", len(phase.Batches), b.root, b.cfg.dbPath)
+	os.Exit(exitCrashed)
+	return nil
+}
+
+// recoverResult is the report of a recovery run.
+type recoverResult struct {
+	DBPath      string        `json:"dbPath"`
+	Scheme      string        `json:"scheme"`
+	Open        time.Duration `json:"openNs"`   // Time taken to open the databases after the crash
+	Search      time.Duration `json:"searchNs"` // Time taken to find the recovered root
+	Expected    common.Hash   `json:"expectedRoot"`
+	Recovered   common.Hash   `json:"recoveredRoot"`
+	Recoverable bool          `json:"recoverable"`
+	Empty       bool          `json:"emptyFallback,omitempty"` // Whether the database fell back to the empty state
+	Batches     int           `json:"committedBatches"`        // Batches committed before the crash
+	Lost        int           `json:"lostBatches"`
+	LostAccts   int           `json:"lostAccounts"`
+	LostSlots   int64         `json:"lostSlots"`
+	Verify      *verifyResult `json:"verify,omitempty"` // Trie integrity check of the recovered root
+}
+
+// runRecover reopens the databases left behind by a run killed with
+// -inject-crash-after and looks for the newest of the roots committed before
+// the crash that is still readable, reporting how many batches were lost.
+func runRecover(cfg *config, out io.Writer) (*recoverResult, error) {
+	path := crashMarkerPath(cfg.dbPath)
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crash marker, was the database left by -inject-crash-after? %v", err)
+	}
+	var marker crashMarker
+	if err := json.Unmarshal(blob, &marker); err != nil {
+		return nil, fmt.Errorf("invalid crash marker %s: %v", path, err)
+	}
+	if len(marker.Batches) == 0 {
+		return nil, fmt.Errorf("crash marker %s holds no batches", path)
+	}
+	if marker.Scheme != cfg.scheme {
+		return nil, fmt.Errorf("database crashed in %s mode, recover it with -scheme %s", marker.Scheme, marker.Scheme)
+	}
+	fmt.Fprintf(out, "Recovering %s state at %s (crashed after %d batches)...\n", marker.Scheme, cfg.dbPath, len(marker.Batches))
+
+	start := time.Now()
+	stores, err := openStores(cfg, cfg.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer stores.diskdb.Close()
+
+	var (
+		last = marker.Batches[len(marker.Batches)-1]
+		res  = &recoverResult{
+			DBPath:   cfg.dbPath,
+			Scheme:   marker.Scheme,
+			Open:     time.Since(start),
+			Expected: last.Root,
+			Batches:  len(marker.Batches),
+		}
+		recovered *crashBatch
+	)
+	// Only the newest state flushed to disk survives a crash in path mode,
+	// walk the batches back until one of their roots opens
+	start = time.Now()
+	for i := len(marker.Batches) - 1; i >= 0; i-- {
+		if _, err := openState(stores.sdb, marker.Batches[i].Root, true); err == nil {
+			recovered = &marker.Batches[i]
+			res.Lost = len(marker.Batches) - 1 - i
+			break
+		}
+	}
+	res.Search = time.Since(start)
+	if recovered == nil {
+		res.Lost = len(marker.Batches)
+		res.LostAccts, res.LostSlots = last.Accounts, last.Slots
+		if _, err := openState(stores.sdb, types.EmptyRootHash, true); err == nil {
+			res.Empty = true
+		}
+		return res, nil
+	}
+	res.Recoverable = true
+	res.Recovered = recovered.Root
+	res.LostAccts = last.Accounts - recovered.Accounts
+	res.LostSlots = last.Slots - recovered.Slots
+
+	if cfg.verifyTrie {
+		fmt.Fprintf(out, "Verifying trie at root %x...\n", res.Recovered)
+		b := &bench{stores: *stores, cfg: cfg, out: out, progress: newProgress(cfg, out), root: res.Recovered}
+		if res.Verify, err = b.verifyTrie(); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(out)
+	}
+	return res, nil
+}
+
+// print writes the recovery report into w in the requested format.
+func (r *recoverResult) print(w io.Writer, format string) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	fmt.Fprintf(w, "\n--- Recovery Report ---\n")
+	fmt.Fprintf(w, "Database Path:  %s\n", r.DBPath)
+	fmt.Fprintf(w, "State Scheme:   %s\n", r.Scheme)
+	fmt.Fprintf(w, "Open Time:      %v (root found in %v)\n", r.Open, r.Search)
+	fmt.Fprintf(w, "Pre-Crash Root: %x (batch %d)\n", r.Expected, r.Batches)
+	switch {
+	case r.Empty:
+		fmt.Fprintf(w, "Recovered Root: none of the %d committed roots is readable, fell back to the empty state\n", r.Batches)
+	case !r.Recoverable:
+		fmt.Fprintf(w, "Recovered Root: none of the %d committed roots is readable\n", r.Batches)
+	default:
+		fmt.Fprintf(w, "Recovered Root: %x (batch %d)\n", r.Recovered, r.Batches-r.Lost)
+	}
+	if r.Lost == 0 {
+		fmt.Fprintf(w, "Data Lost:      none, the pre-crash root was recovered\n")
+	} else {
+		fmt.Fprintf(w, "Data Lost:      %d batches, %d accounts, %d slots\n", r.Lost, r.LostAccts, r.LostSlots)
+	}
+	printVerify(w, r.Verify)
+	return nil
+}
+
+// exitCode implements report, failing unless the pre-crash root was recovered.
+func (r *recoverResult) exitCode() int {
+	if r.Lost > 0 {
+		return 1
+	}
+	return 0
+}
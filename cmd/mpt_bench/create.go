@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+)
+
+// creationSeed is the fixed seed of the creation workload, borrowed from the
+// C# version for deterministic benchmarking.
+const creationSeed = 42
+
+// accountGenerator produces the deterministic contents of the accounts created
+// in phase 1.
+type accountGenerator struct {
+	cfg *config
+	r   *rand.Rand // Source of the slot counts and values
+	// The code is drawn from its own source, keeping the slots identical
+	// regardless of the code settings.
+	rCode *rand.Rand
+
+	values *accountValues // Balance and nonce drawer, nil if fixed
+	budget *slotBudget    // Cap of the total slots, nil if uncapped
+}
+
+func newAccountGenerator(cfg *config) *accountGenerator {
+	return &accountGenerator{
+		cfg:    cfg,
+		r:      cfg.rng.newRand("creation", creationSeed),
+		rCode:  cfg.rng.newRand("code", creationSeed+1),
+		values: newAccountValues(cfg),
+		budget: newSlotBudget(cfg),
+	}
+}
+
+// write populates the i-th account in statedb, writing its slots into storage,
+// and returns the number of slots created and whether it was given code. The
+// accounts must be written in order.
+func (g *accountGenerator) write(statedb *state.StateDB, storage storageWriter, i int, values *valueStats) (int, bool) {
+	addr := accountAddress(g.cfg.addrMode, i)
+	contract := g.cfg.codeSize > 0 && g.rCode.Float64() < g.cfg.contractRatio
+
+	balance, nonce := g.values.draw(i, contract)
+	statedb.SetBalance(addr, balance, tracing.BalanceChangeUnspecified)
+	statedb.SetNonce(addr, nonce, tracing.NonceChangeUnspecified)
+	if contract {
+		code := make([]byte, g.cfg.codeSize)
+		g.rCode.Read(code)
+		statedb.SetCode(addr, code, tracing.CodeChangeUnspecified)
+	}
+	vSlots := g.budget.take(slotCount(g.cfg, g.r))
+
+	// Include account index i to ensure slots are unique across different accounts
+	keys := slotKeys(i, vSlots, g.cfg.workers)
+	vals := slotValues(g.r, vSlots)
+	if g.cfg.batchSlots {
+		// The account is new, all the slots are written over empty ones
+		kv := make(map[common.Hash]common.Hash, vSlots)
+		for j := 0; j < vSlots; j++ {
+			kv[keys[j]] = vals[j]
+			values.add(common.Hash{}, vals[j])
+		}
+		statedb.SetStorageBatch(addr, kv)
+		return vSlots, contract
+	}
+	for j := 0; j < vSlots; j++ {
+		values.add(storage.SetState(addr, keys[j], vals[j]), vals[j])
+	}
+	return vSlots, contract
+}
+
+// createAccounts populates the configured number of accounts along with their
+// storage slots, committing them every batch. If a time budget is configured,
+// accounts are created until it elapses instead, stopping at the first batch
+// boundary past the deadline. Likewise with a target size, until the database
+// reaches it, the compactions of the target excluded from the phase.
+func (b *bench) createAccounts(ctx context.Context, phase *phaseResult) error {
+	var (
+		cfg   = b.cfg
+		gen   = newAccountGenerator(cfg)
+		open  = cfg.duration > 0 || b.target != nil // Whether the account count is open-ended
+		slots int64
+		size  int64 // Disk usage after the last batch
+		start = time.Now()
+	)
+	elapsed := func() time.Duration {
+		if b.target != nil {
+			return time.Since(start) - b.target.Compaction
+		}
+		return time.Since(start)
+	}
+	b.batchStart = start
+	b.presize()
+	b.addrs = make([]common.Address, 0, cfg.accounts)
+	b.slotCounts = make([]int, 0, cfg.accounts)
+	phase.Values = new(valueStats)
+	phase.AcctValues = gen.values.summary()
+
+	for i := 0; open || i < cfg.accounts; i++ {
+		b.touchAccount(accountAddress(cfg.addrMode, i))
+		vSlots, contract := gen.write(b.statedb, b.storage(), i, phase.Values)
+		b.addrs = append(b.addrs, accountAddress(cfg.addrMode, i))
+		b.slotCounts = append(b.slotCounts, vSlots)
+		slots += int64(vSlots)
+		if contract {
+			phase.Contracts++
+		}
+		if phase.Growth != nil {
+			phase.Growth.observe(i+1, slots, start, false, b.growthSize())
+		}
+
+		interrupted := ctx.Err() != nil
+		last := !open && i+1 == cfg.accounts || interrupted
+		switch {
+		case cfg.duration > 0:
+			if (i+1)%10 == 0 {
+				b.progress.update(phase.Name, i+1, slots, "...processed %d accounts (%v/%v)", i+1, time.Since(start).Round(time.Second), cfg.duration)
+			}
+		case b.target != nil:
+			if (i+1)%10 == 0 {
+				b.progress.update(phase.Name, i+1, slots, "...processed %d accounts (%s)", i+1, b.target.progress(i+1, size, elapsed()))
+			}
+		case (i+1)%10 == 0 || last:
+			b.progress.update(phase.Name, i+1, slots, "...processed %d/%d accounts (%.1f%%)", i+1, cfg.accounts, float64(i+1)/float64(cfg.accounts)*100)
+		}
+
+		// Periodic commit to keep memory usage low
+		if (i+1)%cfg.batch == 0 || last {
+			sample, err := b.commit(phase, uint64(i/cfg.batch), i+1, slots)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b.out, "\n[Batch %d] Root: %.8s | %s\n", sample.Batch, sample.Root.String(), b.usage(sample))
+			size = sample.DiskSize
+			b.presize()
+			if b.load != nil {
+				b.load.toggle() // Alternate the batches with and without readers
+			}
+			if sample.Batch == cfg.crashAfter {
+				return b.crash(phase)
+			}
+
+			if interrupted {
+				phase.Accounts = len(b.addrs)
+				if phase.Growth != nil {
+					phase.Growth.observe(phase.Accounts, slots, start, true, b.growthSize())
+				}
+				phase.finish(slots, elapsed(), b.root)
+				return errInterrupted
+			}
+			if cfg.duration > 0 && time.Since(start) >= cfg.duration {
+				break
+			}
+			if b.target != nil {
+				done, err := b.target.reached(b, size)
+				if err != nil {
+					return err
+				}
+				if done {
+					break
+				}
+				b.batchStart = time.Now() // Leave the compaction out of the next batch
+			}
+		}
+	}
+	if cfg.crashAfter > 0 {
+		return fmt.Errorf("phase 1 finished after %d batches, before the crash injected after batch %d", len(phase.Batches), cfg.crashAfter)
+	}
+	phase.Accounts = len(b.addrs)
+	if phase.Growth != nil {
+		phase.Growth.observe(phase.Accounts, slots, start, true, b.growthSize())
+	}
+	phase.finish(slots, elapsed(), b.root)
+	return nil
+}
+
+// presize pre-sizes the maps of the statedb to the accounts and slots of a
+// creation batch if requested, the commits open a new statedb every batch.
+// Flat storage writes the slots past the statedb, only the accounts count.
+func (b *bench) presize() {
+	if !b.cfg.prealloc {
+		return
+	}
+	slots := b.cfg.slots
+	if b.flat != nil {
+		slots = 0
+	}
+	b.statedb.SetSizeHint(b.cfg.batch, slots)
+}
+
+// replayCreation reconstructs the accounts and slot counts of the creation
+// phase without writing anything, by drawing the same sequence from the seeded
+// random source. It is used when resuming from an already populated database.
+func (b *bench) replayCreation() {
+	var (
+		r      = b.cfg.rng.newRand("creation", creationSeed)
+		budget = newSlotBudget(b.cfg)
+	)
+	b.addrs = make([]common.Address, b.cfg.accounts)
+	b.slotCounts = make([]int, b.cfg.accounts)
+	for i := 0; i < b.cfg.accounts; i++ {
+		b.addrs[i] = accountAddress(b.cfg.addrMode, i)
+		b.slotCounts[i] = budget.take(slotCount(b.cfg, r))
+		slotValues(r, b.slotCounts[i])
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+)
+
+// csvHeader is the list of columns written for every committed batch.
+var csvHeader = []string{
+	"phase",
+	"batch_index",
+	"block_number",
+	"accounts_processed",
+	"slots_written",
+	"disk_bytes",
+	"mem_alloc_bytes",
+	"batch_duration_ms",
+	"cumulative_root",
+}
+
+// csvSysHeader is the list of columns appended with -sysstat.
+var csvSysHeader = []string{
+	"rss_bytes",
+	"cpu_percent",
+	"disk_read_bytes",
+	"disk_write_bytes",
+}
+
+// csvWriter emits one row per committed batch. Every row is flushed right away
+// so that a crashed run still leaves the partial data behind.
+type csvWriter struct {
+	file   *os.File
+	w      *csv.Writer
+	noDisk bool // Leave the disk column empty, nothing is written there in dry-run mode
+	sys    bool // Append the resource usage columns of the process
+}
+
+// newCSVWriter creates the file at path and writes the header row into it.
+// If noDisk is set, the disk size column is left empty, if sys is set the
+// resource usage columns are appended.
+func newCSVWriter(path string, noDisk, sys bool) (*csvWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &csvWriter{file: file, w: csv.NewWriter(file), noDisk: noDisk, sys: sys}
+	header := csvHeader
+	if sys {
+		header = append(header[:len(header):len(header)], csvSysHeader...)
+	}
+	if err := c.writeRow(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// write appends the sample of a batch committed in the given phase.
+func (c *csvWriter) write(phase string, s batchSample) error {
+	disk := strconv.FormatInt(s.DiskSize, 10)
+	if c.noDisk {
+		disk = ""
+	}
+	row := []string{
+		phase,
+		strconv.Itoa(s.Batch),
+		strconv.FormatUint(s.Block, 10),
+		strconv.Itoa(s.Accounts),
+		strconv.FormatInt(s.Slots, 10),
+		disk,
+		strconv.FormatUint(s.MemAlloc, 10),
+		strconv.FormatFloat(float64(s.Duration.Microseconds())/1000, 'f', 3, 64),
+		s.Root.Hex(),
+	}
+	if c.sys {
+		var sys sysSample
+		if s.Sys != nil {
+			sys = *s.Sys
+		}
+		row = append(row,
+			strconv.FormatUint(sys.RSS, 10),
+			strconv.FormatFloat(cpuPercent(sys.CPU, sys.Wall), 'f', 1, 64),
+			strconv.FormatUint(sys.Read, 10),
+			strconv.FormatUint(sys.Write, 10),
+		)
+	}
+	return c.writeRow(row)
+}
+
+func (c *csvWriter) writeRow(row []string) error {
+	if err := c.w.Write(row); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Close flushes any buffered data and closes the underlying file.
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.file.Close()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/holiman/uint256"
+)
+
+const (
+	deleteModeSelfDestruct = "selfdestruct"
+	deleteModeEmptyAccount = "emptyaccount"
+)
+
+// deleteAccounts removes m randomly picked accounts from the state, committing
+// them in the usual batches. Depending on the configured mode, the accounts are
+// either self-destructed, letting the state wipe the storage, or emptied by
+// clearing every slot and account field manually.
+//
+// The slot range cleared in emptyaccount mode covers both the slots created in
+// phase 1 and the ones phase 2 may have added on top. Selfdestruct mode counts
+// the slots written in phase 1 instead, as the storage is wiped without being
+// read.
+func (b *bench) deleteAccounts(ctx context.Context, phase *phaseResult, m int) error {
+	var (
+		r       = b.cfg.rng.newRand("delete", 42)
+		perm    = r.Perm(len(b.addrs))
+		cleared int64
+		start   = time.Now()
+	)
+	b.batchStart = start
+	for i := 0; i < m; i++ {
+		accountIdx := perm[i]
+		addr := b.addrs[accountIdx]
+
+		switch b.cfg.deleteMode {
+		case deleteModeSelfDestruct:
+			b.statedb.SelfDestruct(addr)
+			cleared += int64(b.slotCounts[accountIdx])
+		case deleteModeEmptyAccount:
+			slots := max(b.slotCounts[accountIdx], b.cfg.slots)
+			for j := 0; j < slots; j++ {
+				key := slotKey(accountIdx, j)
+				if b.statedb.GetState(addr, key) != (common.Hash{}) {
+					b.statedb.SetState(addr, key, common.Hash{})
+					cleared++
+				}
+			}
+			b.statedb.SetBalance(addr, new(uint256.Int), tracing.BalanceChangeUnspecified)
+			b.statedb.SetNonce(addr, 0, tracing.NonceChangeUnspecified)
+		}
+		interrupted := ctx.Err() != nil
+		if (i+1)%10 == 0 || i+1 == m {
+			b.progress.update(phase.Name, i+1, cleared, "...deleted %d/%d accounts (%.1f%%)", i+1, m, float64(i+1)/float64(m)*100)
+		}
+		if (i+1)%b.cfg.batch == 0 || i+1 == m || interrupted {
+			// EIP-158: drop the emptied accounts from the trie as well, the
+			// self-destructed ones are removed regardless.
+			b.statedb.Finalise(true)
+
+			sample, err := b.commit(phase, b.next, i+1, cleared)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b.out, "\n[Delete Batch] %s\n", b.usage(sample))
+
+			if interrupted {
+				phase.Accounts = i + 1
+				phase.finish(cleared, time.Since(start), b.root)
+				return errInterrupted
+			}
+		}
+	}
+	phase.finish(cleared, time.Since(start), b.root)
+	return nil
+}
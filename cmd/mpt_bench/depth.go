@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// depthStats is the distribution of the leaf depths of one or more tries, in
+// nibbles, i.e. the length of the path leading to the node holding the leaf.
+// Every level deeper costs one more node read per lookup.
+type depthStats struct {
+	Leaves    int64   `json:"leaves"`
+	Min       int     `json:"min"`
+	Avg       float64 `json:"avg"`
+	Max       int     `json:"max"`
+	Histogram []int64 `json:"histogram"` // Leaves per depth, indexed by the depth
+}
+
+// add counts a leaf at the given depth.
+func (s *depthStats) add(depth int) {
+	if s.Leaves == 0 || depth < s.Min {
+		s.Min = depth
+	}
+	s.Max = max(s.Max, depth)
+	for len(s.Histogram) <= depth {
+		s.Histogram = append(s.Histogram, 0)
+	}
+	s.Histogram[depth]++
+	s.Avg += (float64(depth) - s.Avg) / float64(s.Leaves+1)
+	s.Leaves++
+}
+
+// depthReport contains the leaf depths of the account trie and, if reported,
+// of all the storage tries at the final root.
+type depthReport struct {
+	Accounts *depthStats `json:"accounts"`
+	Storage  *depthStats `json:"storage,omitempty"`
+	Tries    int         `json:"storageTries,omitempty"` // Storage tries iterated
+}
+
+// leafDepths iterates the account trie at root, returning the distribution of
+// the depths of its leaves.
+func leafDepths(trieDB *triedb.Database, root common.Hash) (*depthStats, error) {
+	depths := new(depthStats)
+	if err := addLeafDepths(trieDB, trie.StateTrieID(root), depths); err != nil {
+		return nil, fmt.Errorf("account trie: %v", err)
+	}
+	return depths, nil
+}
+
+// addLeafDepths iterates the trie identified by id, counting the depth of
+// every leaf into depths.
+func addLeafDepths(trieDB *triedb.Database, id *trie.ID, depths *depthStats) error {
+	tr, err := trie.NewStateTrie(id, trieDB)
+	if err != nil {
+		return fmt.Errorf("failed to open trie: %v", err)
+	}
+	it, err := tr.NodeIterator(nil)
+	if err != nil {
+		return fmt.Errorf("failed to iterate trie: %v", err)
+	}
+	parent := 0 // Path length of the last node visited, the one holding the next leaf
+	for it.Next(true) {
+		if it.Leaf() {
+			depths.add(parent)
+			continue
+		}
+		parent = len(it.Path())
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("failed to iterate trie: %v", err)
+	}
+	return nil
+}
+
+// storageDepths iterates the storage tries of all the accounts created at the
+// final root, returning the distribution of the depths of their leaves.
+func (b *bench) storageDepths(report *depthReport) error {
+	statedb, err := openState(b.sdb, b.root, false)
+	if err != nil {
+		return err
+	}
+	report.Storage = new(depthStats)
+	for i, addr := range b.addrs {
+		storageRoot := statedb.GetStorageRoot(addr)
+		if storageRoot == types.EmptyRootHash || storageRoot == (common.Hash{}) {
+			continue
+		}
+		id := trie.StorageTrieID(b.root, crypto.Keccak256Hash(addr.Bytes()), storageRoot)
+		if err := addLeafDepths(b.trieDB, id, report.Storage); err != nil {
+			return fmt.Errorf("storage trie %x of %x: %v", storageRoot, addr, err)
+		}
+		report.Tries++
+		if (i+1)%1000 == 0 || i+1 == len(b.addrs) {
+			b.progress.update("depths", 0, report.Storage.Leaves, "...iterated the storage tries of %d/%d accounts (%.1f%%)", i+1, len(b.addrs), float64(i+1)/float64(len(b.addrs))*100)
+		}
+	}
+	return nil
+}
+
+// printDepths writes the leaf depth distributions into w, if reported.
+func printDepths(w io.Writer, r *depthReport) {
+	if r == nil {
+		return
+	}
+	printStats := func(name string, s *depthStats) {
+		if s == nil || s.Leaves == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s min %d | avg %.2f | max %d nibbles over %d leaves\n", name, s.Min, s.Avg, s.Max, s.Leaves)
+		for depth := s.Min; depth <= s.Max; depth++ {
+			share := float64(s.Histogram[depth]) / float64(s.Leaves)
+			bar := strings.Repeat("#", int(math.Round(share*40)))
+			fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("               %2d: %10d %5.1f%% %s", depth, s.Histogram[depth], share*100, bar), " "))
+		}
+	}
+	printStats("Account Depth:", r.Accounts)
+	if r.Storage != nil {
+		printStats(fmt.Sprintf("Storage Depth: %d tries,", r.Tries), r.Storage)
+	}
+}
+
+// largestTrie describes the account with the most slots created by the run,
+// the one with the largest storage trie.
+type largestTrie struct {
+	Address common.Address `json:"address"`
+	Slots   int            `json:"slots"`
+	Nodes   int            `json:"storageNodes,omitempty"` // Nodes of the storage trie at the final root, if counted
+}
+
+// largestAccount returns the account with the most slots among addrs, nil if
+// none has any. Ties go to the first account.
+func largestAccount(addrs []common.Address, slotCounts []int) *largestTrie {
+	var largest *largestTrie
+	for i, slots := range slotCounts {
+		if slots > 0 && (largest == nil || slots > largest.Slots) {
+			largest = &largestTrie{Address: addrs[i], Slots: slots}
+		}
+	}
+	return largest
+}
+
+// countStorageNodes returns the number of nodes stored in the storage tries of
+// the given accounts at root, leaving out the nodes embedded in their parent.
+func (b *bench) countStorageNodes(root common.Hash, addrs map[common.Address]struct{}) (int, error) {
+	statedb, err := openState(b.sdb, root, false)
+	if err != nil {
+		return 0, err
+	}
+	var nodes int
+	for addr := range addrs {
+		storageRoot := statedb.GetStorageRoot(addr)
+		if storageRoot == types.EmptyRootHash || storageRoot == (common.Hash{}) {
+			continue
+		}
+		owner := crypto.Keccak256Hash(addr.Bytes())
+		tr, err := trie.NewStateTrie(trie.StorageTrieID(root, owner, storageRoot), b.trieDB)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open storage trie %x of %x: %v", storageRoot, addr, err)
+		}
+		it, err := tr.NodeIterator(nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate storage trie %x of %x: %v", storageRoot, addr, err)
+		}
+		for it.Next(true) {
+			if it.Hash() != (common.Hash{}) {
+				nodes++
+			}
+		}
+		if err := it.Error(); err != nil {
+			return 0, fmt.Errorf("failed to iterate storage trie %x of %x: %v", storageRoot, addr, err)
+		}
+	}
+	return nodes, nil
+}
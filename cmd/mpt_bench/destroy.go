@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	destroyModeZero = "zero" // Every slot is written zero, pruning the trie leaf by leaf
+	destroyModeWipe = "wipe" // The storage is dropped at once, like a destructed account recreated
+)
+
+// destroyStats contains where the storage tries destroyed by the storage
+// destruction phase went, freed from the key-value store or merely recorded
+// as deleted in the state history.
+type destroyStats struct {
+	Mode           string `json:"mode"`
+	StoreReclaimed int64  `json:"storeReclaimedBytes"` // Key-value store shrinkage, negative if it grew
+	HistoryGrowth  int64  `json:"historyGrowthBytes"`  // Growth of the state history freezer
+	Checked        int    `json:"checked,omitempty"`   // Accounts whose storage root node was looked up on disk, path mode only
+	OnDisk         int    `json:"onDisk,omitempty"`    // Of which the root node is still in the key-value store
+}
+
+// destroyStorage clears the whole storage of m randomly picked accounts,
+// committing them in the usual batches. Unlike a deletion the accounts remain,
+// keeping their balance, nonce and code. In zero mode every slot is read and
+// written zero, in wipe mode the storage is replaced by an empty one without
+// being read, taking the path of a destructed account recreated within the
+// block, which deletes the storage trie as a whole on commit.
+//
+// The slots cleared in zero mode are the nonzero ones found. Wipe mode counts
+// the slots written in phase 1 instead, an upper bound including the zero
+// values and ignoring the changes of phase 2.
+func (b *bench) destroyStorage(ctx context.Context, phase *phaseResult, m int) ([]common.Address, error) {
+	var (
+		r       = b.cfg.rng.newRand("destroy", 43)
+		perm    = r.Perm(len(b.addrs))
+		addrs   = make([]common.Address, 0, m)
+		cleared int64
+		start   = time.Now()
+	)
+	b.batchStart = start
+	for i := 0; i < m; i++ {
+		accountIdx := perm[i]
+		addr := b.addrs[accountIdx]
+
+		switch b.cfg.destroyMode {
+		case destroyModeZero:
+			slots := max(b.slotCounts[accountIdx], b.cfg.slots)
+			for j := 0; j < slots; j++ {
+				key := slotKey(accountIdx, j)
+				if b.statedb.GetState(addr, key) != (common.Hash{}) {
+					b.statedb.SetState(addr, key, common.Hash{})
+					cleared++
+				}
+			}
+		case destroyModeWipe:
+			b.statedb.SetStorage(addr, nil)
+			cleared += int64(b.slotCounts[accountIdx])
+		}
+		// Keep the later reads and proofs away from the cleared slots
+		b.slotCounts[accountIdx] = 0
+		addrs = append(addrs, addr)
+
+		interrupted := ctx.Err() != nil
+		if (i+1)%10 == 0 || i+1 == m {
+			b.progress.update(phase.Name, i+1, cleared, "...destroyed the storage of %d/%d accounts (%.1f%%)", i+1, m, float64(i+1)/float64(m)*100)
+		}
+		if (i+1)%b.cfg.batch == 0 || i+1 == m || interrupted {
+			sample, err := b.commit(phase, b.next, i+1, cleared)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(b.out, "\n[Destroy Batch] %s\n", b.usage(sample))
+
+			if interrupted {
+				phase.Accounts = i + 1
+				phase.finish(cleared, time.Since(start), b.root)
+				return addrs, errInterrupted
+			}
+		}
+	}
+	phase.finish(cleared, time.Since(start), b.root)
+	return addrs, nil
+}
+
+// storeSizes returns the size of the key-value store and of the state history
+// freezer of the database.
+func (b *bench) storeSizes() (int64, int64) {
+	ancient := getDirSize(filepath.Join(b.cfg.dbPath, "ancient"))
+	return getDirSize(b.cfg.dbPath) - ancient, ancient
+}
+
+// countStorageRoots returns how many of the storage root nodes of addrs are
+// still in the key-value store. Path mode deletes the nodes of a destroyed
+// storage trie once the diff layers and the write buffer are flushed into the
+// disk layer, until then they remain on disk.
+func (b *bench) countStorageRoots(addrs []common.Address) int {
+	var found int
+	for _, addr := range addrs {
+		if rawdb.HasStorageTrieNode(b.diskdb, crypto.Keccak256Hash(addr.Bytes()), nil) {
+			found++
+		}
+	}
+	return found
+}
+
+// printDestroy writes where the destroyed storage went into w. The hash scheme
+// never deletes trie nodes, the ones destroyed are left for pruning.
+func printDestroy(w io.Writer, s *destroyStats, scheme string) {
+	if s == nil {
+		return
+	}
+	fmt.Fprintf(w, "Key-Value Store Reclaimed: %.2f MB | State History Growth: %.2f MB\n",
+		float64(s.StoreReclaimed)/1024/1024, float64(s.HistoryGrowth)/1024/1024)
+	switch {
+	case scheme == rawdb.HashScheme:
+		fmt.Fprintf(w, "Storage Nodes: kept on disk, the hash scheme never deletes trie nodes\n")
+	case s.OnDisk == 0:
+		fmt.Fprintf(w, "Storage Nodes: freed, none of the %d destroyed storage roots remains on disk\n", s.Checked)
+	default:
+		fmt.Fprintf(w, "Storage Nodes: %d of the %d destroyed storage roots still on disk, the deletions are pending in memory\n", s.OnDisk, s.Checked)
+	}
+}
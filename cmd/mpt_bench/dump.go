@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// dumpResult contains the totals of a key dump.
+type dumpResult struct {
+	Path     string  `json:"path"`
+	Sample   float64 `json:"samplePercent"`
+	Accounts int     `json:"accounts"`
+	Slots    int64   `json:"slots"`
+}
+
+// dumpKeys writes the addresses of the accounts created in phase 1 into path,
+// one hex encoded address per line. If slots is set, every address is followed
+// by lines of the address and one of its slot keys, separated by a space. Only
+// the slots holding a nonzero value are dumped, the zero ones don't exist in the
+// state. If sample is below 100, only that percentage of the accounts is dumped
+// along with their slots, chosen by the hash of the address so the same keys
+// are picked in every run.
+func (b *bench) dumpKeys(path string, sample float64, slots bool) (*dumpResult, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key dump: %v", err)
+	}
+	defer f.Close()
+
+	var (
+		w   = bufio.NewWriter(f)
+		res = &dumpResult{Path: path, Sample: sample}
+		r   = rand.New(rand.NewSource(creationSeed)) // Replays the slot values of phase 1
+	)
+	for i, addr := range b.addrs {
+		slotCount(b.cfg, r) // Drawn in full even if capped
+		vals := slotValues(r, b.slotCounts[i])
+		if !sampled(addr, sample) {
+			continue
+		}
+		fmt.Fprintf(w, "%#x\n", addr)
+		res.Accounts++
+		if !slots {
+			continue
+		}
+		for j, val := range vals {
+			if val == (common.Hash{}) {
+				continue
+			}
+			fmt.Fprintf(w, "%#x %#x\n", addr, slotKey(i, j))
+			res.Slots++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write key dump: %v", err)
+	}
+	return res, nil
+}
+
+// sampled reports whether the account at addr falls into the given percentage
+// of the accounts, deterministically.
+func sampled(addr common.Address, percent float64) bool {
+	if percent >= 100 {
+		return true
+	}
+	pos := binary.BigEndian.Uint64(crypto.Keccak256(addr.Bytes())[:8])
+	return float64(pos) < percent/100*math.MaxUint64
+}
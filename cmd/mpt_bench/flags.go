@@ -0,0 +1,277 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// cliFlags binds the flag set of a subcommand to the configuration it runs
+// with. The commands register the groups of flags relevant to them, most
+// flags are stored into cfg directly, while the ones needing further parsing
+// are kept raw until parse.
+type cliFlags struct {
+	fs  *flag.FlagSet
+	cfg *config
+
+	configFile    string
+	history       int64
+	expectRoot    string
+	expectModRoot string
+	resumeRoot    string
+	rootFlag      string // Name of the flag setting resumeRoot
+	iterateFrom   string
+	compare       string
+	compareFlag   string // Name of the flag setting compare
+	commitSnap    bool   // Compare the workload with and without snapshot commits
+	commitEach    bool   // Compare the workload committed in batches and after every account
+	benchfmt      bool   // Print the report as benchmark lines
+	mix           string
+}
+
+// newCLIFlags creates the flag set of the named command on top of the default
+// configuration, along with the flag pointing to a configuration file.
+func newCLIFlags(name string) *cliFlags {
+	f := &cliFlags{
+		fs:  flag.NewFlagSet(name, flag.ExitOnError),
+		cfg: defaultConfig(),
+	}
+	f.history = int64(f.cfg.history)
+	f.fs.StringVar(&f.configFile, configFlag, "", "Path of a TOML or JSON file setting any of the flags by name, the command line takes precedence")
+	return f
+}
+
+// stateFlags registers the flags shaping the accounts of phase 1, which are
+// also needed to find their keys again in an existing state.
+func (f *cliFlags) stateFlags() {
+	fs, cfg := f.fs, f.cfg
+	fs.IntVar(&cfg.accounts, "n", cfg.accounts, "Number of accounts to create")
+	fs.IntVar(&cfg.slots, "slots", cfg.slots, "Average number of slots per account, 0 for an account-only workload skipping phase 2")
+	fs.Int64Var(&cfg.totalSlots, "total-slots", cfg.totalSlots, "Stop adding slots once phase 1 wrote this many in total, whatever the slot distribution, the later accounts getting fewer or none (0: no cap)")
+	fs.IntVar(&cfg.codeSize, "code-size", cfg.codeSize, "Bytes of pseudo-random code per contract account (0: EOAs only)")
+	fs.Float64Var(&cfg.contractRatio, "contract-ratio", cfg.contractRatio, "Fraction of the accounts created as contracts when code is enabled")
+	fs.StringVar(&cfg.addrMode, "addr-mode", cfg.addrMode, "Derivation of the account addresses (hashed|sequential|prefixed), prefixed clusters the hashed trie keys under one nibble")
+	fs.StringVar(&cfg.slotDist, "slot-dist", cfg.slotDist, "Distribution of the slots per account created in phase 1 (uniform|normal|pareto)")
+	fs.Float64Var(&cfg.slotStddev, "slot-stddev", cfg.slotStddev, "Standard deviation of the normal slot distribution, as a fraction of -slots")
+	fs.Float64Var(&cfg.paretoAlpha, "pareto-alpha", cfg.paretoAlpha, "Shape of the pareto slot distribution (> 1), lower is more skewed")
+	fs.StringVar(&cfg.balanceDist, "balance-dist", cfg.balanceDist, "Distribution of the balances of the accounts created in phase 1 (fixed|random), fixed giving each 1 ether, random spreading them from 1 wei to ~1e28")
+	fs.StringVar(&cfg.nonceDist, "nonce-dist", cfg.nonceDist, "Distribution of the nonces of the accounts created in phase 1 (index|zero|random), index giving each its creation index")
+	fs.Float64Var(&cfg.zeroBalance, "zero-balance", cfg.zeroBalance, "Fraction of the accounts created in phase 1 without balance, whatever -balance-dist, empty by EIP-161 with a zero nonce and no code")
+}
+
+// writeFlags registers the flags of the write phases: creation, modification
+// and deletion.
+func (f *cliFlags) writeFlags() {
+	fs, cfg := f.fs, f.cfg
+	fs.DurationVar(&cfg.duration, "duration", cfg.duration, "Keep creating accounts until the time budget elapses, overrides -n (e.g. 10m)")
+	fs.Float64Var(&cfg.targetSize, "target-size-gb", cfg.targetSize, "Keep creating accounts until the compacted database reaches this size in GB, overrides -n (0: disabled)")
+	fs.BoolVar(&cfg.batchSlots, "batch-slots", cfg.batchSlots, "Write the slots of every account created in phase 1 with one StateDB.SetStorageBatch call instead of SetState per slot")
+	fs.BoolVar(&cfg.prealloc, "prealloc", cfg.prealloc, "Pre-size the StateDB account and storage maps of every phase 1 batch to -k accounts of -slots slots, sparing their repeated growth")
+	fs.IntVar(&cfg.modify, "m", cfg.modify, "Number of accounts to modify after creation")
+	fs.Int64Var(&cfg.phase2Block, "phase2-block-offset", cfg.phase2Block, "First block number of phase 2, later phases continue after its last block; overlapping the blocks of phase 1 is rejected (-1: right after phase 1)")
+	fs.IntVar(&cfg.blocks, "blocks", cfg.blocks, "Number of blocks to apply in phase 2 instead of modifying -m accounts, committing one root per block (0: disabled)")
+	fs.IntVar(&cfg.txsPerBlock, "txs-per-block", cfg.txsPerBlock, "Number of random slot writes per block in block mode, or of transactions with -workload mixed")
+	fs.StringVar(&cfg.workload, "workload", cfg.workload, "Shape of the phase 2 blocks (bulk|mixed), mixed executes transactions of randomly drawn operations")
+	fs.StringVar(&f.mix, "mix", cfg.mix.String(), "Relative weights of the operations of the mixed transactions")
+	fs.IntVar(&cfg.opsPerTx, "ops-per-tx", cfg.opsPerTx, "Number of operations per transaction with -workload mixed")
+	fs.Int64Var(&cfg.modSeed, "mod-seed", cfg.modSeed, "Seed of the random modifications in phase 2")
+	fs.StringVar(&cfg.modMode, "mod-mode", cfg.modMode, "Account fields updated by the phase 2 modifications (storage|balance|mixed), balance bumps the nonce and balance only, like simple transfers")
+	fs.Float64Var(&cfg.modDelete, "mod-delete-ratio", cfg.modDelete, "Fraction of the slot writes of phase 2 writing zero, deleting the slot from its storage trie")
+	fs.IntVar(&cfg.batch, "k", cfg.batch, "Number of accounts per commit/flush")
+	fs.IntVar(&cfg.workers, "workers", cfg.workers, "Number of goroutines deriving the slot keys")
+	fs.IntVar(&cfg.commitWorkers, "commit-workers", cfg.commitWorkers, "Number of storage tries hashed and committed concurrently within a commit (0: no limit)")
+	fs.IntVar(&cfg.warmup, "warmup", cfg.warmup, "Number of accounts to write before starting the measurements")
+	fs.IntVar(&cfg.readers, "concurrent-readers", cfg.readers, "Number of goroutines reading the last committed state during phase 1, active in every other batch (0: disabled)")
+	fs.IntVar(&cfg.reorgs, "reorgs", cfg.reorgs, "Number of chain reorgs applied after phase 2, each rolling back -reorg-depth batches and committing as many divergent ones")
+	fs.IntVar(&cfg.reorgDepth, "reorg-depth", cfg.reorgDepth, "Number of batches every reorg rolls back, the root must still be served by the diff layers or the state history")
+	fs.IntVar(&cfg.destroy, "destroy-storage", cfg.destroy, "Number of accounts whose whole storage is cleared after the reads, keeping the accounts themselves")
+	fs.StringVar(&cfg.destroyMode, "destroy-mode", cfg.destroyMode, "How the storage is cleared (zero|wipe), zero writes every slot zero, wipe drops the storage trie at once")
+	fs.IntVar(&cfg.delete, "delete", cfg.delete, "Number of accounts to delete after the other phases")
+	fs.StringVar(&cfg.deleteMode, "delete-mode", cfg.deleteMode, "How accounts are deleted (selfdestruct|emptyaccount)")
+	fs.StringVar(&cfg.dist, "dist", cfg.dist, "Distribution of the slots modified in phase 2 (uniform|zipf)")
+	fs.Float64Var(&cfg.zipfS, "zipf-s", cfg.zipfS, "Zipf distribution s parameter (> 1)")
+	fs.Float64Var(&cfg.zipfV, "zipf-v", cfg.zipfV, "Zipf distribution v parameter (>= 1)")
+	fs.StringVar(&cfg.replayPath, "replay", cfg.replayPath, "Path of a file of operations (SET_BALANCE addr wei, SET_STATE addr key value, DELETE addr, COMMIT) to apply instead of phases 1 and 2, -expect-root checking the final root")
+	fs.BoolVar(&cfg.continueOnError, "continue-on-error", cfg.continueOnError, "Log and skip the batches failing to commit, dropping their changes, instead of aborting the run, reporting the failures (e.g. near a full disk)")
+	fs.IntVar(&cfg.crashAfter, "inject-crash-after", cfg.crashAfter, "Kill the process without flushing anything after committing this many batches of phase 1 (0: disabled)")
+}
+
+// queryFlags registers the flags of the read phases run on the final state.
+func (f *cliFlags) queryFlags() {
+	fs, cfg := f.fs, f.cfg
+	fs.IntVar(&cfg.reads, "reads", cfg.reads, "Number of random slot reads to perform after modification")
+	fs.IntVar(&cfg.batchReads, "batch-reads", cfg.batchReads, "Repeat the random reads in batches of this many slots of one account, once with StateDB.GetStorageBatch per batch and once with GetState per slot, comparing their throughput (0: disabled)")
+	fs.BoolVar(&cfg.prewarm, "prewarm-cache", cfg.prewarm, "Run the random reads on cold caches first, then reopen the databases and read every slot of the read accounts once to fill the Pebble block cache and the trie database clean caches before the measured reads")
+	fs.IntVar(&cfg.historical, "read-historical", cfg.historical, "Repeat the random reads at the root committed this many commits before the final one, through the diff layers or the indexed state history of pathdb (0: disabled, indexes the state history if set)")
+	fs.IntVar(&cfg.proofs, "proofs", cfg.proofs, "Number of account and storage proofs to generate after the reads")
+	fs.BoolVar(&cfg.verify, "verify", cfg.verify, "Verify the generated proofs, timed separately")
+	fs.BoolVar(&cfg.proofMissing, "proof-missing", cfg.proofMissing, "Generate exclusion proofs of absent keys instead")
+	fs.BoolVar(&cfg.iterate, "iterate", cfg.iterate, "Iterate the account trie and every storage trie after the reads and proofs, timing the full state walk")
+	fs.StringVar(&f.iterateFrom, "iterate-from", "", "Hashed account key (prefix) to start the iteration at, as hex (e.g. 0x8f)")
+}
+
+// checkFlags registers the regression checks of the write phases.
+func (f *cliFlags) checkFlags() {
+	fs, cfg := f.fs, f.cfg
+	fs.StringVar(&f.expectRoot, "expect-root", "", "Expected state root after phase 1, the run fails on mismatch")
+	fs.StringVar(&f.expectModRoot, "expect-mod-root", "", "Expected state root after phase 2, the run fails on mismatch")
+	fs.BoolVar(&cfg.verifyTrie, "verify-trie", cfg.verifyTrie, "Iterate the full state after phases 1 and 2, checking the integrity of every trie node")
+	fs.BoolVar(&cfg.rehash, "full-rehash-check", cfg.rehash, "Rebuild the full trie from its accounts and slots after phase 2, failing unless it hashes to the incrementally maintained root")
+	fs.IntVar(&cfg.verifyMods, "verify-mods", cfg.verifyMods, "Number of the slot writes of phase 2 to sample and read back from the final root, failing on any lost write (0: disabled)")
+	fs.StringVar(&cfg.rngLogPath, "rng-log", cfg.rngLogPath, "Path of a file to log the draws of the workload's random sources into after every batch, for comparing the workloads of two builds")
+	fs.StringVar(&cfg.rngVerifyPath, "rng-verify", cfg.rngVerifyPath, "Path of a -rng-log file to compare the draws of the workload's random sources against, failing on the first divergence")
+	fs.IntVar(&cfg.zeroNoop, "assert-zero-noop", cfg.zeroNoop, "Number of absent slots to write zeroes into after phase 1, failing unless the root and the storage trie nodes stay unchanged (0: disabled)")
+}
+
+// storeFlags registers the flags needed to open a database, whether it is
+// created by the run or already exists.
+func (f *cliFlags) storeFlags() {
+	fs, cfg := f.fs, f.cfg
+	fs.StringVar(&cfg.dbPath, "db", cfg.dbPath, "Path to database")
+	fs.StringVar(&cfg.scheme, "scheme", cfg.scheme, "State scheme of the trie database (path|hash)")
+	fs.IntVar(&cfg.cacheMB, "cache-mb", cfg.cacheMB, "Size of the Pebble block cache in megabytes")
+	fs.StringVar(&cfg.compression, "compression", cfg.compression, "Compression of the Pebble tables (none|snappy|zstd)")
+	fs.StringVar(&cfg.keyScheme, "key-scheme", cfg.keyScheme, "Layout of the state trie keys (secure|raw): raw inserts the addresses and slots unhashed like the legacy non-secure trie, changing the trie depths and node sharing, and the roots. Raw only covers the tries, not the snapshots, preimages, proofs or iteration")
+	fs.StringVar(&cfg.insertOrder, "insert-order", cfg.insertOrder, "Order the changed slots are inserted into the storage tries in at every commit (random|sorted), sorted following the hashed slot keys")
+	fs.BoolVar(&cfg.noHashCache, "no-hash-cache", cfg.noHashCache, "Bypass the node hashes cached by the tries, rehashing every node in memory on each hashing to measure the cold hashing cost")
+	fs.BoolVar(&cfg.verkle, "verkle", cfg.verkle, "Experimental: build the state in the verkle mode of the trie database, failing if the build doesn't support it (requires -scheme path)")
+	fs.IntVar(&cfg.dirtyCacheMB, "dirty-cache-mb", cfg.dirtyCacheMB, "Size of the pathdb write buffer in megabytes")
+	fs.Float64Var(&cfg.batchMB, "ethdb-batch-mb", cfg.batchMB, "Size of the batches the hash scheme writes the committed trie nodes into the key-value store in, in megabytes (0: 100 KB batches, requires -scheme hash)")
+	fs.IntVar(&cfg.cleanCacheMB, "clean-cache-mb", cfg.cleanCacheMB, "Size of each of the pathdb clean trie and state caches in megabytes")
+	fs.BoolVar(&cfg.preimages, "preimages", cfg.preimages, "Record the preimages of the hashed trie keys, reporting the activity of their caches and the disk taken up by the preimage store apart from the trie nodes")
+	fs.IntVar(&cfg.preimageMB, "preimage-cache-mb", cfg.preimageMB, "Size of the preimages the trie database caches before flushing them with -preimages, in megabytes (0: flush on every commit)")
+	fs.IntVar(&cfg.secKeyLimit, "seckey-cache", cfg.secKeyLimit, "Number of key preimages a state trie caches before handing them to the trie database with -preimages (0: hold them until the commit)")
+	fs.Int64Var(&f.history, "history", f.history, "Number of recent blocks to keep state history for in path mode (0: keep all)")
+	fs.BoolVar(&cfg.archive, "archive", cfg.archive, "Retain the state history of every block in path mode, never pruning it, reporting its entries and bytes (overrides -history)")
+	fs.BoolVar(&cfg.freezer, "freezer-stats", cfg.freezer, "Measure the state history freezer of path mode apart from the live key-value store, reporting the histories appended and their append throughput, the items and bytes retained per table and the freezer file sizes")
+}
+
+// layoutFlags registers the flags deciding how the written state is laid out
+// and kept, on disk or in memory.
+func (f *cliFlags) layoutFlags() {
+	fs, cfg := f.fs, f.cfg
+	fs.BoolVar(&cfg.clear, "clear", cfg.clear, "Clear database before starting")
+	fs.BoolVar(&cfg.reopen, "reopen-between-phases", cfg.reopen, "Close and reopen the databases after phase 1, starting phase 2 with cold caches")
+	fs.BoolVar(&cfg.syncCommit, "sync-commit", cfg.syncCommit, "Sync the Pebble write-ahead log to disk on every write and batch flush, measuring the cost of durable commits")
+	fs.BoolVar(&cfg.noWAL, "no-wal", cfg.noWAL, "Disable the Pebble write-ahead log, the database is not crash-safe and must be discarded after the run")
+	fs.BoolVar(&cfg.flatStorage, "flat-storage", cfg.flatStorage, "Write the slots as flat key-values instead of into storage tries, building the account trie only")
+	fs.BoolVar(&cfg.dryRun, "dry-run", cfg.dryRun, "Only compute the roots in memory, never committing to disk")
+	fs.IntVar(&cfg.shards, "shards", cfg.shards, "Number of independent states to spread the accounts over, committed concurrently")
+	fs.IntVar(&cfg.instances, "instances", cfg.instances, "Number of states sharing one Pebble, each running the whole workload on its own goroutine")
+	fs.BoolVar(&cfg.snapshot, "snapshot", cfg.snapshot, "Generate and maintain a snapshot after phase 1 (requires -scheme hash)")
+	fs.IntVar(&cfg.capLayers, "cap-layers", cfg.capLayers, "Number of pathdb diff layers to keep in memory, flushing only once the buffer fills (0: flatten every batch)")
+	f.rootVar("resume-root", "Root of an existing state to continue modifying (requires -clear=false)")
+}
+
+// outputFlags registers the flags shaping the output of every command.
+func (f *cliFlags) outputFlags() {
+	fs, cfg := f.fs, f.cfg
+	fs.StringVar(&cfg.output, "output", cfg.output, "Output format of the final report (text|json|benchfmt), the json reports can be recorded into an SQLite database with cmd/mpt_bench/sqlite")
+	fs.BoolVar(&f.benchfmt, "benchfmt", false, "Print the final report as go test -bench lines, one per phase, for comparing runs with benchstat (shorthand for -output benchfmt)")
+	fs.StringVar(&cfg.tracePath, "trace", cfg.tracePath, "Path of a Go execution trace of the run to write, for go tool trace")
+	fs.StringVar(&cfg.metricsAddr, "metrics-addr", cfg.metricsAddr, "Address to serve the live progress of the run at as JSON over HTTP, updated after every batch (e.g. :6060, or unix:/path/to/socket)")
+	fs.BoolVar(&cfg.logJournal, "log-journal", cfg.logJournal, "Send the milestones of the write phases and the final summary to the systemd journal, tagged with MPT_* fields (phase, throughput, disk, root) to query with journalctl -t mpt_bench (linux with journald only)")
+	fs.DurationVar(&cfg.progressInterval, "progress-interval", cfg.progressInterval, "Emit a JSON progress record (accounts, slots, disk MB) to stderr at this interval instead of the progress lines (0 = print lines, rewritten in place on a terminal)")
+}
+
+// metricFlags registers the optional measurements and dumps of the write
+// phases.
+func (f *cliFlags) metricFlags() {
+	fs, cfg := f.fs, f.cfg
+	fs.BoolVar(&cfg.measureHash, "measure-intermediate", cfg.measureHash, "Time the trie hashing (IntermediateRoot) apart from the database writes of every commit")
+	fs.BoolVar(&cfg.measureOpen, "measure-open", cfg.measureOpen, "Time reopening the StateDB at the new root after every batch, reporting the distribution of the open times apart from the batch times")
+	fs.BoolVar(&cfg.detailed, "detailed-commit", cfg.detailed, "Time the steps of every commit (finalise, storage and account hashing, trie commit, trie database update and flush), reporting their share of the commit time")
+	fs.BoolVar(&cfg.noForcedGC, "no-forced-gc", cfg.noForcedGC, "Don't force a garbage collection after every batch, reporting the natural GC activity instead")
+	fs.BoolVar(&cfg.trackAccess, "track-access", cfg.trackAccess, "Keep an access list across every batch, reporting the cold and warm account and slot accesses and their EIP-2929 gas")
+	fs.IntVar(&cfg.maxHeapMB, "max-heap-mb", cfg.maxHeapMB, "Heap cap in megabytes checked after every batch: above it the buffered layers are flushed and a GC forced, aborting the run if the heap stays above (0 = disabled)")
+	fs.BoolVar(&cfg.growth, "growth-report", cfg.growth, "Sample the throughput and disk usage of phase 1 at 1k, 10k, 100k, ... accounts written, reporting how they evolve as the trie grows")
+	fs.BoolVar(&cfg.sysstat, "sysstat", cfg.sysstat, "Sample the resident memory, CPU time and bytes read from and written to storage by the process after every batch, reporting them per batch and per phase")
+	fs.BoolVar(&cfg.compact, "compact-before-report", cfg.compact, "Compact the full key range of Pebble after the last batch, reporting the disk usage before and after")
+	fs.BoolVar(&cfg.compactTrie, "verify-after-compact", cfg.compactTrie, "Compact the full key range of Pebble like -compact-before-report, iterating the full trie at the final root before the compaction and again after reopening the databases, failing unless the root and node counts match")
+	fs.BoolVar(&cfg.depthReport, "depth-report", cfg.depthReport, "Iterate the account trie at the final root, reporting the min, average and max depth of its leaves and their histogram, the average also noted next to the address mode")
+	fs.BoolVar(&cfg.depthTries, "depth-report-storage", cfg.depthTries, "Include the leaves of all the storage tries into -depth-report")
+	fs.BoolVar(&cfg.checksum, "checksum", cfg.checksum, "Hash the trie nodes, flat state and code in the key-value store in key order after the run, identical for runs producing the same state")
+	fs.StringVar(&cfg.csvPath, "csv", cfg.csvPath, "Path of a CSV file to write per-batch metrics into")
+	fs.StringVar(&cfg.dumpPath, "dump-keys", cfg.dumpPath, "Path of a file to write the addresses created in phase 1 into, one hex key per line")
+	fs.BoolVar(&cfg.dumpSlots, "dump-slots", cfg.dumpSlots, "Dump the nonzero slot keys along with every address")
+	fs.Float64Var(&cfg.dumpSample, "dump-sample", cfg.dumpSample, "Percentage of the accounts to dump, picked deterministically by address hash")
+}
+
+// rootVar registers the flag under name pointing to the root of an existing
+// state.
+func (f *cliFlags) rootVar(name, usage string) {
+	f.rootFlag = name
+	f.fs.StringVar(&f.resumeRoot, name, "", usage)
+}
+
+// compareVar registers the flag under name selecting the two schemes to run
+// the workload under.
+func (f *cliFlags) compareVar(name, value, usage string) {
+	f.compareFlag = name
+	f.fs.StringVar(&f.compare, name, value, usage)
+}
+
+// parse parses the command line arguments along with the configuration file
+// they point to, returning the configuration to run with. The configuration is
+// not validated yet, as the commands may adjust it first.
+func (f *cliFlags) parse(args []string) (*config, error) {
+	f.fs.Parse(args)
+	if f.configFile != "" {
+		if err := applyConfigFile(f.fs, f.configFile); err != nil {
+			return nil, err
+		}
+	}
+	if f.history < 0 {
+		return nil, fmt.Errorf("negative state history %d", f.history)
+	}
+	var (
+		cfg = f.cfg
+		err error
+	)
+	cfg.history = uint64(f.history)
+	if cfg.archive {
+		cfg.history = 0
+	}
+	if cfg.expectRoot, err = parseRoot(f.expectRoot); err != nil {
+		return nil, fmt.Errorf("bad -expect-root: %v", err)
+	}
+	if cfg.expectModRoot, err = parseRoot(f.expectModRoot); err != nil {
+		return nil, fmt.Errorf("bad -expect-mod-root: %v", err)
+	}
+	if cfg.resumeRoot, err = parseRoot(f.resumeRoot); err != nil {
+		return nil, fmt.Errorf("bad -%s: %v", f.rootFlag, err)
+	}
+	if f.iterateFrom != "" {
+		if cfg.iterateFrom, err = hexutil.Decode(f.iterateFrom); err != nil {
+			return nil, fmt.Errorf("bad -iterate-from: %v", err)
+		}
+	}
+	if cfg.compare, err = parseSchemes(f.compare); err != nil {
+		return nil, fmt.Errorf("bad -%s: %v", f.compareFlag, err)
+	}
+	if f.commitSnap {
+		if cfg.compare != nil {
+			return nil, fmt.Errorf("-commit-snapshot runs its own comparison, not supported with -%s", f.compareFlag)
+		}
+		cfg.compare = []string{cfg.scheme, compareSnapshot}
+	}
+	if f.commitEach {
+		if cfg.compare != nil {
+			return nil, fmt.Errorf("-commit-every-account runs its own comparison, not supported with -%s or -commit-snapshot", f.compareFlag)
+		}
+		cfg.compare = []string{cfg.scheme, compareAccount}
+	}
+	if f.benchfmt {
+		if cfg.output != outputText && cfg.output != outputBench {
+			return nil, fmt.Errorf("-benchfmt is not supported with -output %s", cfg.output)
+		}
+		cfg.output = outputBench
+	}
+	if f.mix != "" {
+		if cfg.mix, err = parseMix(f.mix); err != nil {
+			return nil, fmt.Errorf("bad -mix: %v", err)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// flatStoragePrefix is the key namespace of the flat storage layout, keeping
+// the slots apart from the trie nodes and any state of geth itself.
+const flatStoragePrefix = "mpt-bench-flat-"
+
+// storageWriter is the destination of the slot writes of the workload, either
+// the storage tries of a StateDB or the flat storage layout.
+type storageWriter interface {
+	// SetState writes value into the slot key of account addr, returning the
+	// previous value of the slot.
+	SetState(addr common.Address, key, value common.Hash) common.Hash
+}
+
+// flatStorage writes the slots into a dedicated key-value namespace, keyed by
+// the account address followed by the slot key, without any storage trie. The
+// writes are buffered until the next commit.
+type flatStorage struct {
+	db    ethdb.KeyValueStore
+	dirty map[[common.AddressLength + common.HashLength]byte]common.Hash
+}
+
+func newFlatStorage(diskdb ethdb.Database) *flatStorage {
+	return &flatStorage{
+		db:    rawdb.NewTable(diskdb, flatStoragePrefix),
+		dirty: make(map[[common.AddressLength + common.HashLength]byte]common.Hash),
+	}
+}
+
+// SetState implements storageWriter.
+func (f *flatStorage) SetState(addr common.Address, key, value common.Hash) common.Hash {
+	var k [common.AddressLength + common.HashLength]byte
+	copy(k[:], addr[:])
+	copy(k[common.AddressLength:], key[:])
+
+	prev, ok := f.dirty[k]
+	if !ok {
+		// Missing slots are reported as empty, like the tries do
+		blob, _ := f.db.Get(k[:])
+		prev = common.BytesToHash(blob)
+	}
+	f.dirty[k] = value
+	return prev
+}
+
+// commit writes the buffered slots into the database in one batch, deleting
+// the zeroed ones.
+func (f *flatStorage) commit() error {
+	batch := f.db.NewBatch()
+	for k, value := range f.dirty {
+		var err error
+		if value == (common.Hash{}) {
+			err = batch.Delete(k[:])
+		} else {
+			err = batch.Put(k[:], value[:])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write flat slot: %v", err)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to write flat storage: %v", err)
+	}
+	clear(f.dirty)
+	return nil
+}
+
+// storage returns the destination of the slot writes of the current batch.
+func (b *bench) storage() storageWriter {
+	var w storageWriter = b.statedb
+	if b.flat != nil {
+		w = b.flat
+	}
+	if b.mods != nil {
+		w = sampledWriter{storageWriter: w, sample: b.mods}
+	}
+	if b.access != nil {
+		w = accessWriter{storageWriter: w, statedb: b.statedb, stats: b.access}
+	}
+	return w
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// freezerStats contains the state history freezer of path mode measured apart
+// from the live key-value store: the histories appended over the run, the time
+// appending them took, and what the freezer retains on disk at its end.
+type freezerStats struct {
+	Appended   int64             `json:"appended"` // State histories appended over the run
+	DataBytes  int64             `json:"dataBytes"`
+	IndexBytes int64             `json:"indexBytes"`
+	Elapsed    time.Duration     `json:"appendNs"` // Time spent encoding and appending the histories
+	Throughput float64           `json:"appendBytesPerSecond"`
+	Items      uint64            `json:"items"`      // State histories retained
+	Tables     map[string]uint64 `json:"tableBytes"` // Size of the retained histories per freezer table
+	FileBytes  int64             `json:"fileBytes"`  // Size of the freezer files, tails pruned but not yet deleted included
+	LiveBytes  int64             `json:"liveBytes"`  // Size of the key-value store, the freezer excluded
+}
+
+// freezerStats measures the state history freezer, counting the histories
+// appended since the given snapshot of the history write counters. The items
+// and table sizes are read from the freezer held open by the trie database.
+func (b *bench) freezerStats(start pathdb.HistoryWriteStats, items uint64) (*freezerStats, error) {
+	tables, err := b.trieDB.HistoryTableSizes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure the freezer tables: %v", err)
+	}
+	name := rawdb.MerkleStateFreezerName
+	if b.cfg.verkle {
+		name = rawdb.VerkleStateFreezerName
+	}
+	var (
+		now = pathdb.ReadHistoryWriteStats()
+		res = &freezerStats{
+			Appended:   now.Written - start.Written,
+			DataBytes:  now.DataBytes - start.DataBytes,
+			IndexBytes: now.IndexBytes - start.IndexBytes,
+			Elapsed:    now.Elapsed - start.Elapsed,
+			Items:      items,
+			Tables:     tables,
+			FileBytes:  getDirSize(filepath.Join(b.cfg.dbPath, "ancient", name)),
+		}
+	)
+	if secs := res.Elapsed.Seconds(); secs > 0 {
+		res.Throughput = float64(res.DataBytes+res.IndexBytes) / secs
+	}
+	res.LiveBytes, _ = b.storeSizes()
+	return res, nil
+}
+
+// printFreezer writes the freezer measurements into w, if taken.
+func printFreezer(w io.Writer, f *freezerStats) {
+	if f == nil {
+		return
+	}
+	appended := float64(f.DataBytes+f.IndexBytes) / (1024 * 1024)
+	fmt.Fprintf(w, "Freezer:       %d histories appended, %.2f MB (%.2f MB data, %.2f MB index) in %v, %.2f MB/s\n",
+		f.Appended, appended, float64(f.DataBytes)/(1024*1024), float64(f.IndexBytes)/(1024*1024), f.Elapsed.Round(time.Millisecond), f.Throughput/(1024*1024))
+
+	tables := make([]string, 0, len(f.Tables))
+	for _, name := range slices.Sorted(maps.Keys(f.Tables)) {
+		tables = append(tables, fmt.Sprintf("%s %.2f MB", name, float64(f.Tables[name])/(1024*1024)))
+	}
+	fmt.Fprintf(w, "               %d items retained: %s\n", f.Items, strings.Join(tables, ", "))
+
+	var share float64
+	if total := f.FileBytes + f.LiveBytes; total > 0 {
+		share = float64(f.FileBytes) / float64(total) * 100
+	}
+	fmt.Fprintf(w, "               %.2f MB freezer files vs %.2f MB live key-value store (%.1f%% frozen)\n",
+		float64(f.FileBytes)/(1024*1024), float64(f.LiveBytes)/(1024*1024), share)
+}
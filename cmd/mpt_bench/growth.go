@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// growthStart is the first account count the growth curve is sampled at, the
+// later samples are spaced by factors of ten.
+const growthStart = 1000
+
+// growthPoint is a sample of the growth curve, taken once the creation phase
+// wrote a given number of accounts.
+type growthPoint struct {
+	Accounts   int           `json:"accounts"`
+	Slots      int64         `json:"slots"`
+	Elapsed    time.Duration `json:"elapsedNs"`      // Since the start of the phase, excluding the sampling
+	Throughput float64       `json:"slotsPerSecond"` // Since the previous sample
+	DiskSize   int64         `json:"diskBytes"`      // Size of the database, 0 in dry-run mode
+}
+
+// growthCurve samples the throughput and disk usage of the creation phase at
+// logarithmically spaced account counts, showing how they evolve as the trie
+// deepens. The samples are taken by cumulative accounts written, regardless
+// of where the batch boundaries fall.
+type growthCurve struct {
+	Points []growthPoint `json:"points"`
+
+	next   int           // Account count of the next sample
+	paused time.Duration // Time spent measuring the disk usage, excluded from the samples
+}
+
+func newGrowthCurve() *growthCurve {
+	return &growthCurve{next: growthStart}
+}
+
+// observe records a sample if accounts reached the next sampling point, or if
+// last is set, closing the curve with the final account count. The disk usage
+// is measured by size, which is nil in dry-run mode.
+func (g *growthCurve) observe(accounts int, slots int64, start time.Time, last bool, size func() int64) {
+	if accounts < g.next && !last {
+		return
+	}
+	if n := len(g.Points); n > 0 && g.Points[n-1].Accounts == accounts {
+		return // The final account count was a sampling point too
+	}
+	point := growthPoint{
+		Accounts: accounts,
+		Slots:    slots,
+		Elapsed:  time.Since(start) - g.paused,
+	}
+	prev := growthPoint{}
+	if n := len(g.Points); n > 0 {
+		prev = g.Points[n-1]
+	}
+	if secs := (point.Elapsed - prev.Elapsed).Seconds(); secs > 0 {
+		point.Throughput = float64(point.Slots-prev.Slots) / secs
+	}
+	if size != nil {
+		measured := time.Now()
+		point.DiskSize = size()
+		g.paused += time.Since(measured)
+	}
+	g.Points = append(g.Points, point)
+	for g.next <= accounts {
+		g.next *= 10
+	}
+}
+
+// growthSize returns the measure of the disk usage of the growth curve, nil in
+// dry-run mode as nothing is written to disk.
+func (b *bench) growthSize() func() int64 {
+	if b.cfg.dryRun {
+		return nil
+	}
+	return func() int64 { return getDirSize(b.cfg.dbPath) }
+}
+
+// printGrowth writes the growth curve into w as a table, one row per sample.
+// The disk column counts the committed batches only, the accounts of the
+// current one are still in memory.
+func printGrowth(w io.Writer, g *growthCurve, dryRun bool) {
+	if g == nil || len(g.Points) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n--- Growth Curve ---\n")
+	fmt.Fprintf(w, "%12s %14s %12s %16s %12s %10s\n", "Accounts", "Slots", "Elapsed", "Slots/s", "Disk (MB)", "MB/1k acc")
+	for _, p := range g.Points {
+		if dryRun {
+			fmt.Fprintf(w, "%12d %14d %12v %16.2f %12s %10s\n", p.Accounts, p.Slots, p.Elapsed.Round(time.Millisecond), p.Throughput, "-", "-")
+			continue
+		}
+		fmt.Fprintf(w, "%12d %14d %12v %16.2f %12.2f %10.3f\n", p.Accounts, p.Slots, p.Elapsed.Round(time.Millisecond), p.Throughput,
+			float64(p.DiskSize)/(1024*1024), float64(p.DiskSize)/(1024*1024)/float64(p.Accounts)*1000)
+	}
+}
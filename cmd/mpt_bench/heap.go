@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// heapGuard tracks the heap cap enforced after every batch.
+type heapGuard struct {
+	triggers int         // Batches that found the heap over the cap
+	flushed  common.Hash // Root last flushed by the guard, to not commit it again
+}
+
+// guardHeap checks the live heap against -max-heap-mb after a batch. If it is
+// over the cap, the layers buffered in memory are flushed to disk and a garbage
+// collection is forced. If the heap is still over the cap after that, the run
+// is aborted with a diagnostic instead of leaving it to the OOM killer.
+func (b *bench) guardHeap(phase *phaseResult) error {
+	var (
+		limit = uint64(b.cfg.maxHeapMB) * 1024 * 1024
+		mem   runtime.MemStats
+	)
+	runtime.ReadMemStats(&mem)
+	if mem.HeapAlloc <= limit {
+		return nil
+	}
+	b.heap.triggers++
+	before := mem.HeapAlloc
+	fmt.Fprintf(b.out, "\nHeap at %.2f MB over the %d MB cap after %s batch %d, flushing and collecting...\n",
+		float64(before)/1024/1024, b.cfg.maxHeapMB, phase.Name, len(phase.Batches))
+
+	// Only the diff layers kept by -cap-layers outlive the batch commit, any
+	// other layout has already written the batch out
+	if !b.cfg.dryRun && b.cfg.capLayers > 0 && b.trieDB.Scheme() == rawdb.PathScheme && b.heap.flushed != b.root {
+		if err := b.trieDB.Commit(b.root, false); err != nil {
+			return fmt.Errorf("failed to flush TrieDB: %v", err)
+		}
+		b.heap.flushed = b.root
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&mem)
+	if mem.HeapAlloc > limit {
+		return fmt.Errorf("heap still at %.2f MB after flushing and collecting (%.2f MB before, %.2f MB in use, %d MB cap) in %s batch %d, aborting before running out of memory: lower -k, the cache sizes or -cap-layers",
+			float64(mem.HeapAlloc)/1024/1024, float64(before)/1024/1024, float64(mem.HeapInuse)/1024/1024, b.cfg.maxHeapMB, phase.Name, len(phase.Batches))
+	}
+	fmt.Fprintf(b.out, "Heap down to %.2f MB\n", float64(mem.HeapAlloc)/1024/1024)
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+const (
+	historicalCurrent = "current"       // The root read from is the latest one
+	historicalLayer   = "diff layer"    // The root is still one of the in-memory diff layers
+	historicalHistory = "state history" // The root is below the disk layer, served by the indexed history
+)
+
+// historicalIndexTimeout is how long the historical reads wait for the state
+// histories written by the run to be indexed.
+const historicalIndexTimeout = time.Minute
+
+// historyReads contains the measurements of the reads at a past root.
+type historyReads struct {
+	Depth  int         `json:"depth"` // Commits back from the final root
+	Root   common.Hash `json:"root"`
+	Source string      `json:"source,omitempty"` // Part of pathdb serving the root, empty if unavailable
+	Error  string      `json:"error,omitempty"`  // Why the root couldn't be read, empty on success
+	Reads  *readResult `json:"reads,omitempty"`
+}
+
+// readHistorical performs n random reads at the root committed depth commits
+// before the final one, the same slots the random-read phase reads at the
+// final root. Roots still held in the diff layers are read through them, the
+// older ones through the indexed state histories. A root pathdb can't serve,
+// e.g. as its history has been pruned, is reported rather than failing the
+// run.
+func (b *bench) readHistorical(depth, n int) (*historyReads, error) {
+	if depth >= len(b.roots) {
+		return nil, fmt.Errorf("the run committed %d roots, none of them %d commits back", len(b.roots), depth)
+	}
+	res := &historyReads{
+		Depth: depth,
+		Root:  b.roots[len(b.roots)-1-depth],
+	}
+	var (
+		statedb *state.StateDB
+		err     error
+	)
+	if _, err = b.trieDB.StateReader(res.Root); err == nil {
+		res.Source = historicalLayer
+		if depth == 0 {
+			res.Source = historicalCurrent
+		}
+		statedb, err = state.New(res.Root, b.sdb)
+	} else if err = b.waitIndexed(); err == nil {
+		res.Source = historicalHistory
+		statedb, err = state.New(res.Root, state.NewHistoricDatabase(b.diskdb, b.trieDB))
+	}
+	if err != nil {
+		res.Source, res.Error = "", err.Error()
+		return res, nil
+	}
+	if res.Reads, err = b.timeReads(statedb, n, "historical reads"); err != nil {
+		res.Source, res.Error = "", err.Error()
+	}
+	return res, nil
+}
+
+// waitIndexed waits until the state histories written so far are all indexed,
+// making them available for historical reads.
+func (b *bench) waitIndexed() error {
+	deadline := time.Now().Add(historicalIndexTimeout)
+	for {
+		remain, err := b.trieDB.IndexProgress()
+		if err != nil {
+			return fmt.Errorf("failed to check the state history indexing: %v", err)
+		}
+		if remain == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d state histories still unindexed after %v", remain, historicalIndexTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// printHistorical writes the outcome of the historical reads into w, comparing
+// their latency against the reads of the final root.
+func printHistorical(w io.Writer, h *historyReads, current *readResult) {
+	if h == nil {
+		return
+	}
+	if h.Reads == nil {
+		fmt.Fprintf(w, "Historical:    %d commits back (%x) FAILED: %s\n", h.Depth, h.Root, h.Error)
+		return
+	}
+	fmt.Fprintf(w, "Historical:    %d commits back (%x) via %s, found %d/%d\n", h.Depth, h.Root, h.Source, h.Reads.Found, h.Reads.Reads)
+	if current != nil && current.P95 > 0 && current.Throughput > 0 {
+		fmt.Fprintf(w, "               %.2f reads/s, P95 %v (%.2fx the P95 and %.2fx the throughput of the final root)\n",
+			h.Reads.Throughput, h.Reads.P95, float64(h.Reads.P95)/float64(current.P95), h.Reads.Throughput/current.Throughput)
+		return
+	}
+	fmt.Fprintf(w, "               %.2f reads/s, P95 %v\n", h.Reads.Throughput, h.Reads.P95)
+}
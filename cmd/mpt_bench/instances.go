@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	ethpebble "github.com/ethereum/go-ethereum/ethdb/pebble"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// bottleneckShare is the share of their time the instances have to spend in
+// the commits on average for the shared key-value store to be reported as the
+// bottleneck, even without any write stall.
+const bottleneckShare = 0.5
+
+// instanceDB is the namespace of a single instance in the shared key-value
+// store. The state history freezer is not shared though, it locks its
+// directory, so every instance is pointed to one of its own.
+type instanceDB struct {
+	ethdb.Database
+	ancient string
+}
+
+// AncientDatadir implements ethdb.AncientStore, returning the directory of the
+// instance's own state history freezer.
+func (db *instanceDB) AncientDatadir() (string, error) {
+	return db.ancient, nil
+}
+
+// instanceResult contains the measurements of a single instance.
+type instanceResult struct {
+	ID           int          `json:"id"`
+	Root         common.Hash  `json:"root"`
+	Creation     *phaseResult `json:"creation"`
+	Modification *phaseResult `json:"modification,omitempty"`
+	CommitShare  float64      `json:"commitShare"` // Share of the instance's time spent in the commits
+}
+
+// instanceStats contains the measurements of concurrent instances sharing a
+// single key-value store, telling whether the store held them back.
+type instanceStats struct {
+	Instances      []instanceResult `json:"instances"`
+	WriteStalls    int64            `json:"writeStalls"`
+	WriteStallTime time.Duration    `json:"writeStallNs"`
+	CommitShare    float64          `json:"commitShare"` // Average over the instances
+	Bottleneck     bool             `json:"pebbleBottleneck"`
+}
+
+// runInstances executes the creation and modification phases of cfg in
+// cfg.instances independent states at once, all living in the same Pebble
+// store under distinct key prefixes. Every instance runs the same workload on
+// its own goroutine, so they all end up at the root of a single run.
+func runInstances(ctx context.Context, cfg *config, out io.Writer, start time.Time) (*result, error) {
+	fmt.Fprintf(out, "Initializing %d instances sharing Pebble at %s (Scheme: %s, Cache: %d MB, Compression: %s)...\n",
+		cfg.instances, cfg.dbPath, cfg.scheme, cfg.cacheMB, cfg.compression)
+
+	diskdb, kvdb, err := openDatabase(cfg, cfg.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer diskdb.Close()
+
+	flushes, layers := pathdb.ReadNodeStats().Flushes, trackLayers()
+	before := kvdb.LSMStats()
+	benches := make([]*bench, cfg.instances)
+	for i := range benches {
+		db := &instanceDB{
+			Database: rawdb.NewTable(diskdb, fmt.Sprintf("instance-%d-", i)),
+			ancient:  filepath.Join(cfg.dbPath, "ancient", fmt.Sprintf("instance-%d", i)),
+		}
+		trieDB := triedb.NewDatabase(db, newTrieConfig(cfg))
+		defer trieDB.Close()
+
+		sdb := newStateDatabase(cfg, trieDB, nil)
+		statedb, err := state.New(types.EmptyRootHash, sdb)
+		if err != nil {
+			return nil, fmt.Errorf("instance %d: failed to open state: %v", i, err)
+		}
+		// The instances report through the coordinator, not on their own
+		benches[i] = &bench{
+			stores:   stores{diskdb: db, kvdb: kvdb, trieDB: trieDB, sdb: sdb},
+			cfg:      cfg,
+			out:      io.Discard,
+			statedb:  statedb,
+			progress: newProgress(cfg, io.Discard),
+		}
+	}
+	res := &result{
+		Started:      start,
+		DBPath:       cfg.dbPath,
+		Scheme:       cfg.scheme,
+		Tree:         treeMPT,
+		Params:       cfg.params(),
+		CacheMB:      cfg.cacheMB,
+		Compression:  cfg.compression,
+		BatchMB:      cfg.batchMB,
+		NoWAL:        cfg.noWAL,
+		SyncCommit:   cfg.syncCommit,
+		CommitLimit:  cfg.commitWorkers,
+		NoHashCache:  cfg.noHashCache,
+		InsertOrder:  cfg.insertOrder,
+		KeyScheme:    cfg.keyScheme,
+		AddrMode:     cfg.addrMode,
+		SlotDist:     cfg.slotDist,
+		SlotCap:      cfg.totalSlots,
+		BatchSlots:   cfg.batchSlots,
+		Distribution: cfg.dist,
+		ModSeed:      cfg.modSeed,
+		ModDelete:    cfg.modDelete,
+		ModMode:      cfg.modMode,
+		Creation:     &phaseResult{Name: "creation"},
+		Modification: &phaseResult{Name: "modification"},
+		Instances:    &instanceStats{Instances: make([]instanceResult, cfg.instances)},
+	}
+	for i := range res.Instances.Instances {
+		res.Instances.Instances[i].ID = i
+	}
+	set := &instanceSet{benches: benches, stats: res.Instances}
+	err = runWritePhases(ctx, cfg, out, set, fmt.Sprintf("in each of %d instances", cfg.instances), res)
+	if err := finishPhases(out, res, err); err != nil {
+		return nil, err
+	}
+	for i, b := range benches {
+		res.Instances.Instances[i].Root = b.root
+		res.Dereferenced += b.derefs
+		if cfg.scheme == rawdb.PathScheme {
+			// Persist the buffered layers, otherwise the final state is lost
+			if err := b.trieDB.Journal(b.root); err != nil {
+				return nil, fmt.Errorf("instance %d: failed to journal state: %v", i, err)
+			}
+		}
+	}
+	res.Instances.finish(before, kvdb.LSMStats())
+
+	res.History, res.Archive = cfg.history, cfg.archive
+	if cfg.scheme == rawdb.PathScheme {
+		res.CapLayers = cfg.capLayers
+		res.BufferFlushes = pathdb.ReadNodeStats().Flushes - flushes
+		layers(res)
+	}
+	res.Root = benches[0].root
+	res.DiskSize = getDirSize(cfg.dbPath)
+	res.LSM = inspectLSM(kvdb)
+	res.Elapsed = time.Since(start)
+	return res, nil
+}
+
+// instanceSet runs the write phases in all instances at once, waiting for the
+// slowest instance before moving on to the next phase. The phases of the run
+// hold the aggregate throughput over the wall time, the instance results the
+// throughput of each.
+type instanceSet struct {
+	benches []*bench
+	stats   *instanceStats
+	phases  []*phaseResult // Phases of the instances last run
+}
+
+// createAccounts implements writeTarget, populating the accounts of every
+// instance.
+func (s *instanceSet) createAccounts(ctx context.Context, phase *phaseResult) error {
+	err := s.run(phase, func(b *bench, phase *phaseResult) error {
+		return b.createAccounts(ctx, phase)
+	})
+	for i, phase := range s.phases {
+		s.stats.Instances[i].Creation = phase
+	}
+	return err
+}
+
+// modifyAccounts implements writeTarget, modifying m accounts of every
+// instance.
+func (s *instanceSet) modifyAccounts(ctx context.Context, phase *phaseResult, m int, first uint64) error {
+	err := s.run(phase, func(b *bench, phase *phaseResult) error {
+		phase.Accounts = m
+		return b.modifyAccounts(ctx, phase, m, first)
+	})
+	for i, phase := range s.phases {
+		s.stats.Instances[i].Modification = phase
+	}
+	return err
+}
+
+// run runs fn in every instance on its own goroutine, aggregating the phases of
+// the instances into total over the wall time once all are done. If any
+// instance fails, the first error is returned after the others finished too.
+func (s *instanceSet) run(total *phaseResult, fn func(b *bench, phase *phaseResult) error) error {
+	var (
+		wg    sync.WaitGroup
+		start = time.Now()
+		errs  = make([]error, len(s.benches))
+	)
+	s.phases = make([]*phaseResult, len(s.benches))
+	for i, b := range s.benches {
+		s.phases[i] = &phaseResult{Name: total.Name}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(b, s.phases[i])
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// The commit latency is summarized over the batches of all instances, but
+	// the batches themselves are only reported per instance
+	var slots int64
+	for _, phase := range s.phases {
+		total.Accounts += phase.Accounts
+		total.Batches = append(total.Batches, phase.Batches...)
+		slots += phase.Slots
+	}
+	total.finish(slots, elapsed, s.phases[0].Root)
+	total.Batches = nil
+
+	// Interruptions are only reported if no instance failed for real
+	var err error
+	for i, e := range errs {
+		switch {
+		case e == nil:
+		case errors.Is(e, errInterrupted):
+			if err == nil {
+				err = e
+			}
+		case err == nil || errors.Is(err, errInterrupted):
+			err = fmt.Errorf("instance %d: %v", i, e)
+		}
+	}
+	return err
+}
+
+// checkRoot implements writeTarget, verifying that all instances reached the
+// same root after the phase, as they run the same workload, and that it is the
+// expected one if set.
+func (s *instanceSet) checkRoot(phase *phaseResult, want *common.Hash) error {
+	for i, p := range s.phases[1:] {
+		if p.Root != s.phases[0].Root {
+			return fmt.Errorf("%s root mismatch: instance %d has %x, instance 0 %x", phase.Name, i+1, p.Root, s.phases[0].Root)
+		}
+	}
+	return checkRoot(phase.Name, want, s.phases[0].Root)
+}
+
+// report implements writeTarget, writing the aggregate and per-instance
+// throughput of the phase into w.
+func (s *instanceSet) report(w io.Writer, title, verb string, phase *phaseResult) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%s finished in %v. Root: %x\n", title, phase.Elapsed, phase.Root)
+	fmt.Fprintf(w, "Total Slots %s: %d | Aggregate Throughput: %.2f slots/s\n", verb, phase.Slots, phase.Throughput)
+	fmt.Fprintf(w, "Commit Latency: %v\n", phase.Latency)
+	for i, phase := range s.phases {
+		fmt.Fprintf(w, "  instance %d: %d slots in %v | %.2f slots/s | commit p50 %v, max %v\n",
+			i, phase.Slots, phase.Elapsed, phase.Throughput, phase.Latency.P50, phase.Latency.Max)
+	}
+}
+
+// finish computes the commit shares of the instances and judges whether the
+// shared key-value store was the bottleneck, given its statistics before and
+// after the phases.
+func (s *instanceStats) finish(before, after ethpebble.LSMStats) {
+	s.WriteStalls = after.WriteStalls - before.WriteStalls
+	s.WriteStallTime = after.WriteStallTime - before.WriteStallTime
+
+	for i := range s.Instances {
+		inst := &s.Instances[i]
+
+		var commits, elapsed time.Duration
+		for _, phase := range []*phaseResult{inst.Creation, inst.Modification} {
+			if phase == nil {
+				continue
+			}
+			for _, batch := range phase.Batches {
+				commits += batch.Commit
+			}
+			elapsed += phase.Elapsed
+		}
+		if elapsed > 0 {
+			inst.CommitShare = float64(commits) / float64(elapsed)
+		}
+		s.CommitShare += inst.CommitShare / float64(len(s.Instances))
+	}
+	s.Bottleneck = s.WriteStalls > 0 || s.CommitShare > bottleneckShare
+}
+
+// print writes the per-instance results and the bottleneck verdict into w.
+func (s *instanceStats) print(w io.Writer) {
+	fmt.Fprintf(w, "Instances:     %d sharing one Pebble\n", len(s.Instances))
+	for _, inst := range s.Instances {
+		fmt.Fprintf(w, "  instance %d: root %x | commits %.1f%% of the time\n", inst.ID, inst.Root, inst.CommitShare*100)
+	}
+	verdict := "unlikely"
+	if s.Bottleneck {
+		verdict = "likely"
+	}
+	fmt.Fprintf(w, "Pebble Bottleneck: %s (%d write stalls for %v, commits take %.1f%% of the instance time on average)\n",
+		verdict, s.WriteStalls, s.WriteStallTime, s.CommitShare*100)
+}
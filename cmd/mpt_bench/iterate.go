@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// iterateResult contains the measurements of a full state iteration.
+type iterateResult struct {
+	From        hexutil.Bytes `json:"from,omitempty"` // Hashed account key the iteration started at
+	Accounts    int           `json:"accounts"`
+	Slots       int64         `json:"slots"`
+	Elapsed     time.Duration `json:"elapsedNs"`
+	AccountRate float64       `json:"accountsPerSecond"`
+	SlotRate    float64       `json:"slotsPerSecond"`
+	Preimages   int64         `json:"preimagesResolved,omitempty"` // Keys resolved to their preimage, if recorded
+}
+
+// iterateState walks the account trie at the current root with a key-value
+// iterator, along with the full storage trie of every account visited. This is
+// the access pattern of exporting the state or serving snap sync ranges, unlike
+// the point lookups of the read phase. If from is set, the iteration starts at
+// the first account whose hashed key is not below it, leaving the accounts in
+// front of it out.
+func (b *bench) iterateState(from []byte) (*iterateResult, error) {
+	var (
+		res   = &iterateResult{From: from}
+		start = time.Now()
+	)
+	accTrie, err := trie.NewStateTrie(trie.StateTrieID(b.root), b.trieDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open account trie: %v", err)
+	}
+	nodeIter, err := accTrie.NodeIterator(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate account trie: %v", err)
+	}
+	accIter := trie.NewIterator(nodeIter)
+	for accIter.Next() {
+		res.Accounts++
+		if b.cfg.preimages && accTrie.GetKey(accIter.Key) != nil {
+			res.Preimages++
+		}
+		acc, err := types.FullAccount(accIter.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account %x: %v", accIter.Key, err)
+		}
+		if acc.Root != types.EmptyRootHash {
+			owner := common.BytesToHash(accIter.Key)
+			storageTrie, err := trie.NewStateTrie(trie.StorageTrieID(b.root, owner, acc.Root), b.trieDB)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open storage trie %x of %x: %v", acc.Root, owner, err)
+			}
+			storageNodes, err := storageTrie.NodeIterator(nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to iterate storage trie %x of %x: %v", acc.Root, owner, err)
+			}
+			storageIter := trie.NewIterator(storageNodes)
+			for storageIter.Next() {
+				res.Slots++
+				if b.cfg.preimages && storageTrie.GetKey(storageIter.Key) != nil {
+					res.Preimages++
+				}
+			}
+			if storageIter.Err != nil {
+				return nil, fmt.Errorf("failed to iterate storage trie %x of %x: %v", acc.Root, owner, storageIter.Err)
+			}
+		}
+		if res.Accounts%1000 == 0 {
+			b.progress.update("iteration", res.Accounts, res.Slots, "...iterated %d accounts, %d slots", res.Accounts, res.Slots)
+		}
+	}
+	if accIter.Err != nil {
+		return nil, fmt.Errorf("failed to iterate account trie: %v", accIter.Err)
+	}
+	res.Elapsed = time.Since(start)
+	if secs := res.Elapsed.Seconds(); secs > 0 {
+		res.AccountRate = float64(res.Accounts) / secs
+		res.SlotRate = float64(res.Slots) / secs
+	}
+	return res, nil
+}
+
+// printIterate writes the outcome of a state iteration into w, if one ran.
+func printIterate(w io.Writer, it *iterateResult) {
+	if it == nil {
+		return
+	}
+	from := "the first account"
+	if len(it.From) > 0 {
+		from = it.From.String()
+	}
+	fmt.Fprintf(w, "Iteration finished in %v, starting at %s. Visited: %d accounts, %d slots\n", it.Elapsed, from, it.Accounts, it.Slots)
+	fmt.Fprintf(w, "Throughput: %.2f accounts/s | %.2f slots/s\n", it.AccountRate, it.SlotRate)
+	if it.Preimages > 0 {
+		fmt.Fprintf(w, "Preimages Resolved: %d of %d keys\n", it.Preimages, int64(it.Accounts)+it.Slots)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// iterationRuns contains the results of the same workload run several times
+// over, and the spread of the throughput and disk usage across the runs.
+type iterationRuns struct {
+	Runs        []*result         `json:"runs"`
+	Metrics     []iterationMetric `json:"metrics"`
+	Interrupted bool              `json:"interrupted,omitempty"` // Whether the remaining runs were skipped
+	Mismatches  []string          `json:"mismatches,omitempty"`  // Roots differing across the runs
+}
+
+// iterationMetric is the spread of a single measurement over the iterations.
+type iterationMetric struct {
+	Name   string    `json:"name"`
+	Unit   string    `json:"unit"`
+	Values []float64 `json:"values"`
+	Mean   float64   `json:"mean"`
+	Stddev float64   `json:"stddev"` // Sample standard deviation
+	CI95   float64   `json:"ci95"`   // Half-width of the 95% confidence interval of the mean
+}
+
+// runIterations runs the workload described by cfg the configured number of
+// times, clearing the database before every run unless resuming from an
+// existing state. The workload is deterministic, so all the runs must end up
+// with the same roots.
+func runIterations(ctx context.Context, cfg *config, out io.Writer) (*iterationRuns, error) {
+	runs := new(iterationRuns)
+	for i := 1; i <= cfg.iterations; i++ {
+		sub := *cfg
+		sub.iterations = 1
+		if sub.resumeRoot == nil {
+			sub.clear = true
+		}
+		if sub.csvPath != "" {
+			sub.csvPath = fmt.Sprintf("%s.i%d", cfg.csvPath, i)
+		}
+		fmt.Fprintf(out, "\n=== Iteration %d/%d ===\n", i, cfg.iterations)
+		res, err := run(ctx, &sub, out)
+		if err != nil {
+			return nil, fmt.Errorf("iteration %d: %v", i, err)
+		}
+		runs.Runs = append(runs.Runs, res)
+		if res.Interrupted {
+			runs.Interrupted = true
+			break
+		}
+	}
+	runs.check()
+	runs.summarize(cfg.dryRun)
+	return runs, nil
+}
+
+// check compares the roots of every run against the ones of the first. An
+// interrupted run stopped somewhere in its phases, it is left out.
+func (r *iterationRuns) check() {
+	first := r.Runs[0]
+	for i, res := range r.Runs[1:] {
+		if res.Interrupted {
+			continue
+		}
+		check := func(phase string, want, have *phaseResult) {
+			if want != nil && have != nil && want.Root != have.Root {
+				r.Mismatches = append(r.Mismatches, fmt.Sprintf("%s root: iteration 1 %x, iteration %d %x", phase, want.Root, i+2, have.Root))
+			}
+		}
+		check("creation", first.Creation, res.Creation)
+		check("modification", first.Modification, res.Modification)
+		check("destruction", first.Destruction, res.Destruction)
+		check("deletion", first.Deletion, res.Deletion)
+		if first.Root != res.Root {
+			r.Mismatches = append(r.Mismatches, fmt.Sprintf("final root: iteration 1 %x, iteration %d %x", first.Root, i+2, res.Root))
+		}
+	}
+}
+
+// summarize computes the spread of the phase throughputs, the elapsed time and
+// the final disk usage over the completed runs. The phases skipped by the
+// workload, and the disk usage of dry runs, are left out.
+func (r *iterationRuns) summarize(dryRun bool) {
+	add := func(name, unit string, value func(res *result) (float64, bool)) {
+		m := iterationMetric{Name: name, Unit: unit}
+		for _, res := range r.Runs {
+			if res.Interrupted {
+				continue
+			}
+			if v, ok := value(res); ok {
+				m.Values = append(m.Values, v)
+			}
+		}
+		if len(m.Values) == 0 {
+			return
+		}
+		m.Mean, m.Stddev = meanStddev(m.Values)
+		m.CI95 = tQuantile95(len(m.Values)-1) * m.Stddev / math.Sqrt(float64(len(m.Values)))
+		r.Metrics = append(r.Metrics, m)
+	}
+	phase := func(name string, get func(res *result) *phaseResult) {
+		add(name, "slots/s", func(res *result) (float64, bool) {
+			if p := get(res); p != nil {
+				return p.Throughput, true
+			}
+			return 0, false
+		})
+	}
+	phase("Creation", func(res *result) *phaseResult { return res.Creation })
+	phase("Modification", func(res *result) *phaseResult { return res.Modification })
+	phase("Destruction", func(res *result) *phaseResult { return res.Destruction })
+	phase("Deletion", func(res *result) *phaseResult { return res.Deletion })
+	add("Elapsed", "s", func(res *result) (float64, bool) { return res.Elapsed.Seconds(), true })
+	if !dryRun {
+		add("Disk Usage", "MB", func(res *result) (float64, bool) { return float64(res.DiskSize) / (1024 * 1024), true })
+	}
+}
+
+// tQuantile95 returns the two-sided 95% quantile of the Student's t
+// distribution with df degrees of freedom, approaching the normal one above
+// the abridged table.
+func tQuantile95(df int) float64 {
+	table := []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+	switch {
+	case df < 1:
+		return math.NaN()
+	case df <= len(table):
+		return table[df-1]
+	}
+	return 1.960
+}
+
+// print writes the spread of every metric into w in the requested format.
+func (r *iterationRuns) print(w io.Writer, format string) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	if format == outputBench {
+		printBenchHeader(w)
+		for _, res := range r.Runs {
+			printBench(w, res)
+		}
+		return nil
+	}
+	fmt.Fprintf(w, "\n--- Iterations (%d runs, %s scheme) ---\n", len(r.Runs), r.Runs[0].Scheme)
+	fmt.Fprintf(w, "%-22s %14s %14s %14s %8s\n", "Metric", "Mean", "Stddev", "95% CI", "CV")
+	for _, m := range r.Metrics {
+		cv, ci := "-", "-"
+		if m.Mean != 0 {
+			cv = fmt.Sprintf("%.1f%%", m.Stddev/m.Mean*100)
+		}
+		if len(m.Values) > 1 {
+			ci = fmt.Sprintf("±%.2f", m.CI95)
+		}
+		fmt.Fprintf(w, "%-22s %14.2f %14.2f %14s %8s\n", fmt.Sprintf("%s (%s)", m.Name, m.Unit), m.Mean, m.Stddev, ci, cv)
+	}
+	switch {
+	case r.Interrupted:
+		fmt.Fprintf(w, "Interrupted, the remaining iterations were skipped\n")
+	case len(r.Mismatches) > 0:
+		fmt.Fprintf(w, "ROOT MISMATCH, the iterations disagree on the identical workload:\n")
+		for _, m := range r.Mismatches {
+			fmt.Fprintf(w, "  %s\n", m)
+		}
+	default:
+		fmt.Fprintf(w, "Roots match across all iterations\n")
+	}
+	return nil
+}
+
+// exitCode implements report, failing on any mismatch between the runs.
+func (r *iterationRuns) exitCode() int {
+	switch {
+	case len(r.Mismatches) > 0:
+		return 1
+	case r.Interrupted:
+		return exitInterrupted
+	}
+	return 0
+}
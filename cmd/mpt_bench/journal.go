@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// journalIdentifier is the syslog identifier of the journal entries, for
+// filtering them with journalctl -t.
+const journalIdentifier = "mpt_bench"
+
+// The priorities of the journal entries, as defined by syslog.
+const (
+	journalPriorityErr    = 3
+	journalPriorityNotice = 5
+	journalPriorityInfo   = 6
+)
+
+// journalField is a field of a journal entry. The names follow the journal
+// rules, uppercase letters, digits and underscores.
+type journalField struct {
+	name  string
+	value string
+}
+
+// journalConn sends the milestones and the summary of the runs to the
+// systemd journal over its native protocol, one datagram per entry. Besides
+// the message, every entry carries the measurements as fields of their own,
+// so they can be queried with e.g. journalctl -t mpt_bench MPT_PHASE=creation.
+type journalConn struct {
+	conn   net.Conn
+	failed bool // Whether a send failed, only the first failure is warned about
+}
+
+// send writes an entry with the given priority, message and fields into the
+// journal. A journal going away fails neither the entry nor the run, it is
+// warned about on stderr once.
+func (j *journalConn) send(priority int, message string, fields ...journalField) {
+	if j == nil {
+		return
+	}
+	entry := append([]journalField{
+		{"MESSAGE", message},
+		{"PRIORITY", strconv.Itoa(priority)},
+		{"SYSLOG_IDENTIFIER", journalIdentifier},
+	}, fields...)
+
+	if _, err := j.conn.Write(encodeJournal(entry)); err != nil && !j.failed {
+		j.failed = true
+		fmt.Fprintf(os.Stderr, "Failed to write to the systemd journal: %v\n", err)
+	}
+}
+
+// close closes the connection to the journal.
+func (j *journalConn) close() error {
+	if j == nil {
+		return nil
+	}
+	return j.conn.Close()
+}
+
+// encodeJournal serializes the fields of an entry in the native protocol of
+// the journal. Values spanning several lines are length prefixed instead of
+// terminated by the newline.
+func encodeJournal(fields []journalField) []byte {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		if !strings.Contains(f.value, "\n") {
+			fmt.Fprintf(&buf, "%s=%s\n", f.name, f.value)
+			continue
+		}
+		buf.WriteString(f.name)
+		buf.WriteByte('\n')
+		binary.Write(&buf, binary.LittleEndian, uint64(len(f.value)))
+		buf.WriteString(f.value)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// milestone sends the outcome of a write phase just finished to the journal,
+// if enabled.
+func (b *bench) milestone(p *phaseResult) {
+	if b.cfg.journal == nil {
+		return
+	}
+	fields := []journalField{
+		{"MPT_PHASE", p.Name},
+		{"MPT_SCHEME", b.cfg.scheme},
+		{"MPT_ACCOUNTS", strconv.Itoa(p.Accounts)},
+		{"MPT_SLOTS", strconv.FormatInt(p.Slots, 10)},
+		{"MPT_THROUGHPUT", strconv.FormatFloat(p.Throughput, 'f', 2, 64)},
+		{"MPT_ELAPSED_NS", strconv.FormatInt(int64(p.Elapsed), 10)},
+		{"MPT_ROOT", b.root.Hex()},
+	}
+	disk := "n/a (dry run)"
+	if !b.cfg.dryRun {
+		size := getDirSize(b.cfg.dbPath)
+		fields = append(fields, journalField{"MPT_DISK_BYTES", strconv.FormatInt(size, 10)})
+		disk = fmt.Sprintf("%.2f MB", float64(size)/(1024*1024))
+	}
+	b.cfg.journal.send(journalPriorityInfo, fmt.Sprintf("%s finished in %v: %d accounts, %d slots, %.2f slots/s, root %x, disk %s",
+		p.Name, p.Elapsed, p.Accounts, p.Slots, p.Throughput, b.root, disk), fields...)
+}
+
+// journalSummary sends the summary of the command ending with the given report
+// and exit code to the journal, if enabled. The throughputs, disk usage and
+// final root are broken out for single runs, the other reports only tell
+// their outcome.
+func journalSummary(j *journalConn, cmd string, rep report, code int) {
+	if j == nil {
+		return
+	}
+	fields := []journalField{
+		{"MPT_COMMAND", cmd},
+		{"MPT_EXIT_CODE", strconv.Itoa(code)},
+	}
+	priority := journalPriorityNotice
+	if code == 1 {
+		priority = journalPriorityErr
+	}
+	res, ok := rep.(*result)
+	if !ok {
+		j.send(priority, fmt.Sprintf("%s finished with exit code %d", cmd, code), fields...)
+		return
+	}
+	fields = append(fields,
+		journalField{"MPT_SCHEME", res.Scheme},
+		journalField{"MPT_ROOT", res.Root.Hex()},
+		journalField{"MPT_ELAPSED_NS", strconv.FormatInt(int64(res.Elapsed), 10)},
+	)
+	summary := []string{fmt.Sprintf("%s finished in %v", cmd, res.Elapsed)}
+	for _, p := range []*phaseResult{res.Creation, res.Modification, res.Destruction, res.Deletion, res.Replay} {
+		if p == nil || p.Elapsed == 0 {
+			continue
+		}
+		fields = append(fields, journalField{"MPT_" + strings.ToUpper(p.Name) + "_THROUGHPUT", strconv.FormatFloat(p.Throughput, 'f', 2, 64)})
+		summary = append(summary, fmt.Sprintf("%s %.2f slots/s", p.Name, p.Throughput))
+	}
+	if !res.DryRun {
+		fields = append(fields, journalField{"MPT_DISK_BYTES", strconv.FormatInt(res.DiskSize, 10)})
+		summary = append(summary, fmt.Sprintf("disk %.2f MB", float64(res.DiskSize)/(1024*1024)))
+	}
+	summary = append(summary, fmt.Sprintf("root %x", res.Root))
+	if res.Interrupted {
+		summary = append(summary, "interrupted")
+	}
+	j.send(priority, strings.Join(summary, ", "), fields...)
+}
+
+// journalFailure sends the error failing the command to the journal, if
+// enabled.
+func journalFailure(j *journalConn, cmd string, err error) {
+	j.send(journalPriorityErr, fmt.Sprintf("%s failed: %v", cmd, err),
+		journalField{"MPT_COMMAND", cmd},
+		journalField{"MPT_EXIT_CODE", "1"},
+		journalField{"MPT_ERROR", err.Error()},
+	)
+}
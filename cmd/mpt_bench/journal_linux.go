@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// journalSocket is the socket journald receives the native protocol entries on.
+var journalSocket = "/run/systemd/journal/socket"
+
+// openJournal connects to the systemd journal, failing if no journald is
+// listening on this system.
+func openJournal() (*journalConn, error) {
+	if _, err := os.Stat(journalSocket); err != nil {
+		return nil, fmt.Errorf("no systemd journal listening at %s: %v", journalSocket, err)
+	}
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the systemd journal: %v", err)
+	}
+	return &journalConn{conn: conn}, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	defer func(old string) { journalSocket = old }(journalSocket)
+	journalSocket = path
+
+	j, err := openJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	j.send(journalPriorityInfo, "creation finished", journalField{"MPT_PHASE", "creation"}, journalField{"MPT_ERROR", "two\nlines"})
+
+	buf := make([]byte, 4096)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	want.WriteString("MESSAGE=creation finished\nPRIORITY=6\nSYSLOG_IDENTIFIER=mpt_bench\nMPT_PHASE=creation\nMPT_ERROR\n")
+	binary.Write(&want, binary.LittleEndian, uint64(len("two\nlines")))
+	want.WriteString("two\nlines\n")
+	if !bytes.Equal(buf[:n], want.Bytes()) {
+		t.Errorf("have entry %q, want %q", buf[:n], want.Bytes())
+	}
+	if j.failed {
+		t.Error("send reported as failed")
+	}
+
+	journalSocket = filepath.Join(t.TempDir(), "missing")
+	if _, err := openJournal(); err == nil {
+		t.Error("opened a journal without a socket")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+// openJournal fails, the systemd journal only exists on linux.
+func openJournal() (*journalConn, error) {
+	return nil, errors.New("the systemd journal is only available on linux, not " + runtime.GOOS)
+}
@@ -1,202 +1,141 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"fmt"
-	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"runtime"
-	"time"
-
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/rawdb"
-	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/core/tracing"
-	"github.com/ethereum/go-ethereum/crypto"
-	ethpebble "github.com/ethereum/go-ethereum/ethdb/pebble"
-	"github.com/ethereum/go-ethereum/triedb"
-	"github.com/ethereum/go-ethereum/triedb/pathdb"
-	"github.com/holiman/uint256"
-	"github.com/cockroachdb/pebble"
+	"runtime/trace"
+	"syscall"
 )
 
-func main() {
-	var (
-		nAccounts = flag.Int("n", 100, "Number of accounts to create")
-		nSlots    = flag.Int("slots", 1000, "Number of slots per account")
-		mModify   = flag.Int("m", 10, "Number of accounts to modify after creation")
-		kCommit   = flag.Int("k", 50, "Number of accounts per commit/flush")
-		dbPath    = flag.String("db", "mpt_bench_db", "Path to database")
-		clearDB   = flag.Bool("clear", true, "Clear database before starting")
-	)
-	flag.Parse()
+// exitInterrupted is the exit code of a run stopped cleanly by a signal, as
+// opposed to 1 for failures and 2 for invalid configurations.
+const exitInterrupted = 130
 
-	if *clearDB {
-		fmt.Printf("Cleaning up old database at %s...\n", *dbPath)
-		os.RemoveAll(*dbPath)
-	}
+// exitCrashed is the exit code of a run killed by -inject-crash-after, the one
+// of a process killed by SIGKILL.
+const exitCrashed = 137
 
-	// 1. Initialize Pebble
-	fmt.Printf("Initializing Pebble at %s (Compression: Off)...\n", *dbPath)
-	pdb, err := ethpebble.NewCustom(*dbPath, "eth/db/chaindata/", func(options *pebble.Options) {
-		for i := range options.Levels {
-			options.Levels[i].Compression = pebble.NoCompression
-		}
-		options.Cache = pebble.NewCache(256 * 1024 * 1024)
-	})
-	if err != nil {
-		fmt.Printf("Failed to open Pebble: %v\n", err)
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "help" {
+		printUsage(os.Stdout)
 		return
 	}
-	diskdb := rawdb.NewDatabase(pdb)
-	defer diskdb.Close()
-
-	// 2. Initialize TrieDB (PathDB for Pruning) and StateDB
-	fmt.Println("Initializing TrieDB with PathDB (Pruning: On)...")
-	trieDB := triedb.NewDatabase(diskdb, &triedb.Config{
-		PathDB: pathdb.Defaults,
-	})
-	sdb := state.NewDatabase(trieDB, nil)
-	statedb, _ := state.New(common.Hash{}, sdb)
-
-	// 3. Phase 1: Creation
-	fmt.Printf("Phase 1: Creating %d accounts with variable slots (avg %d, k=%d)...\n", *nAccounts, *nSlots, *kCommit)
-	phase1Start := time.Now()
-
-	addrs := make([]common.Address, *nAccounts)
-	batchSize := *kCommit
-	var currentRoot common.Hash
-	var totalSlotsCreated int64
-
-	// Use a fixed seed for deterministic benchmarking (borrowed from C# version)
-	r := rand.New(rand.NewSource(42))
-
-	for i := 0; i < *nAccounts; i++ {
-		addr := common.BytesToAddress(crypto.Keccak256([]byte(fmt.Sprintf("account-%d", i)))[:20])
-		addrs[i] = addr
-
-		statedb.SetBalance(addr, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
-		statedb.SetNonce(addr, uint64(i), tracing.NonceChangeUnspecified)
-
-		// Borrowed from C#: Variable slots to simulate real world distribution (avg nSlots)
-		vSlots := r.Intn(*nSlots * 2)
-		for j := 0; j < vSlots; j++ {
-			totalSlotsCreated++
-			// Include account index i to ensure slots are unique across different accounts
-			slotKey := common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("acc-%d-slot-%d", i, j))))
+	cmd, args, err := findCommand(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n", err)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	cfg, err := parseCommand(cmd, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	os.Exit(execute(cmd, cfg))
+}
 
-			// Borrowed from C#: 30% probability for zero or small values to test RLP compression
-			var slotVal common.Hash
-			dice := r.Intn(100)
-			if dice < 20 {
-				// Keep zero
-			} else if dice < 30 {
-				slotVal[31] = 1 // Small value
-			} else {
-				r.Read(slotVal[:]) // Random 32 bytes
-			}
-			statedb.SetState(addr, slotKey, slotVal)
+// execute runs the command with cfg and prints its report, returning the exit
+// code of the process. It is split out of main so that the deferred cleanups
+// still run before exiting.
+func execute(cmd *command, cfg *config) int {
+	// Keep stdout clean for the machine-readable report, the human-readable
+	// progress is routed to stderr instead.
+	out := os.Stdout
+	if cfg.output != outputText {
+		out = os.Stderr
+	}
+	if cfg.tracePath != "" {
+		f, err := os.Create(cfg.tracePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create trace file: %v\n", err)
+			return 1
 		}
-
-		if (i+1)%10 == 0 || i+1 == *nAccounts {
-			fmt.Printf("...processed %d/%d accounts (%.1f%%)\r", i+1, *nAccounts, float64(i+1)/float64(*nAccounts)*100)
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			fmt.Fprintf(os.Stderr, "Failed to start trace: %v\n", err)
+			return 1
 		}
-
-		// Periodic commit to keep memory usage low
-		if (i+1)%batchSize == 0 || i+1 == *nAccounts {
-			root, err := statedb.Commit(uint64(i/batchSize), false, false)
-			if err != nil {
-				fmt.Printf("\nFailed to commit StateDB: %v\n", err)
-				return
-			}
-			err = trieDB.Commit(root, false)
-			if err != nil {
-				fmt.Printf("\nFailed to commit TrieDB: %v\n", err)
+		defer func() {
+			trace.Stop()
+			if err := f.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write trace: %v\n", err)
 				return
 			}
-			currentRoot = root
-
-			// Borrowed from C#: Memory monitoring
-			var mem runtime.MemStats
-			runtime.ReadMemStats(&mem)
-			fmt.Printf("\n[Batch %d] Root: %.8s | Disk: %.2f MB | MemAlloc: %.2f MB\n",
-				(i/batchSize)+1, currentRoot.String(), float64(getDirSize(*dbPath))/1024/1024, float64(mem.Alloc)/1024/1024)
-
-			// Re-create statedb from the new root to release memory of dirty objects
-			statedb, _ = state.New(currentRoot, sdb)
-			runtime.GC() // Suggest GC to clean up
-		}
+			fmt.Fprintf(out, "Execution trace written to %s, inspect it with: go tool trace %s\n", cfg.tracePath, cfg.tracePath)
+		}()
 	}
-	p1Elapsed := time.Since(phase1Start)
-	fmt.Println()
-	fmt.Printf("Creation finished in %v. Final Root: %x\n", p1Elapsed, currentRoot)
-	fmt.Printf("Total Slots Created: %d | Throughput: %.2f slots/s\n", totalSlotsCreated, float64(totalSlotsCreated)/p1Elapsed.Seconds())
-
-	// 4. Phase 2: Modification
-	if *mModify > *nAccounts {
-		*mModify = *nAccounts
+	if cfg.metricsAddr != "" {
+		live, err := startLiveMetrics(cfg.metricsAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start metrics server: %v\n", err)
+			return 1
+		}
+		cfg.live = live
+		fmt.Fprintf(out, "Serving live metrics at %s\n", cfg.metricsAddr)
+		defer func() {
+			if err := live.close(); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		}()
 	}
-	fmt.Printf("\nPhase 2: Randomly modifying slots in %d accounts (k=%d)...\n", *mModify, *kCommit)
-	phase2Start := time.Now()
-	var totalSlotsModified int64
-	const slotsToModifyPerAccount = 500
-
-	// statedb is already updated to currentRoot from phase 1
-	rMod := rand.New(rand.NewSource(time.Now().UnixNano()))
-	perm := rMod.Perm(*nAccounts)
-	for i := 0; i < *mModify; i++ {
-		accountIdx := perm[i]
-		addr := addrs[accountIdx]
-
-		// Modify some slots randomly
-		for j := 0; j < slotsToModifyPerAccount; j++ {
-			totalSlotsModified++
-			slotIdx := rMod.Intn(*nSlots)
-			// Use the same unique key pattern as in Phase 1
-			slotKey := common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("acc-%d-slot-%d", accountIdx, slotIdx))))
-			var newVal common.Hash
-			rMod.Read(newVal[:])
-			statedb.SetState(addr, slotKey, newVal)
+	if cfg.sysstat {
+		sys, err := newSysSampler()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-sysstat is not supported on this platform: %v\n", err)
+			return 1
 		}
-
-		if (i+1)%10 == 0 || i+1 == *mModify {
-			fmt.Printf("...modified %d/%d accounts (%.1f%%)\r", i+1, *mModify, float64(i+1)/float64(*mModify)*100)
+		cfg.sys = sys
+	}
+	if cfg.logJournal {
+		journal, err := openJournal()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-log-journal is not supported on this platform: %v\n", err)
+			return 1
 		}
-
-		// Modification periodic commit
-		if (i+1)%batchSize == 0 || i+1 == *mModify {
-			root, err := statedb.Commit(uint64(i/batchSize)+1000000, false, false) // different block space
-			if err != nil {
-				fmt.Printf("\nFailed to commit modifications: %v\n", err)
-				return
-			}
-			err = trieDB.Commit(root, false)
-			if err != nil {
-				fmt.Printf("\nFailed to commit TrieDB (mod): %v\n", err)
-				return
-			}
-			currentRoot = root
-
-			var mem runtime.MemStats
-			runtime.ReadMemStats(&mem)
-			fmt.Printf("\n[Mod Batch] Disk: %.2f MB | MemAlloc: %.2f MB\n",
-				float64(getDirSize(*dbPath))/1024/1024, float64(mem.Alloc)/1024/1024)
-
-			statedb, _ = state.New(currentRoot, sdb)
-			runtime.GC()
+		cfg.journal = journal
+		defer journal.close()
+	}
+	if cfg.rngLogPath != "" || cfg.rngVerifyPath != "" {
+		rng, err := openRNGLog(cfg.rngLogPath, cfg.rngVerifyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
 		}
+		cfg.rng = rng
+		defer rng.close()
 	}
-	p2Elapsed := time.Since(phase2Start)
-	fmt.Println()
-	fmt.Printf("Modification finished in %v. Final New Root: %x\n", p2Elapsed, currentRoot)
-	fmt.Printf("Total Slots Modified: %d | Throughput: %.2f slots/s\n", totalSlotsModified, float64(totalSlotsModified)/p2Elapsed.Seconds())
-
-	// 5. Final Report
-	size := getDirSize(*dbPath)
-	fmt.Printf("\n--- Final Report ---\n")
-	fmt.Printf("Database Path: %s\n", *dbPath)
-	fmt.Printf("Disk Usage:    %.2f MB\n", float64(size)/(1024*1024))
+	// Stop at the next batch boundary on the first interrupt, restoring the
+	// default behavior so that a second one terminates right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+		fmt.Fprintln(out, "\nInterrupt received, finishing the current batch...")
+	}()
+
+	rep, err := cmd.run(ctx, cfg, out)
+	if err != nil {
+		fmt.Fprintf(out, "\nBenchmark failed: %v\n", err)
+		journalFailure(cfg.journal, cmd.name, err)
+		return 1
+	}
+	// An interrupted run stops drawing early, only compare the finished ones
+	if err := cfg.rng.close(); err != nil && rep.exitCode() == 0 {
+		fmt.Fprintf(out, "\nBenchmark failed: %v\n", err)
+		journalFailure(cfg.journal, cmd.name, err)
+		return 1
+	}
+	if err := rep.print(os.Stdout, cfg.output); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+		return 1
+	}
+	journalSummary(cfg.journal, cmd.name, rep, rep.exitCode())
+	return rep.exitCode()
 }
 
 func getDirSize(path string) int64 {
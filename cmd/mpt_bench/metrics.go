@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// liveMetricsTimeout is how long the metrics server waits for the requests in
+// flight when shutting down at the end of the run.
+const liveMetricsTimeout = 5 * time.Second
+
+// liveSnapshot is the JSON document served by the metrics server, the state of
+// the run as of the last committed batch.
+type liveSnapshot struct {
+	Time          time.Time   `json:"time"`
+	Elapsed       float64     `json:"elapsedSeconds"` // Since the server started
+	Phase         string      `json:"phase,omitempty"`
+	Batch         int         `json:"batch"` // Within the phase
+	Block         uint64      `json:"block"`
+	Root          common.Hash `json:"root"`
+	Accounts      int         `json:"accounts"` // Within the phase
+	Slots         int64       `json:"slots"`    // Within the phase
+	TotalAccounts int         `json:"totalAccounts"`
+	TotalSlots    int64       `json:"totalSlots"`
+	Throughput    float64     `json:"slotsPerSecond"`      // Of the last batch
+	PhaseRate     float64     `json:"phaseSlotsPerSecond"` // Of the phase so far
+	DiskSize      int64       `json:"diskBytes"`           // 0 in dry-run mode
+	MemAlloc      uint64      `json:"memAllocBytes"`
+	HeapInuse     uint64      `json:"heapInuseBytes"`
+}
+
+// livePhase is the running total of a phase fed to the metrics server.
+type livePhase struct {
+	accounts int
+	slots    int64
+	elapsed  time.Duration // Sum of the batch durations
+}
+
+// liveMetrics serves the progress of the run over HTTP as JSON, updated after
+// every committed batch, for monitoring long runs from another terminal. The
+// concurrent instances of a run all feed the same server.
+type liveMetrics struct {
+	lock   sync.Mutex
+	start  time.Time
+	phases map[*phaseResult]*livePhase
+	last   liveSnapshot
+
+	server *http.Server
+	done   chan error // Result of the serving goroutine
+}
+
+// startLiveMetrics starts serving the metrics at addr, a TCP address or the
+// path of a Unix socket prefixed by "unix:".
+func startLiveMetrics(addr string) (*liveMetrics, error) {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	m := &liveMetrics{
+		start:  time.Now(),
+		phases: make(map[*phaseResult]*livePhase),
+		done:   make(chan error, 1),
+	}
+	m.server = &http.Server{Handler: m, ReadHeaderTimeout: liveMetricsTimeout}
+	go func() {
+		if err := m.server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			m.done <- err
+			return
+		}
+		m.done <- nil
+	}()
+	return m, nil
+}
+
+// update records a batch committed into phase.
+func (m *liveMetrics) update(phase *phaseResult, sample batchSample) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	p := m.phases[phase]
+	if p == nil {
+		p = new(livePhase)
+		m.phases[phase] = p
+	}
+	prevSlots := p.slots
+	p.accounts, p.slots, p.elapsed = sample.Accounts, sample.Slots, p.elapsed+sample.Duration
+
+	m.last = liveSnapshot{
+		Phase:     phase.Name,
+		Batch:     sample.Batch,
+		Block:     sample.Block,
+		Root:      sample.Root,
+		Accounts:  sample.Accounts,
+		Slots:     sample.Slots,
+		DiskSize:  sample.DiskSize,
+		MemAlloc:  sample.MemAlloc,
+		HeapInuse: sample.HeapInuse,
+	}
+	if secs := sample.Duration.Seconds(); secs > 0 {
+		m.last.Throughput = float64(sample.Slots-prevSlots) / secs
+	}
+	if secs := p.elapsed.Seconds(); secs > 0 {
+		m.last.PhaseRate = float64(p.slots) / secs
+	}
+	for _, p := range m.phases {
+		m.last.TotalAccounts += p.accounts
+		m.last.TotalSlots += p.slots
+	}
+}
+
+// ServeHTTP implements http.Handler, writing the last snapshot on any path.
+func (m *liveMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	snap := m.last
+	m.lock.Unlock()
+
+	now := time.Now()
+	snap.Time, snap.Elapsed = now, now.Sub(m.start).Seconds()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(snap)
+}
+
+// close shuts the server down, waiting for the requests in flight.
+func (m *liveMetrics) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), liveMetricsTimeout)
+	defer cancel()
+
+	if err := m.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down the metrics server: %v", err)
+	}
+	if err := <-m.done; err != nil {
+		return fmt.Errorf("metrics server failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/holiman/uint256"
+)
+
+const (
+	workloadBulk  = "bulk"
+	workloadMixed = "mixed"
+)
+
+// The operations of a transaction of the mixed workload.
+const (
+	opRead     = iota // Read a random slot of a live account
+	opWrite           // Write a random slot of a live account
+	opTransfer        // Move one wei between two live accounts
+	opCreate          // Create a new account without any slots
+	opDelete          // Self-destruct a live account
+	numOps
+)
+
+// opNames are the names of the operations in the -mix flag.
+var opNames = [numOps]string{"read", "write", "transfer", "create", "delete"}
+
+// mixWeights are the relative weights the operations of the mixed workload are
+// drawn with, indexed by operation.
+type mixWeights [numOps]int
+
+// defaultMix is the operation mix of the mixed workload unless set otherwise,
+// dominated by reads and slot writes as block execution is.
+var defaultMix = mixWeights{opRead: 50, opWrite: 30, opTransfer: 15, opCreate: 4, opDelete: 1}
+
+// String returns the weights in the format of the -mix flag.
+func (w mixWeights) String() string {
+	parts := make([]string, numOps)
+	for op, weight := range w {
+		parts[op] = fmt.Sprintf("%s=%d", opNames[op], weight)
+	}
+	return strings.Join(parts, ",")
+}
+
+// MarshalText implements encoding.TextMarshaler, reporting the weights in the
+// format of the -mix flag.
+func (w mixWeights) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// parseMix parses the operation weights of the -mix flag, a comma separated
+// list of name=weight pairs. The operations left out are never drawn.
+func parseMix(s string) (mixWeights, error) {
+	var (
+		w     mixWeights
+		total int
+	)
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return w, fmt.Errorf("want name=weight pairs, have %q", part)
+		}
+		op := -1
+		for i, opName := range opNames {
+			if opName == name {
+				op = i
+			}
+		}
+		if op < 0 {
+			return w, fmt.Errorf("unknown operation %q, want one of %s", name, strings.Join(opNames[:], ", "))
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return w, fmt.Errorf("invalid weight %q of %s", value, name)
+		}
+		w[op] = weight
+		total += weight
+	}
+	if total == 0 {
+		return w, fmt.Errorf("all operation weights are zero")
+	}
+	return w, nil
+}
+
+// mixStats contains the operations actually executed by the mixed workload.
+type mixStats struct {
+	Txs       int64   `json:"txs"`
+	TxRate    float64 `json:"txsPerSecond"`
+	Reads     int64   `json:"reads"`
+	Writes    int64   `json:"writes"`
+	Transfers int64   `json:"transfers"`
+	Creates   int64   `json:"creates"`
+	Deletes   int64   `json:"deletes"`
+}
+
+// mixedWorkload draws the operations of the mixed workload, keeping track of
+// the accounts still alive to operate on.
+type mixedWorkload struct {
+	b        *bench
+	r        *rand.Rand
+	pickSlot func() int
+	delete   func() bool
+	total    int   // Sum of the operation weights
+	live     []int // Indices of the accounts not deleted yet
+	touched  []bool
+	stats    *mixStats
+}
+
+// pick draws the next operation by weight. Falling below two live accounts,
+// only new ones are created until a transfer is possible again.
+func (w *mixedWorkload) pick() int {
+	if len(w.live) < 2 {
+		return opCreate
+	}
+	n := w.r.Intn(w.total)
+	for op, weight := range w.b.cfg.mix {
+		if n < weight {
+			return op
+		}
+		n -= weight
+	}
+	panic("unreachable")
+}
+
+// account returns the index of a random live account, counting it as touched.
+func (w *mixedWorkload) account(phase *phaseResult) int {
+	idx := w.live[w.r.Intn(len(w.live))]
+	if !w.touched[idx] {
+		w.touched[idx] = true
+		phase.Accounts++
+	}
+	w.b.touchAccount(w.b.addrs[idx])
+	return idx
+}
+
+// apply executes a single operation on the current state, returning whether
+// it wrote a slot.
+func (w *mixedWorkload) apply(phase *phaseResult, op int) bool {
+	b := w.b
+	switch op {
+	case opRead:
+		idx := w.account(phase)
+		key := slotKey(idx, w.pickSlot())
+		b.touchSlot(b.addrs[idx], key)
+		b.statedb.GetState(b.addrs[idx], key)
+		w.stats.Reads++
+
+	case opWrite:
+		var (
+			idx    = w.account(phase)
+			newVal common.Hash
+		)
+		w.r.Read(newVal[:])
+		if w.delete() {
+			newVal = common.Hash{}
+		}
+		phase.Values.add(b.storage().SetState(b.addrs[idx], slotKey(idx, w.pickSlot()), newVal), newVal)
+		w.stats.Writes++
+		return true
+
+	case opTransfer:
+		from, to := w.account(phase), w.account(phase)
+		b.statedb.SubBalance(b.addrs[from], uint256.NewInt(1), tracing.BalanceChangeTransfer)
+		b.statedb.AddBalance(b.addrs[to], uint256.NewInt(1), tracing.BalanceChangeTransfer)
+		w.stats.Transfers++
+
+	case opCreate:
+		idx := len(b.addrs)
+		addr := accountAddress(b.cfg.addrMode, idx)
+		b.addrs = append(b.addrs, addr)
+		b.slotCounts = append(b.slotCounts, 0)
+		w.touched = append(w.touched, true)
+		w.live = append(w.live, idx)
+		phase.Accounts++
+
+		b.touchAccount(addr)
+		b.statedb.SetBalance(addr, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
+		b.statedb.SetNonce(addr, 1, tracing.NonceChangeUnspecified)
+		w.stats.Creates++
+
+	case opDelete:
+		i := w.r.Intn(len(w.live))
+		idx := w.live[i]
+		w.live[i] = w.live[len(w.live)-1]
+		w.live = w.live[:len(w.live)-1]
+		if !w.touched[idx] {
+			w.touched[idx] = true
+			phase.Accounts++
+		}
+		// Keep the later reads and proofs away from the deleted slots
+		b.slotCounts[idx] = 0
+
+		b.touchAccount(b.addrs[idx])
+		b.statedb.SelfDestruct(b.addrs[idx])
+		w.stats.Deletes++
+	}
+	return false
+}
+
+// applyMixedBlocks simulates a chain of the configured number of blocks on top
+// of the created state like applyBlocks, but every block executes transactions
+// of randomly drawn reads, slot writes, transfers, creations and deletions
+// instead of bare slot writes. The transactions are finalized one by one, as
+// block processing does.
+func (b *bench) applyMixedBlocks(ctx context.Context, phase *phaseResult, first uint64) error {
+	var (
+		cfg   = b.cfg
+		slots int64
+		start = time.Now()
+		rMod  = cfg.rng.newRand("mixed", cfg.modSeed)
+		w     = &mixedWorkload{
+			b:        b,
+			r:        rMod,
+			pickSlot: newSlotPicker(cfg, rMod),
+			delete:   newDeletePicker(cfg),
+			live:     make([]int, 0, len(b.addrs)),
+			touched:  make([]bool, len(b.addrs)),
+			stats:    new(mixStats),
+		}
+	)
+	b.batchStart = start
+	phase.Values = new(valueStats)
+	phase.Mix = w.stats
+
+	for _, weight := range cfg.mix {
+		w.total += weight
+	}
+	for i := range b.addrs {
+		w.live = append(w.live, i)
+	}
+	for i := 0; i < cfg.blocks; i++ {
+		for j := 0; j < cfg.txsPerBlock; j++ {
+			for k := 0; k < cfg.opsPerTx; k++ {
+				if w.apply(phase, w.pick()) {
+					slots++
+				}
+			}
+			b.statedb.Finalise(true)
+			w.stats.Txs++
+		}
+		sample, err := b.commit(phase, first+uint64(i), phase.Accounts, slots)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b.out, "[Block %d] Root: %.8s | %s\n", sample.Block, sample.Root.String(), b.usage(sample))
+
+		if ctx.Err() != nil {
+			w.finish(phase, slots, time.Since(start))
+			return errInterrupted
+		}
+	}
+	w.finish(phase, slots, time.Since(start))
+	return nil
+}
+
+// finish records the totals of the mixed phase.
+func (w *mixedWorkload) finish(phase *phaseResult, slots int64, elapsed time.Duration) {
+	phase.finish(slots, elapsed, w.b.root)
+	phase.BlockTime = blockTimes(phase)
+	if secs := elapsed.Seconds(); secs > 0 {
+		w.stats.TxRate = float64(w.stats.Txs) / secs
+	}
+}
+
+// printMix writes the operations executed by the mixed workload into w, if it
+// ran.
+func printMix(w io.Writer, m *mixStats) {
+	if m == nil {
+		return
+	}
+	fmt.Fprintf(w, "Transactions: %d | Throughput: %.2f tx/s\n", m.Txs, m.TxRate)
+	fmt.Fprintf(w, "Operation Mix: %d reads, %d writes, %d transfers, %d creates, %d deletes\n",
+		m.Reads, m.Writes, m.Transfers, m.Creates, m.Deletes)
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestParseMix(t *testing.T) {
+	have, err := parseMix(defaultMix.String())
+	if err != nil {
+		t.Fatalf("failed to parse the default mix: %v", err)
+	}
+	if have != defaultMix {
+		t.Errorf("default mix: have %v, want %v", have, defaultMix)
+	}
+	if have, err = parseMix("write=3, delete=1"); err != nil {
+		t.Fatalf("failed to parse partial mix: %v", err)
+	}
+	if want := (mixWeights{opWrite: 3, opDelete: 1}); have != want {
+		t.Errorf("partial mix: have %v, want %v", have, want)
+	}
+	for _, bad := range []string{"", "read", "read=x", "read=-1", "swap=1", "read=0,write=0"} {
+		if _, err := parseMix(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/holiman/uint256"
+)
+
+// slotsToModifyPerAccount is the number of slot writes per modified account.
+const slotsToModifyPerAccount = 500
+
+// The modes of the batched modifications of phase 2, the fields of the
+// modified accounts they update.
+const (
+	modModeStorage = "storage" // Slot writes only, the storage roots change
+	modModeBalance = "balance" // Nonce and balance only, like simple transfers
+	modModeMixed   = "mixed"   // Both the slot writes and the account fields
+)
+
+// modifyAccounts overwrites random slots of m randomly chosen accounts,
+// committing them every batch from block first on.
+func (b *bench) modifyAccounts(ctx context.Context, phase *phaseResult, m int, first uint64) error {
+	var (
+		cfg   = b.cfg
+		slots int64
+		start = time.Now()
+	)
+	b.batchStart = start
+	phase.Values = new(valueStats)
+
+	// statedb is already updated to the latest root from phase 1
+	rMod := cfg.rng.newRand("modify", cfg.modSeed)
+	perm := rMod.Perm(cfg.accounts)
+	pickSlot, pickDelete := newSlotPicker(cfg, rMod), newDeletePicker(cfg)
+	for i := 0; i < m; i++ {
+		accountIdx := perm[i]
+		b.touchAccount(b.addrs[accountIdx])
+		if cfg.modMode != modModeStorage {
+			transferTo(b.statedb, b.addrs[accountIdx])
+		}
+		if cfg.modMode != modModeBalance {
+			slots += modifyAccount(b.storage(), b.addrs[accountIdx], accountIdx, rMod, pickSlot, pickDelete, phase.Values)
+		}
+
+		interrupted := ctx.Err() != nil
+		if (i+1)%10 == 0 || i+1 == m {
+			b.progress.update(phase.Name, i+1, slots, "...modified %d/%d accounts (%.1f%%)", i+1, m, float64(i+1)/float64(m)*100)
+		}
+
+		// Modification periodic commit
+		if (i+1)%cfg.batch == 0 || i+1 == m || interrupted {
+			sample, err := b.commit(phase, first+uint64(i/cfg.batch), i+1, slots)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b.out, "\n[Mod Batch] %s\n", b.usage(sample))
+
+			if interrupted {
+				phase.Accounts = i + 1
+				phase.finish(slots, time.Since(start), b.root)
+				return errInterrupted
+			}
+		}
+	}
+	phase.finish(slots, time.Since(start), b.root)
+	return nil
+}
+
+// modifyAccount overwrites randomly picked slots of the accountIdx-th account,
+// residing at addr, with random values, returning the number of slots written. The written values are
+// recorded in values along with the slots they pruned. The writes picked by
+// pickDelete zero their slot instead.
+func modifyAccount(storage storageWriter, addr common.Address, accountIdx int, r *rand.Rand, pickSlot func() int, pickDelete func() bool, values *valueStats) int64 {
+	for j := 0; j < slotsToModifyPerAccount; j++ {
+		slotIdx := pickSlot()
+		var newVal common.Hash
+		r.Read(newVal[:])
+		if pickDelete() {
+			newVal = common.Hash{}
+		}
+		// Use the same unique key pattern as in Phase 1
+		values.add(storage.SetState(addr, slotKey(accountIdx, slotIdx), newVal), newVal)
+	}
+	return slotsToModifyPerAccount
+}
+
+// transferTo updates the account fields of addr like a simple transfer would,
+// bumping the nonce and crediting a wei. Its storage is left untouched.
+func transferTo(statedb *state.StateDB, addr common.Address) {
+	statedb.SetNonce(addr, statedb.GetNonce(addr)+1, tracing.NonceChangeUnspecified)
+	statedb.AddBalance(addr, uint256.NewInt(1), tracing.BalanceChangeTransfer)
+}
+
+// applyBlocks simulates a chain of the configured number of blocks on top of
+// the created state, starting at block number first. Every block writes a new
+// random value into a fixed number of randomly picked slots and commits exactly
+// one root, so the block numbers and the state histories advance in lockstep.
+func (b *bench) applyBlocks(ctx context.Context, phase *phaseResult, first uint64) error {
+	var (
+		cfg     = b.cfg
+		slots   int64
+		touched = make([]bool, cfg.accounts)
+		start   = time.Now()
+	)
+	b.batchStart = start
+	phase.Values = new(valueStats)
+
+	rMod := cfg.rng.newRand("modify", cfg.modSeed)
+	pickSlot, pickDelete := newSlotPicker(cfg, rMod), newDeletePicker(cfg)
+	for i := 0; i < cfg.blocks; i++ {
+		for j := 0; j < cfg.txsPerBlock; j++ {
+			var (
+				accountIdx = rMod.Intn(cfg.accounts)
+				slotIdx    = pickSlot()
+				newVal     common.Hash
+			)
+			rMod.Read(newVal[:])
+			if pickDelete() {
+				newVal = common.Hash{}
+			}
+			b.touchAccount(b.addrs[accountIdx])
+			phase.Values.add(b.storage().SetState(b.addrs[accountIdx], slotKey(accountIdx, slotIdx), newVal), newVal)
+			if !touched[accountIdx] {
+				touched[accountIdx] = true
+				phase.Accounts++
+			}
+		}
+		slots += int64(cfg.txsPerBlock)
+
+		sample, err := b.commit(phase, first+uint64(i), phase.Accounts, slots)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b.out, "[Block %d] Root: %.8s | %s\n", sample.Block, sample.Root.String(), b.usage(sample))
+
+		if ctx.Err() != nil {
+			phase.finish(slots, time.Since(start), b.root)
+			phase.BlockTime = blockTimes(phase)
+			return errInterrupted
+		}
+	}
+	phase.finish(slots, time.Since(start), b.root)
+	phase.BlockTime = blockTimes(phase)
+	return nil
+}
+
+// blockTimes summarizes the total time of the blocks of a phase, including the
+// state updates in addition to the commit.
+func blockTimes(phase *phaseResult) *latencyStats {
+	durations := make([]time.Duration, len(phase.Batches))
+	for i, batch := range phase.Batches {
+		durations[i] = batch.Duration
+	}
+	stats := summarize(durations)
+	return &stats
+}
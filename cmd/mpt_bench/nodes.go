@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// nodeStats contains the trie node accesses of a single phase, as counted by
+// the path database.
+type nodeStats struct {
+	Reads        int64 `json:"reads"`     // Total trie node lookups
+	DirtyHits    int64 `json:"dirtyHits"` // Lookups served by the diff layers or the write buffer
+	CleanHits    int64 `json:"cleanHits"` // Lookups served by the clean cache
+	DiskReads    int64 `json:"diskReads"` // Lookups hitting the key-value store
+	Written      int64 `json:"written"`   // Nodes flushed into the key-value store
+	FlushedBytes int64 `json:"flushedBytes"`
+	Flushes      int64 `json:"bufferFlushes"` // Write buffer flushes into the key-value store
+}
+
+// trackNodes starts counting the trie node accesses, returning a function that
+// reports the accesses made since. The counters are only maintained by the
+// path database, nil is reported in hash mode.
+func (b *bench) trackNodes() func() *nodeStats {
+	if b.trieDB.Scheme() != rawdb.PathScheme {
+		return func() *nodeStats { return nil }
+	}
+	start := pathdb.ReadNodeStats()
+	return func() *nodeStats {
+		end := pathdb.ReadNodeStats()
+		s := &nodeStats{
+			DirtyHits:    end.DirtyHits - start.DirtyHits,
+			CleanHits:    end.CleanHits - start.CleanHits,
+			DiskReads:    end.DiskReads - start.DiskReads,
+			Written:      end.NodesFlushed - start.NodesFlushed,
+			FlushedBytes: end.BytesFlushed - start.BytesFlushed,
+			Flushes:      end.Flushes - start.Flushes,
+		}
+		s.Reads = s.DirtyHits + s.CleanHits + s.DiskReads
+		return s
+	}
+}
+
+// trackLayers starts counting the diff layers of the path database, returning
+// a function that records the layers created and merged since into res.
+func trackLayers() func(res *result) {
+	start := pathdb.ReadLayerStats()
+	return func(res *result) {
+		end := pathdb.ReadLayerStats()
+		res.LayersCreated = end.Created - start.Created
+		res.LayersMerged = end.Merged - start.Merged
+	}
+}
+
+// printNodes writes the human-readable trie node accesses of a phase into w,
+// if they were tracked.
+func printNodes(w io.Writer, s *nodeStats) {
+	if s == nil {
+		return
+	}
+	fmt.Fprintf(w, "Trie Nodes: %d read (%d dirty, %d clean, %d disk) | %d written | %.2f MB flushed in %d flushes\n",
+		s.Reads, s.DirtyHits, s.CleanHits, s.DiskReads, s.Written, float64(s.FlushedBytes)/1024/1024, s.Flushes)
+}
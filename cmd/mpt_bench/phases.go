@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// writeTarget is a set of states the creation and modification phases write to
+// at once, the shards of a sharded run or the instances sharing one store. The
+// phases run over all states, committing them together every batch.
+type writeTarget interface {
+	// createAccounts populates the configured number of accounts, stopping at
+	// the next batch boundary if ctx is cancelled.
+	createAccounts(ctx context.Context, phase *phaseResult) error
+
+	// modifyAccounts overwrites random slots of m randomly chosen accounts,
+	// committing them every batch from block first on.
+	modifyAccounts(ctx context.Context, phase *phaseResult, m int, first uint64) error
+
+	// report writes the results of the finished phase into w, title and verb
+	// naming the phase in the output.
+	report(w io.Writer, title, verb string, phase *phaseResult)
+
+	// checkRoot verifies the root reached by the phase, if want is set.
+	checkRoot(phase *phaseResult, want *common.Hash) error
+}
+
+// runWritePhases executes the creation and modification phases of cfg on the
+// states of t, collecting the results in res. The states are described by
+// where in the output, e.g. "over 4 shards". If ctx is cancelled, the running
+// phase stops at its next batch boundary and errInterrupted is returned.
+func runWritePhases(ctx context.Context, cfg *config, out io.Writer, t writeTarget, where string, res *result) error {
+	// Phase 1: Creation
+	fmt.Fprintf(out, "Phase 1: Creating %d accounts %s (avg %d slots, k=%d)...\n", cfg.accounts, where, cfg.slots, cfg.batch)
+	allocs := trackAllocs()
+	err := t.createAccounts(ctx, res.Creation)
+	res.Creation.Allocs = allocs(res.Creation.Slots)
+	if err != nil {
+		return err
+	}
+	t.report(out, "Creation", "Created", res.Creation)
+	if err := t.checkRoot(res.Creation, cfg.expectRoot); err != nil {
+		return err
+	}
+
+	// Phase 2: Modification
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if cfg.slots == 0 && cfg.modMode != modModeBalance {
+		fmt.Fprintln(out, "\nPhase 2: Skipped, the accounts have no slots to modify")
+		return checkRoot("modification", cfg.expectModRoot, res.Creation.Root)
+	}
+	mModify := min(cfg.modify, cfg.accounts)
+	if cfg.dist == distZipf {
+		res.ZipfS, res.ZipfV = cfg.zipfS, cfg.zipfV
+	}
+	first, err := cfg.phase2Start(uint64((cfg.accounts + cfg.batch - 1) / cfg.batch))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nPhase 2: Randomly modifying %d accounts %s from block %d (mode=%s, k=%d, dist=%s)...\n", mModify, where, first, cfg.modMode, cfg.batch, cfg.dist)
+	allocs = trackAllocs()
+	err = t.modifyAccounts(ctx, res.Modification, mModify, first)
+	res.Modification.Allocs = allocs(res.Modification.Slots)
+	if err != nil {
+		return err
+	}
+	t.report(out, "Modification", "Modified", res.Modification)
+	return t.checkRoot(res.Modification, cfg.expectModRoot)
+}
+
+// finishPhases handles the error the phases of a run returned. An interruption
+// is reported and flagged in res, the results gathered so far are still valid,
+// any other error is returned.
+func finishPhases(out io.Writer, res *result, err error) error {
+	if errors.Is(err, errInterrupted) {
+		fmt.Fprintln(out, "\nInterrupted, skipping the remaining phases")
+		res.Interrupted = true
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// preimageStats contains the activity of the preimage recording and its two
+// caches: the hashed key preimages every state trie holds until its commit, and
+// the preimages the trie database holds before flushing them into the disk.
+type preimageStats struct {
+	CacheMB   int     `json:"cacheMB"`        // Allowance of the trie database cache, 0: flushed every commit
+	KeyLimit  int     `json:"keyCacheLimit"`  // Preimages a state trie caches at most, 0: until its commit
+	Cached    int64   `json:"cachedBytes"`    // Held by the trie database cache at the end of the run
+	Peak      int64   `json:"peakCacheBytes"` // Largest size the trie database cache reached
+	Flushes   int     `json:"flushes"`
+	Flushed   int     `json:"flushedPreimages"`
+	Hits      int64   `json:"cacheHits"`
+	Misses    int64   `json:"cacheMisses"` // Lookups read from the disk
+	HitRate   float64 `json:"cacheHitRate"`
+	KeyHits   int64   `json:"keyCacheHits"`
+	KeyMisses int64   `json:"keyCacheMisses"`
+	KeyRate   float64 `json:"keyCacheHitRate"`
+	Handovers int64   `json:"keyCacheHandovers"` // Preimages handed over before the commit by a full key cache
+}
+
+// trackPreimages starts counting the key cache activity of the state tries,
+// returning a function that collects it along with the activity of the
+// preimage store of the current trie database. The store is replaced when
+// reopening the databases, only the last one is reported.
+func (b *bench) trackPreimages() func() *preimageStats {
+	start := trie.ReadSecKeyCacheStats()
+	return func() *preimageStats {
+		store := b.trieDB.PreimageStats()
+		if store == nil {
+			return nil
+		}
+		keys := trie.ReadSecKeyCacheStats()
+		s := &preimageStats{
+			CacheMB:   b.cfg.preimageMB,
+			KeyLimit:  b.cfg.secKeyLimit,
+			Cached:    int64(store.Size),
+			Peak:      int64(store.Peak),
+			Flushes:   store.Flushes,
+			Flushed:   store.Flushed,
+			Hits:      store.Hits,
+			Misses:    store.Misses,
+			KeyHits:   keys.Hits - start.Hits,
+			KeyMisses: keys.Misses - start.Misses,
+			Handovers: keys.Handovers - start.Handovers,
+		}
+		if n := s.Hits + s.Misses; n > 0 {
+			s.HitRate = float64(s.Hits) / float64(n)
+		}
+		if n := s.KeyHits + s.KeyMisses; n > 0 {
+			s.KeyRate = float64(s.KeyHits) / float64(n)
+		}
+		return s
+	}
+}
+
+// printPreimages writes the activity of the preimage caches into w, if the
+// preimages were recorded.
+func printPreimages(w io.Writer, s *preimageStats) {
+	if s == nil {
+		return
+	}
+	fmt.Fprintf(w, "Preimages:     %.2f MB cache peak, %.2f MB at the end (allowance %d MB) | %d flushes of %d preimages\n",
+		float64(s.Peak)/(1024*1024), float64(s.Cached)/(1024*1024), s.CacheMB, s.Flushes, s.Flushed)
+	if s.Hits+s.Misses > 0 {
+		fmt.Fprintf(w, "Preimage Hits: %d cached, %d from disk (%.2f%% hit rate)\n", s.Hits, s.Misses, s.HitRate*100)
+	}
+	if s.KeyLimit > 0 {
+		fmt.Fprintf(w, "Key Cache:     %d preimages per trie | %d handed over early", s.KeyLimit, s.Handovers)
+	} else {
+		fmt.Fprintf(w, "Key Cache:     unbounded until the commit")
+	}
+	if s.KeyHits+s.KeyMisses > 0 {
+		fmt.Fprintf(w, " | %d hits, %d misses (%.2f%% hit rate)", s.KeyHits, s.KeyMisses, s.KeyRate*100)
+	}
+	fmt.Fprintln(w)
+}
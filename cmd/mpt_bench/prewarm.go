@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// prewarmStats contains the cold read pass run before the caches are
+// prewarmed for the random reads of phase 3, and the time filling them took.
+type prewarmStats struct {
+	Accounts int           `json:"accounts"` // Accounts whose slots were touched
+	Slots    int64         `json:"slots"`
+	Elapsed  time.Duration `json:"elapsedNs"`
+	Cold     *readResult   `json:"coldReads"` // Same reads as phase 3, right after reopening the databases
+}
+
+// prewarm measures the random reads of phase 3 on cold caches, then fills the
+// caches the reads go through: the databases are reopened, dropping the cold
+// pass, and every slot of the accounts the reads draw from is read once. This
+// loads its flat state entry or trie nodes, whichever the scheme reads the
+// slots from, into the clean caches of the trie database and the blocks
+// holding them into the Pebble block cache. The following reads then measure
+// the in-memory lookups, as far as the caches hold the state.
+func (b *bench) prewarm(n int) (*prewarmStats, error) {
+	if _, err := b.reopen(); err != nil {
+		return nil, err
+	}
+	statedb, err := state.New(b.root, b.sdb)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state: %v", err)
+	}
+	res := new(prewarmStats)
+	if res.Cold, err = b.timeReads(statedb, n, "cold reads"); err != nil {
+		return nil, err
+	}
+	if _, err := b.reopen(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	if statedb, err = state.New(b.root, b.sdb); err != nil {
+		return nil, fmt.Errorf("failed to open state: %v", err)
+	}
+	candidates := b.readCandidates()
+	for i, accountIdx := range candidates {
+		addr := b.addrs[accountIdx]
+		for slotIdx := 0; slotIdx < b.slotCounts[accountIdx]; slotIdx++ {
+			statedb.GetState(addr, slotKey(accountIdx, slotIdx))
+		}
+		res.Slots += int64(b.slotCounts[accountIdx])
+		if (i+1)%1000 == 0 || i+1 == len(candidates) {
+			b.progress.update("prewarm", 0, int64(i+1), "...prewarmed %d/%d accounts (%.1f%%)", i+1, len(candidates), float64(i+1)/float64(len(candidates))*100)
+		}
+	}
+	res.Elapsed = time.Since(start)
+	res.Accounts = len(candidates)
+	if err := statedb.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read state: %v", err)
+	}
+	return res, nil
+}
+
+// printPrewarm writes the cache fill and the cold reads into w, contrasted
+// with the reads on the prewarmed caches, if prewarmed.
+func printPrewarm(w io.Writer, p *prewarmStats, warm *readResult) {
+	if p == nil {
+		return
+	}
+	fmt.Fprintf(w, "Prewarm:       %d slots of %d accounts in %v\n", p.Slots, p.Accounts, p.Elapsed)
+	fmt.Fprintf(w, "Cold Reads:    %.2f reads/s | P95 Latency: %v", p.Cold.Throughput, p.Cold.P95)
+	if warm != nil && p.Cold.Throughput > 0 {
+		fmt.Fprintf(w, " (prewarmed %.2fx the throughput)", warm.Throughput/p.Cold.Throughput)
+	}
+	fmt.Fprintln(w)
+}
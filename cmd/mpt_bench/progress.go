@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// progress prints the running progress of the phase loops. On a terminal the
+// progress line is rewritten in place, otherwise every update is a line of its
+// own. With -progress-interval, the lines give way to a structured record
+// written to stderr at that interval, for log files and orchestrators.
+type progress struct {
+	out      io.Writer
+	tty      bool          // Whether out is a terminal, rewriting the line in place
+	interval time.Duration // Interval of the structured records, 0 to print lines
+	dbPath   string
+	start    time.Time
+	last     time.Time // Time the last record was emitted at
+	records  *json.Encoder
+}
+
+// progressRecord is a structured progress record of -progress-interval.
+type progressRecord struct {
+	Time     time.Time `json:"time"`
+	Phase    string    `json:"phase"`
+	Accounts int       `json:"accounts"`
+	Slots    int64     `json:"slots"`
+	DiskMB   float64   `json:"diskMB"`
+	Elapsed  float64   `json:"elapsedSeconds"`
+}
+
+func newProgress(cfg *config, out io.Writer) *progress {
+	now := time.Now()
+	p := &progress{
+		out:      out,
+		interval: cfg.progressInterval,
+		dbPath:   cfg.dbPath,
+		start:    now,
+		last:     now,
+		records:  json.NewEncoder(os.Stderr),
+	}
+	if f, ok := out.(*os.File); ok {
+		p.tty = isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return p
+}
+
+// update reports the accounts and slots the named phase has gone through. In
+// line mode it prints the line described by format and args, otherwise it
+// emits a record once the interval has passed since the last one.
+func (p *progress) update(phase string, accounts int, slots int64, format string, args ...any) {
+	if p.interval == 0 {
+		end := "\n"
+		if p.tty {
+			end = "\r"
+		}
+		fmt.Fprintf(p.out, format+end, args...)
+		return
+	}
+	now := time.Now()
+	if now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	p.records.Encode(progressRecord{
+		Time:     now,
+		Phase:    phase,
+		Accounts: accounts,
+		Slots:    slots,
+		DiskMB:   float64(getDirSize(p.dbPath)) / (1024 * 1024),
+		Elapsed:  now.Sub(p.start).Seconds(),
+	})
+}
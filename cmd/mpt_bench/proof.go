@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+)
+
+// maxProofAttempts bounds the draws per proof while looking for an existing
+// slot, as a fraction of the created slots is zero and thus absent.
+const maxProofAttempts = 100
+
+// proofResult contains the measurements of the proof phase. Every proof is a
+// pair of an account proof and a storage proof of one of its slots.
+type proofResult struct {
+	Proofs     int           `json:"proofs"`
+	Missing    bool          `json:"missing"` // Whether exclusion proofs of absent keys were generated
+	Elapsed    time.Duration `json:"elapsedNs"`
+	Throughput float64       `json:"proofsPerSecond"`
+
+	AccountNodes float64 `json:"avgAccountProofNodes"`
+	AccountBytes float64 `json:"avgAccountProofBytes"`
+	StorageNodes float64 `json:"avgStorageProofNodes"`
+	StorageBytes float64 `json:"avgStorageProofBytes"`
+
+	VerifyElapsed    time.Duration `json:"verifyElapsedNs,omitempty"`
+	VerifyThroughput float64       `json:"verifiedPerSecond,omitempty"`
+}
+
+// proofTarget is a key pair to prove along with the generated proofs.
+type proofTarget struct {
+	addr        common.Address // Account to prove
+	owner       common.Address // Account owning the storage trie of the slot
+	slot        common.Hash
+	storageRoot common.Hash
+	account     *trienode.ProofSet
+	storage     *trienode.ProofSet
+}
+
+// generateProofs creates n account and storage proofs for random keys at the
+// current root, optionally verifying them afterwards. If missing is set, the
+// proven keys don't exist and exclusion proofs are created instead.
+func (b *bench) generateProofs(n int, missing, verify bool) (*proofResult, error) {
+	targets, err := b.proofTargets(n, missing)
+	if err != nil {
+		return nil, err
+	}
+	accTrie, err := trie.NewStateTrie(trie.StateTrieID(b.root), b.trieDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open account trie: %v", err)
+	}
+	var (
+		res   = &proofResult{Proofs: n, Missing: missing}
+		start = time.Now()
+	)
+	for i, t := range targets {
+		t.account = trienode.NewProofSet()
+		if err := accTrie.Prove(crypto.Keccak256(t.addr.Bytes()), t.account); err != nil {
+			return nil, fmt.Errorf("failed to prove account %x: %v", t.addr, err)
+		}
+		id := trie.StorageTrieID(b.root, crypto.Keccak256Hash(t.owner.Bytes()), t.storageRoot)
+		storageTrie, err := trie.NewStateTrie(id, b.trieDB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open storage trie of %x: %v", t.owner, err)
+		}
+		t.storage = trienode.NewProofSet()
+		if err := storageTrie.Prove(crypto.Keccak256(t.slot.Bytes()), t.storage); err != nil {
+			return nil, fmt.Errorf("failed to prove slot %x of %x: %v", t.slot, t.owner, err)
+		}
+		if (i+1)%1000 == 0 || i+1 == n {
+			b.progress.update("proofs", 0, int64(i+1), "...generated %d/%d proofs (%.1f%%)", i+1, n, float64(i+1)/float64(n)*100)
+		}
+	}
+	res.Elapsed = time.Since(start)
+	if secs := res.Elapsed.Seconds(); secs > 0 {
+		res.Throughput = float64(n) / secs
+	}
+	for _, t := range targets {
+		res.AccountNodes += float64(t.account.KeyCount())
+		res.AccountBytes += float64(t.account.DataSize())
+		res.StorageNodes += float64(t.storage.KeyCount())
+		res.StorageBytes += float64(t.storage.DataSize())
+	}
+	res.AccountNodes /= float64(n)
+	res.AccountBytes /= float64(n)
+	res.StorageNodes /= float64(n)
+	res.StorageBytes /= float64(n)
+
+	if !verify {
+		return res, nil
+	}
+	start = time.Now()
+	for _, t := range targets {
+		val, err := trie.VerifyProof(b.root, crypto.Keccak256(t.addr.Bytes()), t.account)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof of account %x: %v", t.addr, err)
+		}
+		if (val == nil) != missing {
+			return nil, fmt.Errorf("unexpected presence of account %x in proof", t.addr)
+		}
+		val, err = trie.VerifyProof(t.storageRoot, crypto.Keccak256(t.slot.Bytes()), t.storage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof of slot %x of %x: %v", t.slot, t.owner, err)
+		}
+		if (val == nil) != missing {
+			return nil, fmt.Errorf("unexpected presence of slot %x of %x in proof", t.slot, t.owner)
+		}
+	}
+	res.VerifyElapsed = time.Since(start)
+	if secs := res.VerifyElapsed.Seconds(); secs > 0 {
+		res.VerifyThroughput = float64(n) / secs
+	}
+	return res, nil
+}
+
+// proofTargets picks the keys to prove. Existing slots are drawn from the
+// accounts created in phase 1, missing ones use indices past any account or
+// slot ever created.
+func (b *bench) proofTargets(n int, missing bool) ([]*proofTarget, error) {
+	var candidates []int
+	for i, count := range b.slotCounts {
+		if count > 0 {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no slots available to prove")
+	}
+	var (
+		r       = b.cfg.rng.newRand("proof", 42)
+		targets = make([]*proofTarget, 0, n)
+	)
+	for len(targets) < n {
+		accountIdx := candidates[r.Intn(len(candidates))]
+		addr := b.addrs[accountIdx]
+		if missing {
+			// An absent account, and an absent slot of an existing one. Skip
+			// the accounts whose slots are all zero, leaving no trie to prove
+			// against.
+			storageRoot := b.statedb.GetStorageRoot(addr)
+			if storageRoot == types.EmptyRootHash {
+				continue
+			}
+			targets = append(targets, &proofTarget{
+				addr:        accountAddress(b.cfg.addrMode, len(b.addrs)+len(targets)),
+				owner:       addr,
+				slot:        slotKey(accountIdx, 2*b.cfg.slots+len(targets)),
+				storageRoot: storageRoot,
+			})
+			continue
+		}
+		for attempt := 0; attempt < maxProofAttempts; attempt++ {
+			slot := slotKey(accountIdx, r.Intn(b.slotCounts[accountIdx]))
+			if b.statedb.GetState(addr, slot) != (common.Hash{}) {
+				targets = append(targets, &proofTarget{
+					addr:        addr,
+					owner:       addr,
+					slot:        slot,
+					storageRoot: b.statedb.GetStorageRoot(addr),
+				})
+				break
+			}
+		}
+	}
+	if err := b.statedb.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read state: %v", err)
+	}
+	return targets, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// readResult contains the measurements of the random-read phase.
+type readResult struct {
+	Reads      int           `json:"reads"`
+	Found      int           `json:"found"` // Reads that returned a non-empty value
+	Elapsed    time.Duration `json:"elapsedNs"`
+	Throughput float64       `json:"readsPerSecond"`
+	P95        time.Duration `json:"p95Ns"`
+
+	Nodes *nodeStats  `json:"trieNodes,omitempty"`     // Path mode only
+	Bloom *bloomStats `json:"snapshotBloom,omitempty"` // Snapshot mode only
+
+	AccountCacheHit  int64 `json:"accountCacheHit"`
+	AccountCacheMiss int64 `json:"accountCacheMiss"`
+	StorageCacheHit  int64 `json:"storageCacheHit"`
+	StorageCacheMiss int64 `json:"storageCacheMiss"`
+}
+
+// readSlots performs n random GetState calls against slots written during the
+// creation phase. The state is opened fresh at the latest root and no writes
+// happen in the meantime, so only the lookup cost is measured.
+func (b *bench) readSlots(n int) (*readResult, error) {
+	reader, _, err := b.sdb.ReadersWithCacheStats(b.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state reader: %v", err)
+	}
+	statedb, err := state.NewWithReader(b.root, b.sdb, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state: %v", err)
+	}
+	res, err := b.timeReads(statedb, n, "reads")
+	if err != nil {
+		return nil, err
+	}
+	stats := reader.GetStats()
+	res.AccountCacheHit, res.AccountCacheMiss = stats.AccountCacheHit, stats.AccountCacheMiss
+	res.StorageCacheHit, res.StorageCacheMiss = stats.StorageCacheHit, stats.StorageCacheMiss
+	return res, nil
+}
+
+// readCandidates returns the indexes of the accounts created with slots.
+func (b *bench) readCandidates() []int {
+	var candidates []int
+	for i, count := range b.slotCounts {
+		if count > 0 {
+			candidates = append(candidates, i)
+		}
+	}
+	return candidates
+}
+
+// timeReads performs n random GetState calls on statedb against slots written
+// during the creation phase, timing each of them. The slots are drawn from a
+// fixed seed, every state read from gets the same sequence of lookups.
+func (b *bench) timeReads(statedb *state.StateDB, n int, name string) (*readResult, error) {
+	candidates := b.readCandidates()
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no slots available to read")
+	}
+	var (
+		r         = b.cfg.rng.newRand("read", 42)
+		res       = &readResult{Reads: n}
+		latencies = make([]time.Duration, 0, n)
+		start     = time.Now()
+	)
+	for i := 0; i < n; i++ {
+		accountIdx := candidates[r.Intn(len(candidates))]
+		slotIdx := r.Intn(b.slotCounts[accountIdx])
+		addr := b.addrs[accountIdx]
+		key := slotKey(accountIdx, slotIdx)
+
+		readStart := time.Now()
+		val := statedb.GetState(addr, key)
+		latencies = append(latencies, time.Since(readStart))
+
+		if val != (common.Hash{}) {
+			res.Found++
+		}
+		if (i+1)%1000 == 0 || i+1 == n {
+			b.progress.update(name, 0, int64(i+1), "...read %d/%d slots (%.1f%%)", i+1, n, float64(i+1)/float64(n)*100)
+		}
+	}
+	res.Elapsed = time.Since(start)
+	if err := statedb.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read state: %v", err)
+	}
+	if secs := res.Elapsed.Seconds(); secs > 0 {
+		res.Throughput = float64(n) / secs
+	}
+	res.P95 = percentile(latencies, 95)
+	return res, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// readLoadResult contains the measurements of the concurrent readers of the
+// creation phase. The readers only run during every other batch, the batches
+// without them serve as the baseline of the write throughput.
+type readLoadResult struct {
+	Readers    int           `json:"readers"`
+	Lookups    int64         `json:"lookups"` // Account and slot lookups completed
+	Stale      int64         `json:"stale"`   // Lookups retried as their root was flattened meanwhile
+	Active     time.Duration `json:"activeNs"`
+	Throughput float64       `json:"lookupsPerSecond"` // While the readers were active
+
+	WriteLoaded   float64 `json:"loadedSlotsPerSecond"`   // Write throughput of the batches with readers
+	WriteBaseline float64 `json:"baselineSlotsPerSecond"` // Write throughput of the batches without readers
+}
+
+// readHead is the latest committed state the readers read from.
+type readHead struct {
+	root     common.Hash
+	accounts int // Accounts committed up to the root
+}
+
+// readLoad runs goroutines continuously reading random accounts and slots from
+// the latest committed root, while the writes of the next batch go on. Every
+// reader opens its own state reader, as the StateDB being written to is not
+// safe for concurrent use.
+type readLoad struct {
+	cfg *config
+	sdb *state.CachingDB
+
+	head    atomic.Pointer[readHead]
+	active  atomic.Bool
+	lookups atomic.Int64
+	stale   atomic.Int64
+
+	since  time.Time     // Time the readers were last resumed, if active
+	total  time.Duration // Total time the readers were active
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	result *readLoadResult
+}
+
+// startReadLoad starts the given number of paused readers on top of sdb.
+func startReadLoad(cfg *config, sdb *state.CachingDB, readers int) *readLoad {
+	l := &readLoad{
+		cfg:    cfg,
+		sdb:    sdb,
+		quit:   make(chan struct{}),
+		result: &readLoadResult{Readers: readers},
+	}
+	l.head.Store(&readHead{})
+	for i := 0; i < readers; i++ {
+		l.wg.Add(1)
+		go l.loop(int64(i))
+	}
+	return l
+}
+
+// loop reads random keys of the committed accounts until the load is stopped.
+func (l *readLoad) loop(seed int64) {
+	defer l.wg.Done()
+
+	var (
+		r      = rand.New(rand.NewSource(seed))
+		reader state.Reader
+		root   common.Hash
+	)
+	for {
+		select {
+		case <-l.quit:
+			return
+		default:
+		}
+		head := l.head.Load()
+		if !l.active.Load() || head.accounts == 0 {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if reader == nil || root != head.root {
+			var err error
+			if reader, err = l.sdb.Reader(head.root); err != nil {
+				l.stale.Add(1)
+				continue
+			}
+			root = head.root
+		}
+		idx := r.Intn(head.accounts)
+		addr := accountAddress(l.cfg.addrMode, idx)
+		if _, err := reader.Account(addr); err != nil {
+			// The layer of the root was flattened by a newer commit, move on
+			reader = nil
+			l.stale.Add(1)
+			continue
+		}
+		if _, err := reader.Storage(addr, slotKey(idx, r.Intn(max(2*l.cfg.slots, 1)))); err != nil {
+			reader = nil
+			l.stale.Add(1)
+			continue
+		}
+		l.lookups.Add(2)
+	}
+}
+
+// update points the readers at a freshly committed root.
+func (l *readLoad) update(root common.Hash, accounts int) {
+	l.head.Store(&readHead{root: root, accounts: accounts})
+}
+
+// toggle resumes the paused readers, or pauses the active ones.
+func (l *readLoad) toggle() {
+	if l.active.Load() {
+		l.active.Store(false)
+		l.total += time.Since(l.since)
+		return
+	}
+	l.since = time.Now()
+	l.active.Store(true)
+}
+
+// stop terminates the readers and summarizes the read load along with the
+// write throughput of the batches of phase with and without readers.
+func (l *readLoad) stop(phase *phaseResult) *readLoadResult {
+	if l.active.Load() {
+		l.toggle()
+	}
+	close(l.quit)
+	l.wg.Wait()
+
+	res := l.result
+	res.Lookups = l.lookups.Load()
+	res.Stale = l.stale.Load()
+	res.Active = l.total
+	if secs := l.total.Seconds(); secs > 0 {
+		res.Throughput = float64(res.Lookups) / secs
+	}
+	var (
+		slots [2]int64
+		spent [2]time.Duration
+		prev  int64
+	)
+	for _, batch := range phase.Batches {
+		i := 0
+		if batch.Readers {
+			i = 1
+		}
+		slots[i] += batch.Slots - prev
+		spent[i] += batch.Duration
+		prev = batch.Slots
+	}
+	if spent[0] > 0 {
+		res.WriteBaseline = float64(slots[0]) / spent[0].Seconds()
+	}
+	if spent[1] > 0 {
+		res.WriteLoaded = float64(slots[1]) / spent[1].Seconds()
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// reorgStats contains the measurements of the simulated chain reorgs, the
+// rollbacks to an earlier root and the re-execution of divergent batches on
+// top of it. The commits of the re-executed batches are recorded in the reorg
+// phase, the rollbacks are timed apart.
+type reorgStats struct {
+	Depth    int           `json:"depth"` // Batches rolled back per reorg
+	Reorgs   int           `json:"reorgs"`
+	Layers   int           `json:"diffLayerReorgs"`    // Reorgs forking off a root still in the diff layers
+	Reverted int           `json:"historyReorgs"`      // Reorgs reverting the disk layer through the state history
+	Rollback latencyStats  `json:"rollbackLatency"`    // Rollback to the earlier root, until its state is open
+	Reexec   latencyStats  `json:"reexecutionLatency"` // Re-execution of the divergent batches of a reorg
+	Total    time.Duration `json:"elapsedNs"`          // Rollbacks and re-executions together
+}
+
+// applyReorgs simulates n chain reorgs of depth batches each. Every reorg rolls
+// the state back to the root committed depth batches before the current one
+// and commits as many batches of modifications on top, diverging from the
+// rolled back ones. A root still held by the diff layers of pathdb is simply
+// forked off, an older one requires reverting the disk layer through the
+// state history, which fails if the history is pruned already.
+func (b *bench) applyReorgs(ctx context.Context, phase *phaseResult, stats *reorgStats, n, depth int) error {
+	var (
+		cfg       = b.cfg
+		slots     int64
+		rollbacks = make([]time.Duration, 0, n)
+		reexecs   = make([]time.Duration, 0, n)
+		start     = time.Now()
+	)
+	phase.Values = new(valueStats)
+	defer func() {
+		stats.Rollback, stats.Reexec = summarize(rollbacks), summarize(reexecs)
+		stats.Total = time.Since(start)
+	}()
+	for i := 0; i < n; i++ {
+		if depth >= len(b.roots) {
+			return fmt.Errorf("reorg %d: the run committed %d roots, none of them %d batches back", i+1, len(b.roots), depth)
+		}
+		// Roll back to the earlier root, dropping the abandoned ones
+		rollStart := time.Now()
+		target := b.roots[len(b.roots)-1-depth]
+		if _, err := b.trieDB.StateReader(target); err == nil {
+			stats.Layers++
+		} else {
+			if ok, _ := b.trieDB.Recoverable(target); !ok {
+				return fmt.Errorf("reorg %d: root %x %d batches back is not retained by the state history (limit: %s)", i+1, target, depth, historyString(cfg.history))
+			}
+			if err := b.trieDB.Recover(target); err != nil {
+				return fmt.Errorf("reorg %d: failed to roll back to %x: %v", i+1, target, err)
+			}
+			stats.Reverted++
+		}
+		statedb, err := state.New(target, b.sdb)
+		if err != nil {
+			return fmt.Errorf("reorg %d: state %x is not available: %v", i+1, target, err)
+		}
+		b.statedb, b.root, b.roots = statedb, target, b.roots[:len(b.roots)-depth]
+		b.next -= uint64(depth) // The divergent batches take over the rolled back block numbers
+		rollbacks = append(rollbacks, time.Since(rollStart))
+
+		// Re-execute as many batches, seeded apart from phase 2 and each other
+		var (
+			reexecStart = time.Now()
+			r           = cfg.rng.newRand("reorg", cfg.modSeed+int64(i)+1)
+			pickSlot    = newSlotPicker(cfg, r)
+			pickDelete  = newDeletePicker(cfg)
+		)
+		b.batchStart = reexecStart
+		for j := 0; j < depth; j++ {
+			for k := 0; k < cfg.batch; k++ {
+				accountIdx := r.Intn(len(b.addrs))
+				slots += modifyAccount(b.storage(), b.addrs[accountIdx], accountIdx, r, pickSlot, pickDelete, phase.Values)
+			}
+			sample, err := b.commit(phase, b.next, (i*depth+j+1)*cfg.batch, slots)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b.out, "\n[Reorg %d Batch] %s\n", i+1, b.usage(sample))
+		}
+		reexecs = append(reexecs, time.Since(reexecStart))
+		stats.Reorgs++
+
+		b.progress.update(phase.Name, i+1, slots, "...applied %d/%d reorgs (%.1f%%)", i+1, n, float64(i+1)/float64(n)*100)
+		if ctx.Err() != nil {
+			phase.Accounts = (i + 1) * depth * cfg.batch
+			phase.finish(slots, time.Since(start), b.root)
+			return errInterrupted
+		}
+	}
+	phase.finish(slots, time.Since(start), b.root)
+	return nil
+}
+
+// printReorgs writes the human-readable reorg measurements into w.
+func printReorgs(w io.Writer, s *reorgStats) {
+	if s == nil {
+		return
+	}
+	fmt.Fprintf(w, "Reorgs:        %d of depth %d in %v (%d forked off a diff layer, %d reverted through the state history)\n",
+		s.Reorgs, s.Depth, s.Total.Round(time.Millisecond), s.Layers, s.Reverted)
+	fmt.Fprintf(w, "               rollback:     %v\n", s.Rollback)
+	fmt.Fprintf(w, "               re-execution: %v\n", s.Reexec)
+}
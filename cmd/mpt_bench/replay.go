@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/holiman/uint256"
+)
+
+// The operations of a replay file, one per line.
+const (
+	replaySetBalance = "SET_BALANCE" // SET_BALANCE <address> <wei>
+	replaySetState   = "SET_STATE"   // SET_STATE <address> <key> <value>
+	replayDelete     = "DELETE"      // DELETE <address>
+	replayCommit     = "COMMIT"      // COMMIT
+)
+
+// replayOp is a single parsed operation of a replay file.
+type replayOp struct {
+	kind    string
+	addr    common.Address
+	key     common.Hash
+	value   common.Hash
+	balance *uint256.Int
+}
+
+// parseReplayOp parses a non-empty line of a replay file. Addresses are 20 byte
+// hex strings, slot keys and values hex words of up to 32 bytes, left padded
+// with zeroes, and balances decimal or 0x prefixed hex numbers.
+func parseReplayOp(line string) (replayOp, error) {
+	fields := strings.Fields(line)
+	op := replayOp{kind: fields[0]}
+
+	want := map[string]int{replaySetBalance: 3, replaySetState: 4, replayDelete: 2, replayCommit: 1}[op.kind]
+	if want == 0 {
+		return op, fmt.Errorf("unknown operation %q, want one of %s, %s, %s or %s", op.kind, replaySetBalance, replaySetState, replayDelete, replayCommit)
+	}
+	if len(fields) != want {
+		return op, fmt.Errorf("%s takes %d arguments, have %d", op.kind, want-1, len(fields)-1)
+	}
+	if op.kind == replayCommit {
+		return op, nil
+	}
+	if !common.IsHexAddress(fields[1]) {
+		return op, fmt.Errorf("invalid address %q", fields[1])
+	}
+	op.addr = common.HexToAddress(fields[1])
+
+	var err error
+	switch op.kind {
+	case replaySetBalance:
+		if strings.HasPrefix(fields[2], "0x") {
+			op.balance, err = uint256.FromHex(fields[2])
+		} else {
+			op.balance, err = uint256.FromDecimal(fields[2])
+		}
+		if err != nil {
+			return op, fmt.Errorf("invalid balance %q: %v", fields[2], err)
+		}
+	case replaySetState:
+		if op.key, err = parseWord(fields[2]); err != nil {
+			return op, fmt.Errorf("invalid slot key %q: %v", fields[2], err)
+		}
+		if op.value, err = parseWord(fields[3]); err != nil {
+			return op, fmt.Errorf("invalid slot value %q: %v", fields[3], err)
+		}
+	}
+	return op, nil
+}
+
+// parseWord parses a hex word of up to 32 bytes, optionally 0x prefixed, left
+// padding it to a full hash.
+func parseWord(s string) (common.Hash, error) {
+	digits := strings.TrimPrefix(s, "0x")
+	if len(digits) == 0 || len(digits) > 2*common.HashLength {
+		return common.Hash{}, fmt.Errorf("want 1 to %d hex digits, have %d", 2*common.HashLength, len(digits))
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	word, err := hex.DecodeString(digits)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(word), nil
+}
+
+// replayStats contains the operations applied from a replay file.
+type replayStats struct {
+	Path     string  `json:"path"`
+	Ops      int64   `json:"operations"` // State operations, excluding the commits
+	OpRate   float64 `json:"operationsPerSecond"`
+	Balances int64   `json:"balances"`
+	Slots    int64   `json:"slots"`
+	Deletes  int64   `json:"deletes"`
+	Commits  int     `json:"commits"`
+	Trailing bool    `json:"trailingCommit,omitempty"` // Operations after the last COMMIT were committed at the end
+}
+
+// replayPhase replays the operations file of the configuration in place of the
+// creation and modification phases, checking the final root if expected.
+func (b *bench) replayPhase(ctx context.Context, res *result) error {
+	var (
+		cfg = b.cfg
+		out = b.out
+	)
+	res.Creation = nil
+	res.Replay = &phaseResult{Name: "replay"}
+
+	fmt.Fprintf(out, "Replaying the operations of %s...\n", cfg.replayPath)
+	nodes, writes, allocs := b.trackNodes(), b.trackWrites(), trackAllocs()
+	if cfg.trackAccess {
+		res.Replay.Access = new(accessStats)
+		b.access = res.Replay.Access
+	}
+	err := b.replay(ctx, res.Replay, cfg.replayPath)
+	b.access = nil
+	if err != nil {
+		return err
+	}
+	res.Replay.Allocs = allocs(res.Replay.Slots)
+	res.Replay.Nodes = nodes()
+	if cfg.verkle {
+		res.Replay.Commitment = commitments(res.Replay.Nodes)
+	}
+	res.Replay.Writes = writes(logicalBytes(res.Replay.Accounts, res.Replay.Slots, 0))
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Replay finished in %v. Final Root: %x\n", res.Replay.Elapsed, b.root)
+	b.milestone(res.Replay)
+	fmt.Fprintf(out, "Total Slots Written: %d | Throughput: %.2f slots/s\n", res.Replay.Slots, res.Replay.Throughput)
+	printReplay(out, res.Replay.Replay)
+	fmt.Fprintf(out, "Commit Latency: %v\n", res.Replay.Latency)
+	printHashing(out, res.Replay)
+	printBreakdown(out, res.Replay)
+	printSys(out, res.Replay)
+	printOpens(out, res.Replay)
+	printNodes(out, res.Replay.Nodes)
+	printAmplification(out, res.Replay.Writes)
+	printAccess(out, res.Replay.Access)
+	if err := checkRoot("replay", cfg.expectRoot, b.root); err != nil {
+		return err
+	}
+	if cfg.verifyTrie {
+		fmt.Fprintf(out, "Verifying trie at root %x...\n", b.root)
+		if res.Replay.Verify, err = b.verifyTrie(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		printVerify(out, res.Replay.Verify)
+	}
+	return nil
+}
+
+// replay applies the operations of the file at path on top of the current
+// state, committing at every COMMIT line and once more at the end if any
+// operation follows the last one. Replacing the synthetic workload, the same
+// file always yields the same root, regardless of the scheme and layout.
+func (b *bench) replay(ctx context.Context, phase *phaseResult, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open replay file: %v", err)
+	}
+	defer f.Close()
+
+	var (
+		start   = time.Now()
+		stats   = &replayStats{Path: path}
+		touched = make(map[common.Address]struct{})
+		pending bool // Whether any operation follows the last commit
+		scanner = bufio.NewScanner(f)
+	)
+	b.batchStart = start
+	phase.Values = new(valueStats)
+	phase.Replay = stats
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	commit := func() error {
+		stats.Commits++
+		sample, err := b.commit(phase, uint64(stats.Commits), len(touched), stats.Slots)
+		if err != nil {
+			return err
+		}
+		pending = false
+		fmt.Fprintf(b.out, "\n[Commit %d] Root: %.8s | %s\n", sample.Batch, sample.Root.String(), b.usage(sample))
+		return nil
+	}
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		op, err := parseReplayOp(text)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if op.kind == replayCommit {
+			if err := commit(); err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				stats.finish(phase, len(touched), b.root, time.Since(start))
+				return errInterrupted
+			}
+			continue
+		}
+		stats.Ops++
+		pending = true
+		touched[op.addr] = struct{}{}
+		b.touchAccount(op.addr)
+
+		switch op.kind {
+		case replaySetBalance:
+			b.statedb.SetBalance(op.addr, op.balance, tracing.BalanceChangeUnspecified)
+			stats.Balances++
+		case replaySetState:
+			phase.Values.add(b.storage().SetState(op.addr, op.key, op.value), op.value)
+			stats.Slots++
+		case replayDelete:
+			// Finalise the destruction right away, so that the following
+			// operations on the address start from an empty account
+			b.statedb.SelfDestruct(op.addr)
+			b.statedb.Finalise(true)
+			stats.Deletes++
+		}
+		if stats.Ops%1000 == 0 {
+			b.progress.update(phase.Name, len(touched), stats.Slots, "...replayed %d operations, %d accounts", stats.Ops, len(touched))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read replay file: %v", err)
+	}
+	if pending {
+		stats.Trailing = true
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+	stats.finish(phase, len(touched), b.root, time.Since(start))
+	return nil
+}
+
+// finish records the totals of the replay phase.
+func (s *replayStats) finish(phase *phaseResult, accounts int, root common.Hash, elapsed time.Duration) {
+	phase.Accounts = accounts
+	phase.finish(s.Slots, elapsed, root)
+	if secs := elapsed.Seconds(); secs > 0 {
+		s.OpRate = float64(s.Ops) / secs
+	}
+}
+
+// printReplay writes the operations applied from a replay file into w, if
+// one was replayed.
+func printReplay(w io.Writer, s *replayStats) {
+	if s == nil {
+		return
+	}
+	fmt.Fprintf(w, "Operations Replayed: %d | Throughput: %.2f ops/s\n", s.Ops, s.OpRate)
+	fmt.Fprintf(w, "Operation Mix: %d balances, %d slots, %d deletes in %d commits\n", s.Balances, s.Slots, s.Deletes, s.Commits)
+	if s.Trailing {
+		fmt.Fprintf(w, "Trailing Commit: the operations after the last COMMIT were committed at the end of the file\n")
+	}
+}
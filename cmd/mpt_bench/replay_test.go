@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+func TestParseReplayOp(t *testing.T) {
+	addr := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+	op, err := parseReplayOp("SET_STATE 0x00000000000000000000000000000000000000aa 0x1 abc")
+	if err != nil {
+		t.Fatalf("failed to parse SET_STATE: %v", err)
+	}
+	if op.addr != addr || op.key != common.HexToHash("0x01") || op.value != common.HexToHash("0x0abc") {
+		t.Errorf("SET_STATE: have %x %x %x", op.addr, op.key, op.value)
+	}
+	for _, balance := range []string{"1000", "0x3e8"} {
+		op, err := parseReplayOp("SET_BALANCE 0x00000000000000000000000000000000000000aa " + balance)
+		if err != nil {
+			t.Fatalf("failed to parse balance %s: %v", balance, err)
+		}
+		if !op.balance.Eq(uint256.NewInt(1000)) {
+			t.Errorf("balance %s: have %v, want 1000", balance, op.balance)
+		}
+	}
+	for _, bad := range []string{
+		"SWAP 0x00000000000000000000000000000000000000aa",
+		"COMMIT now",
+		"DELETE 0xaa",
+		"SET_BALANCE 0x00000000000000000000000000000000000000aa -1",
+		"SET_STATE 0x00000000000000000000000000000000000000aa 0x1",
+		"SET_STATE 0x00000000000000000000000000000000000000aa 0xzz 0x1",
+		"SET_STATE 0x00000000000000000000000000000000000000aa 0x1 0x" + common.Bytes2Hex(make([]byte, 33)),
+	} {
+		if _, err := parseReplayOp(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
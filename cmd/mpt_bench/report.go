@@ -0,0 +1,540 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// batchSample is the disk and memory snapshot taken after a committed batch.
+type batchSample struct {
+	Batch     int           `json:"batch"`
+	Block     uint64        `json:"block"`
+	Accounts  int           `json:"accounts"` // Accounts processed in the phase so far
+	Slots     int64         `json:"slots"`    // Slots written in the phase so far
+	Root      common.Hash   `json:"root"`
+	DiskSize  int64         `json:"diskBytes,omitempty"`
+	MemAlloc  uint64        `json:"memAllocBytes"`
+	HeapInuse uint64        `json:"heapInuseBytes"`
+	NumGC     uint32        `json:"numGC"` // Garbage collections completed since the process started
+	Duration  time.Duration `json:"durationNs"`
+	Hash      time.Duration `json:"hashNs,omitempty"` // Time spent in IntermediateRoot, if measured apart
+	Commit    time.Duration `json:"commitNs"`         // Time spent in the StateDB and TrieDB commits, excluding Hash
+	Readers   bool          `json:"readers,omitempty"`
+	Breakdown *commitSteps  `json:"commitSteps,omitempty"` // Steps of the commit, with -detailed-commit
+	Sys       *sysSample    `json:"sys,omitempty"`         // Resource usage of the process, with -sysstat
+	Open      time.Duration `json:"openNs,omitempty"`      // Time reopening the state at Root, excluded from Duration, with -measure-open
+}
+
+// phaseResult contains the measurements of a single benchmark phase.
+type phaseResult struct {
+	Name       string         `json:"name"`
+	Accounts   int            `json:"accounts"`
+	Slots      int64          `json:"slots"`
+	Elapsed    time.Duration  `json:"elapsedNs"`
+	Throughput float64        `json:"slotsPerSecond"`
+	Root       common.Hash    `json:"root"`
+	Contracts  int            `json:"contracts,omitempty"` // Accounts created with code
+	Latency    latencyStats   `json:"commitLatency"`
+	BlockTime  *latencyStats  `json:"blockTime,omitempty"`     // Only in block mode, including the state updates
+	Hashing    *hashingStats  `json:"hashing,omitempty"`       // Only measured with -measure-intermediate
+	Breakdown  *commitSteps   `json:"commitSteps,omitempty"`   // Only measured with -detailed-commit
+	Sys        *sysStats      `json:"sys,omitempty"`           // Only sampled with -sysstat
+	Opens      *latencyStats  `json:"stateOpen,omitempty"`     // Only measured with -measure-open
+	Nodes      *nodeStats     `json:"trieNodes,omitempty"`     // Path mode only
+	Commitment *commitStats   `json:"commitments,omitempty"`   // Verkle mode only
+	Writes     *amplification `json:"amplification,omitempty"` // Creation and modification only
+	Values     *valueStats    `json:"slotValues,omitempty"`
+	AcctValues *accountStats  `json:"accountValues,omitempty"` // Creation phase only, with non-default balances or nonces
+	Allocs     *allocStats    `json:"allocations,omitempty"`
+	Access     *accessStats   `json:"access,omitempty"`   // Cold and warm accesses, if tracked
+	Mix        *mixStats      `json:"mix,omitempty"`      // Mixed workload only
+	Replay     *replayStats   `json:"replay,omitempty"`   // Replay mode only
+	Verify     *verifyResult  `json:"verify,omitempty"`   // Trie integrity check of the final root
+	Rehash     *rehashResult  `json:"rehash,omitempty"`   // Trie rebuilt from scratch after phase 2
+	ModCheck   *modCheck      `json:"modCheck,omitempty"` // Sampled writes read back from the final root
+	Growth     *growthCurve   `json:"growth,omitempty"`   // Creation phase only, if reported
+	Batches    []batchSample  `json:"batches"`
+}
+
+// finish records the totals of a completed phase.
+func (p *phaseResult) finish(slots int64, elapsed time.Duration, root common.Hash) {
+	p.Slots = slots
+	p.Elapsed = elapsed
+	if secs := elapsed.Seconds(); secs > 0 {
+		p.Throughput = float64(slots) / secs
+	}
+	p.Root = root
+
+	latencies := make([]time.Duration, len(p.Batches))
+	for i, batch := range p.Batches {
+		latencies[i] = batch.Commit
+	}
+	p.Latency = summarize(latencies)
+
+	var hashing hashingStats
+	for _, batch := range p.Batches {
+		hashing.Hash += batch.Hash
+		hashing.Commit += batch.Commit
+		hashing.Total += batch.Duration
+	}
+	if hashing.Hash > 0 {
+		p.Hashing = &hashing
+	}
+	var breakdown commitSteps
+	for _, batch := range p.Batches {
+		if batch.Breakdown != nil {
+			breakdown.add(batch.Breakdown)
+		}
+	}
+	if breakdown.Total > 0 {
+		p.Breakdown = &breakdown
+	}
+	var sys *sysStats
+	for _, batch := range p.Batches {
+		if batch.Sys != nil {
+			if sys == nil {
+				sys = new(sysStats)
+			}
+			sys.add(batch.Sys)
+		}
+	}
+	p.Sys = sys
+
+	var opens []time.Duration
+	for _, batch := range p.Batches {
+		if batch.Open > 0 {
+			opens = append(opens, batch.Open)
+		}
+	}
+	if len(opens) > 0 {
+		stats := summarize(opens)
+		p.Opens = &stats
+	}
+}
+
+// committed returns the number of accounts the phase processed as of its last
+// committed batch.
+func (p *phaseResult) committed() int {
+	if n := len(p.Batches); n > 0 {
+		return p.Batches[n-1].Accounts
+	}
+	return 0
+}
+
+// hashingStats splits the time of the batches of a phase into the trie hashing
+// and the database writes of the commits.
+type hashingStats struct {
+	Hash   time.Duration `json:"hashNs"`
+	Commit time.Duration `json:"commitNs"`
+	Total  time.Duration `json:"batchNs"` // Total batch time, including collecting the changes
+}
+
+// printHashing writes the hashing and commit share of the batch time of a
+// phase into w, if they were measured apart.
+func printHashing(w io.Writer, p *phaseResult) {
+	h := p.Hashing
+	if h == nil || h.Total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Batch Time: %v | Hashing: %v (%.1f%%) | DB Writes: %v (%.1f%%)\n", h.Total.Round(time.Millisecond),
+		h.Hash.Round(time.Millisecond), float64(h.Hash)/float64(h.Total)*100,
+		h.Commit.Round(time.Millisecond), float64(h.Commit)/float64(h.Total)*100)
+}
+
+// printOpens writes the distribution of the times reopening the state after
+// the batches of a phase into w, if measured.
+func printOpens(w io.Writer, p *phaseResult) {
+	if p.Opens == nil {
+		return
+	}
+	var open, total time.Duration
+	for _, batch := range p.Batches {
+		open += batch.Open
+		total += batch.Duration + batch.Open
+	}
+	fmt.Fprintf(w, "State Open: %v (%.2f%% of the batch time)\n", *p.Opens, float64(open)/float64(total)*100)
+}
+
+// runParams contains the workload parameters of a run not reported elsewhere,
+// so that a JSON report describes the run it was produced by.
+type runParams struct {
+	Accounts int    `json:"accounts"`
+	Slots    int    `json:"slots"` // Average slots per account
+	Modify   int    `json:"modify"`
+	Workload string `json:"workload"`
+	Batch    int    `json:"batch"`   // Accounts per commit
+	Workers  int    `json:"workers"` // Goroutines deriving the slot keys
+}
+
+// result is the final report of a benchmark run.
+type result struct {
+	Started        time.Time       `json:"startedAt"`
+	DBPath         string          `json:"dbPath"`
+	Interrupted    bool            `json:"interrupted,omitempty"` // Whether the run was stopped early by a signal
+	Scheme         string          `json:"scheme"`
+	Tree           string          `json:"tree"`                        // Kind of the state tree (mpt|binary|verkle)
+	Params         runParams       `json:"params"`                      // Workload parameters not reported elsewhere
+	DryRun         bool            `json:"dryRun,omitempty"`            // Nothing was committed, disk numbers are omitted
+	CacheMB        int             `json:"cacheMB"`                     // Pebble block cache size
+	Compression    string          `json:"compression"`                 // Pebble table compression
+	BatchMB        float64         `json:"ethdbBatchMB,omitempty"`      // Size of the trie node batches, 0: the scheme's default
+	NoWAL          bool            `json:"noWAL,omitempty"`             // Pebble write-ahead log disabled
+	SyncCommit     bool            `json:"syncCommit,omitempty"`        // Every Pebble write synced to disk
+	FlatStorage    bool            `json:"flatStorage,omitempty"`       // Slots written as flat key-values, no storage tries
+	CommitLimit    int             `json:"commitWorkers,omitempty"`     // Storage tries committed concurrently, 0 if unlimited
+	NoHashCache    bool            `json:"noHashCache,omitempty"`       // Cached node hashes bypassed, every hashing is cold
+	InsertOrder    string          `json:"insertOrder"`                 // Order of the slot inserts into the storage tries
+	KeyScheme      string          `json:"keyScheme"`                   // Layout of the state trie keys
+	Dereferenced   int             `json:"dereferencedRoots,omitempty"` // Stale roots released in hash mode
+	MaxHeapMB      int             `json:"maxHeapMB,omitempty"`         // Heap cap checked after every batch, 0 if disabled
+	HeapGuard      int             `json:"heapGuardTriggers,omitempty"` // Batches that found the heap over the cap
+	Failures       []batchFailure  `json:"failedBatches,omitempty"`     // Batches skipped with -continue-on-error
+	History        uint64          `json:"stateHistory"`                // Configured state history depth in path mode, 0: keep all
+	HistoryEntries uint64          `json:"stateHistoryEntries"`         // State histories retained in the freezer
+	HistoryBytes   uint64          `json:"stateHistoryBytes"`           // Size of the state histories retained, pruned ones excluded
+	Archive        bool            `json:"archive,omitempty"`           // Whether the state history of every block was retained
+	Freezer        *freezerStats   `json:"freezer,omitempty"`           // State history freezer apart from the live store, with -freezer-stats
+	DirtyCacheMB   int             `json:"dirtyCacheMB,omitempty"`      // Pathdb write buffer size
+	CleanCacheMB   int             `json:"cleanCacheMB,omitempty"`      // Size of each pathdb clean cache
+	CapLayers      int             `json:"capLayers,omitempty"`         // Diff layers kept in memory, 0: flattened every batch
+	BufferFlushes  int64           `json:"bufferFlushes,omitempty"`     // Write buffer flushes into the key-value store
+	LayersCreated  int64           `json:"diffLayersCreated,omitempty"` // Diff layers added by the commits in path mode
+	LayersMerged   int64           `json:"diffLayersMerged,omitempty"`  // Diff layers flattened into the disk layer
+	Journal        *journalStats   `json:"journal,omitempty"`           // Journal of the final state in path mode
+	ReopenJournal  *journalStats   `json:"reopenJournal,omitempty"`     // Journal written and replayed between the phases
+	Warmup         *phaseResult    `json:"warmup,omitempty"`            // Excluded from the throughput numbers
+	Distribution   string          `json:"distribution"`                // Slot access distribution of the modification phase
+	ZipfS          float64         `json:"zipfS,omitempty"`
+	ZipfV          float64         `json:"zipfV,omitempty"`
+	ModSeed        int64           `json:"modSeed"`
+	ModDelete      float64         `json:"modDeleteRatio,omitempty"`
+	ModMode        string          `json:"modMode"`
+	Blocks         int             `json:"blocks,omitempty"` // Blocks applied in phase 2, 0 if modifying in batches
+	TxsPerBlock    int             `json:"txsPerBlock,omitempty"`
+	Mix            *mixWeights     `json:"mix,omitempty"` // Operation weights of the mixed workload
+	OpsPerTx       int             `json:"opsPerTx,omitempty"`
+	AddrMode       string          `json:"addressMode"`
+	LeafDepth      float64         `json:"avgAccountLeafDepth,omitempty"` // Average depth of the account trie leaves in nibbles, with -depth-report
+	Depths         *depthReport    `json:"leafDepths,omitempty"`          // Leaf depth distributions, with -depth-report
+	SlotDist       string          `json:"slotDistribution"`              // Distribution of the slots per account created in phase 1
+	BatchSlots     bool            `json:"batchSlots,omitempty"`          // Whether phase 1 wrote the slots with SetStorageBatch
+	Prealloc       bool            `json:"prealloc,omitempty"`            // Whether the statedb maps of phase 1 were pre-sized
+	SlotCap        int64           `json:"totalSlotsCap,omitempty"`       // Total slots phase 1 was capped at, 0 if uncapped
+	SlotsPerAcct   *countStats     `json:"slotsPerAccount,omitempty"`
+	AccountRLP     *sizeStats      `json:"accountRLPSizes,omitempty"` // Encoded sizes of a sample of the phase 1 accounts
+	Largest        *largestTrie    `json:"largestStorageTrie,omitempty"`
+	ColdCaches     bool            `json:"coldCaches"`        // Whether phase 2 started with freshly reopened databases
+	Resumed        bool            `json:"resumed,omitempty"` // Whether phase 1 was skipped in favor of an existing state
+	Replay         *phaseResult    `json:"replay,omitempty"`  // Operations of a replay file, replacing phases 1 and 2
+	Creation       *phaseResult    `json:"creation,omitempty"`
+	Target         *sizeTarget     `json:"targetSize,omitempty"`
+	Shards         []shardResult   `json:"shards,omitempty"` // Per-shard state of sharded runs
+	Instances      *instanceStats  `json:"instances,omitempty"`
+	Snapshot       *snapshotResult `json:"snapshot,omitempty"`
+	Preimages      *preimageStats  `json:"preimages,omitempty"`
+	Modification   *phaseResult    `json:"modification"`
+	Reorg          *phaseResult    `json:"reorg,omitempty"`  // Divergent batches re-executed by the reorgs
+	Reorgs         *reorgStats     `json:"reorgs,omitempty"` // Rollbacks of the reorgs
+	Reads          *readResult     `json:"reads,omitempty"`
+	Prewarm        *prewarmStats   `json:"prewarm,omitempty"`         // Cold reads and cache fill before the reads
+	BatchReads     *batchReadStats `json:"batchReads,omitempty"`      // Batched reads compared to the single ones
+	Historical     *historyReads   `json:"historicalReads,omitempty"` // Reads at a root committed before the final one
+	ReadLoad       *readLoadResult `json:"concurrentReads,omitempty"` // Readers running alongside phase 1
+	Dump           *dumpResult     `json:"keyDump,omitempty"`         // Keys of phase 1 dumped to a file
+	ZeroNoop       *zeroCheck      `json:"zeroNoop,omitempty"`        // Zeroes written into absent slots after phase 1
+	Proofs         *proofResult    `json:"proofs,omitempty"`
+	Iterate        *iterateResult  `json:"iteration,omitempty"`
+	Destruction    *phaseResult    `json:"storageDestruction,omitempty"` // Storage cleared from accounts kept alive
+	Destroy        *destroyStats   `json:"storageDestroyed,omitempty"`
+	Deletion       *phaseResult    `json:"deletion,omitempty"`
+	DeleteMode     string          `json:"deleteMode,omitempty"`
+	DiskReclaimed  int64           `json:"deleteReclaimedBytes,omitempty"` // Disk shrinkage caused by the deletion, negative if it grew
+	Root           common.Hash     `json:"root"`
+	DiskSize       int64           `json:"diskBytes,omitempty"`
+	Compaction     *compactStats   `json:"compaction,omitempty"` // Full compaction before measuring the disk usage
+	LSM            *lsmStats       `json:"lsm,omitempty"`
+	Breakdown      *diskBreakdown  `json:"diskBreakdown,omitempty"` // Only inspected if code or preimages are enabled
+	Checksum       *checksumResult `json:"checksum,omitempty"`      // State entries of the key-value store
+	GC             *gcStats        `json:"gc,omitempty"`            // Only collected with -no-forced-gc
+	Elapsed        time.Duration   `json:"elapsedNs"`
+}
+
+// print writes the final report into w in the requested format.
+func (r *result) print(w io.Writer, format string) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	if format == outputBench {
+		printBenchHeader(w)
+		printBench(w, r)
+		return nil
+	}
+	fmt.Fprintf(w, "\n--- Final Report ---\n")
+	fmt.Fprintf(w, "Database Path: %s\n", r.DBPath)
+	if r.Interrupted {
+		fmt.Fprintf(w, "Interrupted:   yes, partial results of the work completed\n")
+	}
+	fmt.Fprintf(w, "State Scheme:  %s\n", r.Scheme)
+	printTree(w, r)
+	if r.Scheme == rawdb.HashScheme {
+		fmt.Fprintf(w, "Dereferenced:  %d roots\n", r.Dereferenced)
+		if r.BatchMB > 0 {
+			fmt.Fprintf(w, "Ethdb Batch:   %v MB\n", r.BatchMB)
+		}
+	} else {
+		limit := historyString(r.History)
+		if r.Archive {
+			limit = "archive, never pruned"
+		}
+		fmt.Fprintf(w, "State History: %d entries, %.2f MB (limit: %s)\n", r.HistoryEntries, float64(r.HistoryBytes)/(1024*1024), limit)
+		printFreezer(w, r.Freezer)
+		fmt.Fprintf(w, "PathDB Caches: %d MB dirty, %d MB clean (trie and state each)\n", r.DirtyCacheMB, r.CleanCacheMB)
+		if r.CapLayers > 0 {
+			fmt.Fprintf(w, "Buffer Flush:  %d times (keeping %d diff layers)\n", r.BufferFlushes, r.CapLayers)
+		} else {
+			fmt.Fprintf(w, "Buffer Flush:  %d times (committing every batch)\n", r.BufferFlushes)
+		}
+		fmt.Fprintf(w, "Diff Layers:   %d created, %d merged into the disk layer\n", r.LayersCreated, r.LayersMerged)
+		if r.ReopenJournal != nil {
+			fmt.Fprintf(w, "Reopen:        %.2f MB journal, written in %v, replayed in %v\n",
+				float64(r.ReopenJournal.Bytes)/(1024*1024), r.ReopenJournal.Elapsed, r.ReopenJournal.Replay)
+		}
+		if r.Journal != nil {
+			fmt.Fprintf(w, "Journal:       %.2f MB, written in %v\n", float64(r.Journal.Bytes)/(1024*1024), r.Journal.Elapsed)
+		}
+	}
+	switch {
+	case r.NoWAL:
+		fmt.Fprintf(w, "Pebble:        %d MB cache, %s compression, WAL disabled (not crash-safe)\n", r.CacheMB, r.Compression)
+	case r.SyncCommit:
+		fmt.Fprintf(w, "Pebble:        %d MB cache, %s compression, WAL synced on every write\n", r.CacheMB, r.Compression)
+	default:
+		fmt.Fprintf(w, "Pebble:        %d MB cache, %s compression\n", r.CacheMB, r.Compression)
+	}
+	if r.CommitLimit > 0 {
+		fmt.Fprintf(w, "Commit Limit:  %d storage tries hashed and committed concurrently\n", r.CommitLimit)
+	}
+	printPreimages(w, r.Preimages)
+	if r.MaxHeapMB > 0 {
+		fmt.Fprintf(w, "Heap Guard:    triggered %d times (cap %d MB)\n", r.HeapGuard, r.MaxHeapMB)
+	}
+	printFailures(w, r.Failures)
+	if r.FlatStorage {
+		fmt.Fprintf(w, "Storage:       flat key-values, no storage tries (roots only cover the accounts)\n")
+	}
+	if r.NoHashCache {
+		fmt.Fprintf(w, "Hash Cache:    disabled, every hashing rehashes all the trie nodes in memory\n")
+	}
+	if r.InsertOrder == insertOrderSorted {
+		fmt.Fprintf(w, "Insert Order:  sorted, the slots are inserted into the storage tries in hashed key order\n")
+	}
+	if r.KeyScheme == keySchemeRaw {
+		fmt.Fprintf(w, "Key Scheme:    raw, the addresses and slots are the trie keys unhashed (roots differ from the secure trie)\n")
+	}
+	if len(r.Shards) > 0 {
+		fmt.Fprintf(w, "Shards:        %d\n", len(r.Shards))
+		for _, s := range r.Shards {
+			fmt.Fprintf(w, "  shard %d: root %x | %.2f MB\n", s.ID, s.Root, float64(s.DiskSize)/(1024*1024))
+		}
+	}
+	if r.Instances != nil {
+		r.Instances.print(w)
+	}
+	fmt.Fprintf(w, "Mod Seed:      %d\n", r.ModSeed)
+	if r.ModMode != "" && r.ModMode != modModeStorage {
+		fmt.Fprintf(w, "Mod Mode:      %s\n", r.ModMode)
+	}
+	if r.ModDelete > 0 {
+		fmt.Fprintf(w, "Mod Deletes:   %.1f%% of the phase 2 slot writes zero the slot\n", r.ModDelete*100)
+	}
+	if r.Distribution == distZipf {
+		fmt.Fprintf(w, "Slot Access:   zipf (s=%v, v=%v)\n", r.ZipfS, r.ZipfV)
+	} else {
+		fmt.Fprintf(w, "Slot Access:   %s\n", r.Distribution)
+	}
+	if r.Blocks > 0 {
+		if r.Mix != nil {
+			fmt.Fprintf(w, "Block Mode:    %d blocks of %d mixed transactions of %d operations, one root per block (%s)\n", r.Blocks, r.TxsPerBlock, r.OpsPerTx, r.Mix)
+		} else {
+			fmt.Fprintf(w, "Block Mode:    %d blocks of %d slot writes, one root per block\n", r.Blocks, r.TxsPerBlock)
+		}
+	}
+	if r.LeafDepth > 0 {
+		fmt.Fprintf(w, "Addresses:     %s (avg account leaf depth: %.2f nibbles)\n", r.AddrMode, r.LeafDepth)
+	} else {
+		fmt.Fprintf(w, "Addresses:     %s\n", r.AddrMode)
+	}
+	printDepths(w, r.Depths)
+	if r.SlotsPerAcct != nil {
+		fmt.Fprintf(w, "Slots/Account: %s (%s)\n", r.SlotsPerAcct, r.SlotDist)
+	}
+	if r.Creation != nil {
+		printSlotCap(w, r.SlotCap, r.Creation.Slots)
+	}
+	if l := r.Largest; l != nil {
+		if l.Nodes > 0 {
+			fmt.Fprintf(w, "Largest Trie:  %x with %d slots, %d storage trie nodes\n", l.Address, l.Slots, l.Nodes)
+		} else {
+			fmt.Fprintf(w, "Largest Trie:  %x with %d slots, storage trie nodes not counted\n", l.Address, l.Slots)
+		}
+	}
+	if r.BatchSlots {
+		fmt.Fprintf(w, "Slot Writes:   batched, one SetStorageBatch per account in phase 1\n")
+	}
+	if r.Prealloc {
+		fmt.Fprintf(w, "StateDB Maps:  pre-sized to the accounts and slots of every phase 1 batch\n")
+	}
+	fmt.Fprintf(w, "Phase 2 Cache: %s\n", cacheState(r.ColdCaches))
+	if r.Resumed {
+		fmt.Fprintf(w, "Resumed:       phase 1 skipped, continued from an existing state\n")
+	}
+	if r.Snapshot != nil {
+		fmt.Fprintf(w, "Snapshot:      built in %v, %.2f MB flat state\n", r.Snapshot.Elapsed, float64(r.Snapshot.AccountBytes+r.Snapshot.StorageBytes)/(1024*1024))
+	}
+	if r.Replay != nil {
+		fmt.Fprintf(w, "Replay:        %s, %d operations in %d commits\n", r.Replay.Replay.Path, r.Replay.Replay.Ops, r.Replay.Replay.Commits)
+	}
+	printTarget(w, r.Target)
+	if r.Warmup != nil {
+		fmt.Fprintf(w, "Warm-up:       %d accounts (excluded from throughput)\n", r.Warmup.Accounts)
+	}
+	if r.Replay != nil {
+		printValues(w, "Replay", r.Replay.Values)
+	}
+	if r.Creation != nil {
+		printValues(w, "Phase 1", r.Creation.Values)
+	}
+	printValues(w, "Phase 2", r.Modification.Values)
+	printSizes(w, "Account RLP:  ", r.AccountRLP)
+	if r.Creation != nil {
+		printAllocs(w, "Phase 1", r.Creation.Allocs)
+	}
+	printAllocs(w, "Phase 2", r.Modification.Allocs)
+	if l := r.ReadLoad; l != nil {
+		fmt.Fprintf(w, "Read Load:     %d readers, %.2f lookups/s | phase 1 writes %.2f slots/s loaded vs %.2f baseline\n",
+			l.Readers, l.Throughput, l.WriteLoaded, l.WriteBaseline)
+	}
+	printReorgs(w, r.Reorgs)
+	printHistorical(w, r.Historical, r.Reads)
+	printPrewarm(w, r.Prewarm, r.Reads)
+	if z := r.ZeroNoop; z != nil {
+		fmt.Fprintf(w, "Zero No-Op:    %d absent slots of %d accounts zeroed, root and %d storage nodes unchanged\n", z.Slots, z.Accounts, z.Nodes)
+	}
+	if d := r.Dump; d != nil {
+		fmt.Fprintf(w, "Key Dump:      %d accounts, %d slots (%v%% sample) in %s\n", d.Accounts, d.Slots, d.Sample, d.Path)
+	}
+	if r.GC != nil {
+		fmt.Fprintf(w, "GC:            %d cycles, %v total pause (no forced GC)\n", r.GC.Cycles, r.GC.Pause)
+	}
+	if r.DryRun {
+		fmt.Fprintf(w, "Disk Usage:    n/a (dry run, nothing was committed to disk)\n")
+	} else {
+		if c := r.Compaction; c != nil {
+			fmt.Fprintf(w, "Disk Usage:    %.2f MB compacted, %.2f MB before (compacted in %v)\n",
+				float64(r.DiskSize)/(1024*1024), float64(c.DiskBefore)/(1024*1024), c.Elapsed)
+			printCompactCheck(w, c.Check)
+		} else {
+			fmt.Fprintf(w, "Disk Usage:    %.2f MB\n", float64(r.DiskSize)/(1024*1024))
+		}
+		if r.LSM != nil {
+			r.LSM.print(w)
+		}
+		if r.Breakdown != nil {
+			r.Breakdown.print(w)
+		}
+		printChecksum(w, r.Checksum)
+	}
+	if r.Creation != nil {
+		printGrowth(w, r.Creation.Growth, r.DryRun)
+	}
+	return nil
+}
+
+// exitCode implements report, flagging the runs stopped early by a signal.
+func (r *result) exitCode() int {
+	if r.Interrupted {
+		return exitInterrupted
+	}
+	return 0
+}
+
+// historyString returns the human-readable form of a state history limit.
+func historyString(limit uint64) string {
+	if limit == 0 {
+		return "all"
+	}
+	return fmt.Sprintf("last %d blocks", limit)
+}
+
+// printDiskGrowth writes how the disk usage evolved over the batches of a
+// phase into w, sampling at most ten evenly spaced batches.
+func printDiskGrowth(w io.Writer, p *phaseResult) {
+	if len(p.Batches) == 0 {
+		return
+	}
+	var (
+		first = p.Batches[0]
+		last  = p.Batches[len(p.Batches)-1]
+		step  = max(1, len(p.Batches)/10)
+	)
+	fmt.Fprintf(w, "Disk Growth: %.2f MB -> %.2f MB over %d batches (%.2f MB/min)\n",
+		float64(first.DiskSize)/1024/1024, float64(last.DiskSize)/1024/1024, len(p.Batches),
+		float64(last.DiskSize-first.DiskSize)/1024/1024/p.Elapsed.Minutes())
+	for i := step - 1; i < len(p.Batches); i += step {
+		s := p.Batches[i]
+		fmt.Fprintf(w, "  batch %5d: %9d accounts | %.2f MB\n", s.Batch, s.Accounts, float64(s.DiskSize)/1024/1024)
+	}
+}
+
+// printDeletes writes how the slot writes of a phase split into deletions,
+// updates and new slots into w.
+func printDeletes(w io.Writer, v *valueStats) {
+	if v == nil {
+		return
+	}
+	var (
+		noop    = v.Zero - v.Pruned
+		created = v.Small + v.Random - v.Updated
+	)
+	fmt.Fprintf(w, "Slot Writes: %d deleted, %d updated, %d created | %d zero writes to empty slots\n", v.Pruned, v.Updated, created, noop)
+}
+
+// printValues writes the histogram of the slot values written in a phase into w.
+func printValues(w io.Writer, name string, v *valueStats) {
+	if v == nil {
+		return
+	}
+	total := v.Zero + v.Small + v.Random
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s Values: %d zero (%.1f%%), %d small (%.1f%%), %d random (%.1f%%) | %d slots pruned\n", name,
+		v.Zero, float64(v.Zero)/float64(total)*100, v.Small, float64(v.Small)/float64(total)*100,
+		v.Random, float64(v.Random)/float64(total)*100, v.Pruned)
+	printSizes(w, name+" Slot RLP:", &v.RLP)
+}
+
+// printAllocs writes the heap allocations per slot of a phase into w.
+func printAllocs(w io.Writer, name string, a *allocStats) {
+	if a == nil {
+		return
+	}
+	fmt.Fprintf(w, "%s Allocs: %.1f per slot, %.0f bytes per slot (%d total, %.2f MB)\n", name,
+		a.PerSlot, a.BytesPerSlot, a.Mallocs, float64(a.Bytes)/(1024*1024))
+}
+
+// cacheState returns the label of the cache state the modification phase
+// started with.
+func cacheState(cold bool) string {
+	if cold {
+		return "cold"
+	}
+	return "warm"
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// rlpSampleEvery is the interval of the writes whose RLP encoding is sampled,
+// encoding every single one would distort the throughput.
+const rlpSampleEvery = 16
+
+// sizeStats is the histogram of the RLP encoded sizes of sampled values.
+type sizeStats struct {
+	Samples int64         `json:"samples"`
+	Bytes   int64         `json:"bytes"`
+	Sizes   map[int]int64 `json:"sizes"` // Samples by encoded size in bytes
+}
+
+// add records a sampled value encoded into size bytes.
+func (s *sizeStats) add(size int) {
+	if s.Sizes == nil {
+		s.Sizes = make(map[int]int64)
+	}
+	s.Samples++
+	s.Bytes += int64(size)
+	s.Sizes[size]++
+}
+
+// slotRLPSize returns the size of a slot value as stored in its storage trie,
+// the RLP encoding of the value without its leading zeroes.
+func slotRLPSize(val common.Hash) int {
+	enc, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(val[:]))
+	return len(enc)
+}
+
+// sampleAccountSizes encodes every rlpSampleEvery-th account created in phase 1
+// as stored in the account trie, at the current root. In dry-run mode the
+// accounts are read from the uncommitted statedb, otherwise from a fresh one,
+// keeping them out of the caches of the next phase.
+func (b *bench) sampleAccountSizes() (*sizeStats, error) {
+	statedb := b.statedb
+	if !b.cfg.dryRun {
+		var err error
+		if statedb, err = openState(b.sdb, b.root, false); err != nil {
+			return nil, err
+		}
+	}
+	res := new(sizeStats)
+	for i := 0; i < len(b.addrs); i += rlpSampleEvery {
+		addr := b.addrs[i]
+		enc, err := rlp.EncodeToBytes(&types.StateAccount{
+			Nonce:    statedb.GetNonce(addr),
+			Balance:  statedb.GetBalance(addr),
+			Root:     statedb.GetStorageRoot(addr),
+			CodeHash: statedb.GetCodeHash(addr).Bytes(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode account %x: %v", addr, err)
+		}
+		res.add(len(enc))
+	}
+	if err := statedb.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %v", err)
+	}
+	return res, nil
+}
+
+// printSizes writes the average RLP size sampled into w, followed by the
+// share of the samples of every encoded size.
+func printSizes(w io.Writer, name string, s *sizeStats) {
+	if s == nil || s.Samples == 0 {
+		return
+	}
+	sizes := make([]int, 0, len(s.Sizes))
+	for size := range s.Sizes {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+
+	shares := make([]string, len(sizes))
+	for i, size := range sizes {
+		shares[i] = fmt.Sprintf("%dB %.1f%%", size, float64(s.Sizes[size])/float64(s.Samples)*100)
+	}
+	fmt.Fprintf(w, "%s avg %.1f bytes over %d samples | %s\n", name,
+		float64(s.Bytes)/float64(s.Samples), s.Samples, strings.Join(shares, ", "))
+}
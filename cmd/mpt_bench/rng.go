@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// rngLog records how the workload draws from its seeded random sources, and
+// compares it against the log of an earlier run if verifying. After every
+// committed batch, every source drawn from since the previous one logs its
+// number of draws and the digest of all the values drawn so far, one line
+// each. A different seed changes the digests, a workload consuming its random
+// values differently, e.g. after a change of the iteration order, shifts the
+// draws across the batches. Two builds writing the same log drew the same
+// sequences batch by batch.
+//
+// The sources are told apart by name, numbered in the order they are opened
+// if opened more than once. The sources of the concurrent readers and of the
+// measurement aids, e.g. -verify-mods, are not logged, they don't shape the
+// written state.
+type rngLog struct {
+	lock    sync.Mutex
+	file    *os.File
+	w       *bufio.Writer  // Writer of the log recorded, nil if not recording
+	want    []string       // Lines of the log verified against, nil if not verifying
+	next    int            // Index of the next line to verify
+	opened  map[string]int // Number of the sources opened per name
+	streams []*rngStream
+	err     error // First divergence from the verified log
+}
+
+// openRNGLog creates the draw log recorded at logPath and loads the one to
+// verify against from verifyPath, either of them empty if unused.
+func openRNGLog(logPath, verifyPath string) (*rngLog, error) {
+	l := &rngLog{opened: make(map[string]int)}
+	if verifyPath != "" {
+		want, err := loadRNGLog(verifyPath)
+		if err != nil {
+			return nil, err
+		}
+		l.want = want
+	}
+	if logPath != "" {
+		f, err := os.Create(logPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create draw log: %v", err)
+		}
+		l.file, l.w = f, bufio.NewWriter(f)
+	}
+	return l, nil
+}
+
+// loadRNGLog reads the lines of a draw log.
+func loadRNGLog(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open draw log: %v", err)
+	}
+	defer f.Close()
+
+	want := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		want = append(want, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read draw log: %v", err)
+	}
+	return want, nil
+}
+
+// newRand returns a random source seeded with seed, logged under name. Without
+// a log, it returns a plain seeded source.
+func (l *rngLog) newRand(name string, seed int64) *rand.Rand {
+	if l == nil {
+		return rand.New(rand.NewSource(seed))
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.opened[name]++; l.opened[name] > 1 {
+		name = fmt.Sprintf("%s#%d", name, l.opened[name])
+	}
+	s := &rngStream{
+		name: name,
+		src:  rand.NewSource(seed).(rand.Source64),
+		hash: crypto.NewKeccakState(),
+	}
+	l.streams = append(l.streams, s)
+	return rand.New(s)
+}
+
+// checkpoint logs the draws of the sources since the last checkpoint, after
+// the given batch of phase was committed. It returns the first divergence
+// from the verified log found so far.
+func (l *rngLog) checkpoint(phase string, batch int) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, s := range l.streams {
+		if s.draws == s.logged {
+			continue
+		}
+		s.logged = s.draws
+		l.add(fmt.Sprintf("%s %d %s %d %x", phase, batch, s.name, s.draws, s.hash.Sum(nil)))
+	}
+	return l.err
+}
+
+// add writes a line into the recorded log and compares it against the next
+// one of the verified log.
+func (l *rngLog) add(line string) {
+	if l.w != nil {
+		fmt.Fprintln(l.w, line)
+	}
+	if l.want == nil || l.err != nil {
+		return
+	}
+	if l.next >= len(l.want) {
+		l.err = fmt.Errorf("random draws past the %d lines of the log\n  drawn:  %s", len(l.want), line)
+		return
+	}
+	if want := l.want[l.next]; want != line {
+		l.err = fmt.Errorf("random draws diverged from line %d of the log\n  logged: %s\n  drawn:  %s", l.next+1, want, line)
+		return
+	}
+	l.next++
+}
+
+// close logs the draws since the last committed batch and writes the log out.
+// If verifying, it returns the first divergence, including the lines of the
+// log left undrawn.
+func (l *rngLog) close() error {
+	if l == nil {
+		return nil
+	}
+	l.checkpoint("end", 0)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.want != nil && l.err == nil && l.next < len(l.want) {
+		l.err = fmt.Errorf("random draws stopped before line %d of the log\n  logged: %s", l.next+1, l.want[l.next])
+	}
+	if l.file != nil {
+		err := l.w.Flush()
+		if cerr := l.file.Close(); err == nil {
+			err = cerr
+		}
+		l.file = nil
+		if err != nil {
+			return fmt.Errorf("failed to write draw log: %v", err)
+		}
+	}
+	return l.err
+}
+
+// rngStream is a seeded random source hashing every value it draws into the
+// running digest logged by the draw log.
+type rngStream struct {
+	name   string
+	src    rand.Source64
+	hash   crypto.KeccakState
+	draws  int64
+	logged int64 // Draws as of the last line logged
+	buf    [8]byte
+}
+
+func (s *rngStream) Int63() int64 {
+	v := s.src.Int63()
+	s.draw(uint64(v))
+	return v
+}
+
+func (s *rngStream) Uint64() uint64 {
+	v := s.src.Uint64()
+	s.draw(v)
+	return v
+}
+
+func (s *rngStream) Seed(seed int64) {
+	s.src.Seed(seed)
+}
+
+// draw hashes a drawn value into the digest.
+func (s *rngStream) draw(v uint64) {
+	binary.BigEndian.PutUint64(s.buf[:], v)
+	s.hash.Write(s.buf[:])
+	s.draws++
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// drawWorkload draws n values per batch from a logged source over a phase of
+// three batches.
+func drawWorkload(t *testing.T, l *rngLog, n int) {
+	t.Helper()
+	r := l.newRand("modify", 42)
+	for batch := 1; batch <= 3; batch++ {
+		for i := 0; i < n; i++ {
+			r.Intn(100)
+		}
+		if err := l.checkpoint("modification", batch); err != nil {
+			t.Fatalf("batch %d: %v", batch, err)
+		}
+	}
+}
+
+func TestRNGLogVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rng.log")
+
+	l, err := openRNGLog(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawWorkload(t, l, 10)
+	if err := l.close(); err != nil {
+		t.Fatal(err)
+	}
+	// The same draws verify against the log
+	if l, err = openRNGLog("", path); err != nil {
+		t.Fatal(err)
+	}
+	drawWorkload(t, l, 10)
+	if err := l.close(); err != nil {
+		t.Fatalf("identical draws diverged: %v", err)
+	}
+	// A shifted workload diverges in its first batch
+	if l, err = openRNGLog("", path); err != nil {
+		t.Fatal(err)
+	}
+	l.newRand("modify", 42).Intn(100)
+	if err := l.checkpoint("modification", 1); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("have error %v, want divergence from line 1", err)
+	}
+}
+
+func TestRNGLogUnchangedDraws(t *testing.T) {
+	l, err := openRNGLog("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		logged = l.newRand("creation", 7)
+		plain  = rand.New(rand.NewSource(7))
+	)
+	for i := 0; i < 1000; i++ {
+		if have, want := logged.Int63(), plain.Int63(); have != want {
+			t.Fatalf("draw %d: have %d, want %d", i, have, want)
+		}
+	}
+}
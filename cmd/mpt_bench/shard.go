@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// shard is an independent state with its own databases, living in its own
+// directory below the configured database path.
+type shard struct {
+	stores
+
+	id      int
+	path    string
+	statedb *state.StateDB
+	root    common.Hash
+	derefs  int // Number of stale roots dereferenced in hash mode
+}
+
+// shardResult contains the final state of a single shard.
+type shardResult struct {
+	ID       int         `json:"id"`
+	Path     string      `json:"path"`
+	Root     common.Hash `json:"root"`
+	DiskSize int64       `json:"diskBytes"`
+}
+
+// openShard creates the databases of the id-th shard, starting from an empty
+// state.
+func openShard(cfg *config, id int) (*shard, error) {
+	path := filepath.Join(cfg.dbPath, fmt.Sprintf("shard-%d", id))
+	stores, err := openStores(cfg, path)
+	if err != nil {
+		return nil, fmt.Errorf("shard %d: %v", id, err)
+	}
+	statedb, err := state.New(types.EmptyRootHash, stores.sdb)
+	if err != nil {
+		stores.diskdb.Close()
+		return nil, fmt.Errorf("shard %d: failed to open state: %v", id, err)
+	}
+	return &shard{
+		stores:  *stores,
+		id:      id,
+		path:    path,
+		statedb: statedb,
+	}, nil
+}
+
+// commit flushes the pending changes of the shard and re-creates its statedb
+// from the new root to release the dirty objects.
+func (s *shard) commit(block uint64, layers int) error {
+	root, released, err := commitState(s.statedb, s.trieDB, s.root, block, layers)
+	if err != nil {
+		return fmt.Errorf("shard %d: %v", s.id, err)
+	}
+	if released {
+		s.derefs++
+	}
+	s.root = root
+	s.statedb, err = state.New(root, s.sdb)
+	if err != nil {
+		return fmt.Errorf("shard %d: failed to open state: %v", s.id, err)
+	}
+	return nil
+}
+
+// shardBench runs the creation and modification phases over several shards.
+// Accounts are assigned to the shards round-robin, and every batch boundary
+// commits all shards concurrently, waiting for the slowest one.
+type shardBench struct {
+	cfg        *config
+	out        io.Writer
+	shards     []*shard
+	slotCounts []int // Number of slots created per account in phase 1
+	csv        *csvWriter
+	progress   *progress
+	batchStart time.Time
+}
+
+// runShards executes the benchmark described by cfg over cfg.shards states.
+func runShards(ctx context.Context, cfg *config, out io.Writer, start time.Time) (*result, error) {
+	fmt.Fprintf(out, "Initializing %d shards at %s (Scheme: %s, Cache: %d MB each, Compression: %s)...\n",
+		cfg.shards, cfg.dbPath, cfg.scheme, cfg.cacheMB, cfg.compression)
+
+	sb := &shardBench{cfg: cfg, out: out, progress: newProgress(cfg, out)}
+	flushes, layers := pathdb.ReadNodeStats().Flushes, trackLayers()
+	defer func() {
+		for _, s := range sb.shards {
+			s.diskdb.Close()
+		}
+	}()
+	for i := 0; i < cfg.shards; i++ {
+		s, err := openShard(cfg, i)
+		if err != nil {
+			return nil, err
+		}
+		sb.shards = append(sb.shards, s)
+	}
+	if cfg.csvPath != "" {
+		var err error
+		if sb.csv, err = newCSVWriter(cfg.csvPath, false, cfg.sysstat); err != nil {
+			return nil, fmt.Errorf("failed to create CSV file: %v", err)
+		}
+		defer sb.csv.Close()
+	}
+	res := &result{
+		Started:      start,
+		DBPath:       cfg.dbPath,
+		Scheme:       cfg.scheme,
+		Tree:         treeMPT,
+		Params:       cfg.params(),
+		CacheMB:      cfg.cacheMB,
+		Compression:  cfg.compression,
+		BatchMB:      cfg.batchMB,
+		NoWAL:        cfg.noWAL,
+		SyncCommit:   cfg.syncCommit,
+		CommitLimit:  cfg.commitWorkers,
+		NoHashCache:  cfg.noHashCache,
+		InsertOrder:  cfg.insertOrder,
+		KeyScheme:    cfg.keyScheme,
+		AddrMode:     cfg.addrMode,
+		SlotDist:     cfg.slotDist,
+		SlotCap:      cfg.totalSlots,
+		BatchSlots:   cfg.batchSlots,
+		Distribution: cfg.dist,
+		ModSeed:      cfg.modSeed,
+		ModDelete:    cfg.modDelete,
+		ModMode:      cfg.modMode,
+		Creation:     &phaseResult{Name: "creation", Accounts: cfg.accounts},
+		Modification: &phaseResult{Name: "modification"},
+	}
+	err := runWritePhases(ctx, cfg, out, sb, fmt.Sprintf("over %d shards", cfg.shards), res)
+	if err := finishPhases(out, res, err); err != nil {
+		return nil, err
+	}
+	res.SlotsPerAcct = summarizeCounts(sb.slotCounts)
+	for _, s := range sb.shards {
+		res.Dereferenced += s.derefs
+		if cfg.scheme == rawdb.PathScheme {
+			count, err := s.trieDB.HistoryCount()
+			if err != nil {
+				return nil, fmt.Errorf("shard %d: failed to count state histories: %v", s.id, err)
+			}
+			res.HistoryEntries += count
+			size, err := s.trieDB.HistorySize()
+			if err != nil {
+				return nil, fmt.Errorf("shard %d: failed to measure state histories: %v", s.id, err)
+			}
+			res.HistoryBytes += size
+
+			// Persist the buffered layers, otherwise the final state is lost
+			if err := s.trieDB.Journal(s.root); err != nil {
+				return nil, fmt.Errorf("shard %d: failed to journal state: %v", s.id, err)
+			}
+		}
+		res.Shards = append(res.Shards, shardResult{
+			ID:       s.id,
+			Path:     s.path,
+			Root:     s.root,
+			DiskSize: getDirSize(s.path),
+		})
+	}
+	res.History, res.Archive = cfg.history, cfg.archive
+	if cfg.scheme == rawdb.PathScheme {
+		res.CapLayers = cfg.capLayers
+		res.BufferFlushes = pathdb.ReadNodeStats().Flushes - flushes
+		layers(res)
+	}
+	res.Root = sb.root()
+	res.DiskSize = getDirSize(cfg.dbPath)
+	res.Elapsed = time.Since(start)
+	return res, nil
+}
+
+// report writes the aggregate results of the finished phase over all shards
+// into w.
+func (sb *shardBench) report(w io.Writer, title, verb string, phase *phaseResult) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%s finished in %v. Combined Root: %x\n", title, phase.Elapsed, phase.Root)
+	fmt.Fprintf(w, "Total Slots %s: %d | Aggregate Throughput: %.2f slots/s\n", verb, phase.Slots, phase.Throughput)
+	creation := phase.Name == "creation"
+	if creation {
+		printSlotCap(w, sb.cfg.totalSlots, phase.Slots)
+		printAccountValues(w, phase.AcctValues)
+	}
+	if !creation || sb.cfg.slots == 0 {
+		fmt.Fprintf(w, "Accounts %s: %d | Aggregate Throughput: %.2f accounts/s\n", verb, phase.Accounts, float64(phase.Accounts)/phase.Elapsed.Seconds())
+	}
+	fmt.Fprintf(w, "Commit Latency: %v\n", phase.Latency)
+}
+
+// checkRoot verifies the combined root reached by the phase, if want is set.
+func (sb *shardBench) checkRoot(phase *phaseResult, want *common.Hash) error {
+	return checkRoot(phase.Name, want, phase.Root)
+}
+
+// root returns the combined root of all shards, the hash of the shard roots in
+// order.
+func (sb *shardBench) root() common.Hash {
+	roots := make([][]byte, len(sb.shards))
+	for i, s := range sb.shards {
+		roots[i] = s.root.Bytes()
+	}
+	return crypto.Keccak256Hash(roots...)
+}
+
+// shardOf returns the shard the i-th account is assigned to.
+func (sb *shardBench) shardOf(i int) *shard {
+	return sb.shards[i%len(sb.shards)]
+}
+
+// createAccounts populates the configured number of accounts, distributed over
+// the shards, committing them every batch.
+func (sb *shardBench) createAccounts(ctx context.Context, phase *phaseResult) error {
+	var (
+		cfg   = sb.cfg
+		gen   = newAccountGenerator(cfg)
+		slots int64
+		start = time.Now()
+	)
+	sb.batchStart = start
+	phase.Values = new(valueStats)
+	phase.AcctValues = gen.values.summary()
+	for i := 0; i < cfg.accounts; i++ {
+		vSlots, contract := gen.write(sb.shardOf(i).statedb, sb.shardOf(i).statedb, i, phase.Values)
+		sb.slotCounts = append(sb.slotCounts, vSlots)
+		slots += int64(vSlots)
+		if contract {
+			phase.Contracts++
+		}
+		interrupted := ctx.Err() != nil
+		if (i+1)%10 == 0 || i+1 == cfg.accounts {
+			sb.progress.update(phase.Name, i+1, slots, "...processed %d/%d accounts (%.1f%%)", i+1, cfg.accounts, float64(i+1)/float64(cfg.accounts)*100)
+		}
+		if (i+1)%cfg.batch == 0 || i+1 == cfg.accounts || interrupted {
+			sample, err := sb.commit(phase, uint64(i/cfg.batch), i+1, slots)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(sb.out, "\n[Batch %d] Root: %.8s | Disk: %.2f MB | MemAlloc: %.2f MB\n",
+				sample.Batch, sample.Root.String(), float64(sample.DiskSize)/1024/1024, float64(sample.MemAlloc)/1024/1024)
+
+			if interrupted {
+				phase.Accounts = i + 1
+				phase.finish(slots, time.Since(start), sb.root())
+				return errInterrupted
+			}
+		}
+	}
+	phase.finish(slots, time.Since(start), sb.root())
+	return nil
+}
+
+// modifyAccounts overwrites random slots of m randomly chosen accounts in
+// their shards, committing them every batch from block first on.
+func (sb *shardBench) modifyAccounts(ctx context.Context, phase *phaseResult, m int, first uint64) error {
+	var (
+		cfg   = sb.cfg
+		slots int64
+		start = time.Now()
+	)
+	sb.batchStart = start
+	phase.Accounts = m
+	phase.Values = new(valueStats)
+
+	rMod := cfg.rng.newRand("modify", cfg.modSeed)
+	perm := rMod.Perm(cfg.accounts)
+	pickSlot, pickDelete := newSlotPicker(cfg, rMod), newDeletePicker(cfg)
+	for i := 0; i < m; i++ {
+		shard, addr := sb.shardOf(perm[i]), accountAddress(cfg.addrMode, perm[i])
+		if cfg.modMode != modModeStorage {
+			transferTo(shard.statedb, addr)
+		}
+		if cfg.modMode != modModeBalance {
+			slots += modifyAccount(shard.statedb, addr, perm[i], rMod, pickSlot, pickDelete, phase.Values)
+		}
+
+		interrupted := ctx.Err() != nil
+		if (i+1)%10 == 0 || i+1 == m {
+			sb.progress.update(phase.Name, i+1, slots, "...modified %d/%d accounts (%.1f%%)", i+1, m, float64(i+1)/float64(m)*100)
+		}
+		if (i+1)%cfg.batch == 0 || i+1 == m || interrupted {
+			sample, err := sb.commit(phase, first+uint64(i/cfg.batch), i+1, slots)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(sb.out, "\n[Mod Batch] Disk: %.2f MB | MemAlloc: %.2f MB\n",
+				float64(sample.DiskSize)/1024/1024, float64(sample.MemAlloc)/1024/1024)
+
+			if interrupted {
+				phase.Accounts = i + 1
+				phase.finish(slots, time.Since(start), sb.root())
+				return errInterrupted
+			}
+		}
+	}
+	phase.finish(slots, time.Since(start), sb.root())
+	return nil
+}
+
+// commit flushes all shards concurrently, returning once every shard is done.
+// The recorded commit latency is the one of the slowest shard.
+func (sb *shardBench) commit(phase *phaseResult, block uint64, accounts int, slots int64) (batchSample, error) {
+	var (
+		wg          sync.WaitGroup
+		errs        = make([]error, len(sb.shards))
+		commitStart = time.Now()
+	)
+	for i, s := range sb.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.commit(block, sb.cfg.capLayers)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return batchSample{}, fmt.Errorf("failed to commit %s batch %d (block %d, accounts %d-%d): %v", phase.Name, len(phase.Batches)+1, block, phase.committed()+1, accounts, err)
+	}
+	commitTime := time.Since(commitStart)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	sample := batchSample{
+		Batch:     len(phase.Batches) + 1,
+		Block:     block,
+		Accounts:  accounts,
+		Slots:     slots,
+		Root:      sb.root(),
+		DiskSize:  getDirSize(sb.cfg.dbPath),
+		MemAlloc:  mem.Alloc,
+		HeapInuse: mem.HeapInuse,
+		NumGC:     mem.NumGC,
+		Duration:  time.Since(sb.batchStart),
+		Commit:    commitTime,
+	}
+	if sb.cfg.sys != nil {
+		sys, err := sb.cfg.sys.sample()
+		if err != nil {
+			return batchSample{}, err
+		}
+		sample.Sys = sys
+	}
+	phase.Batches = append(phase.Batches, sample)
+	if sb.cfg.live != nil {
+		sb.cfg.live.update(phase, sample)
+	}
+	if sb.csv != nil {
+		if err := sb.csv.write(phase.Name, sample); err != nil {
+			return batchSample{}, fmt.Errorf("failed to write CSV row: %v", err)
+		}
+	}
+	if err := sb.cfg.rng.checkpoint(phase.Name, sample.Batch); err != nil {
+		return batchSample{}, err
+	}
+	if !sb.cfg.noForcedGC {
+		runtime.GC() // Suggest GC to clean up
+	}
+
+	sb.batchStart = time.Now()
+	return sample, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// snapshotResult contains the measurements of the snapshot generation.
+type snapshotResult struct {
+	Elapsed      time.Duration `json:"elapsedNs"`
+	AccountBytes int64         `json:"accountBytes"` // Logical size of the flat accounts
+	StorageBytes int64         `json:"storageBytes"` // Logical size of the flat slots
+	DiskGrowth   int64         `json:"diskGrowthBytes"`
+}
+
+// generateSnapshot builds the snapshot of the current root synchronously and
+// switches the state database over to it, so the following phases maintain
+// the snapshot and read through it.
+func (b *bench) generateSnapshot() (*snapshotResult, error) {
+	var (
+		res        = new(snapshotResult)
+		diskBefore = getDirSize(b.cfg.dbPath)
+		start      = time.Now()
+	)
+	snaps, err := snapshot.New(snapshot.Config{CacheSize: 256}, b.diskdb, b.trieDB, b.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate snapshot: %v", err)
+	}
+	res.Elapsed = time.Since(start)
+	res.DiskGrowth = getDirSize(b.cfg.dbPath) - diskBefore
+	res.AccountBytes = prefixSize(b.diskdb, rawdb.SnapshotAccountPrefix, common.HashLength)
+	res.StorageBytes = prefixSize(b.diskdb, rawdb.SnapshotStoragePrefix, 2*common.HashLength)
+
+	b.snaps = snaps
+	b.sdb = newStateDatabase(b.cfg, b.trieDB, snaps)
+	b.statedb, err = state.New(b.root, b.sdb)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state: %v", err)
+	}
+	return res, nil
+}
+
+// prefixSize sums up the size of the entries with the given prefix and key
+// length, skipping any metadata sharing the prefix.
+func prefixSize(db ethdb.Database, prefix []byte, length int) int64 {
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var size int64
+	for it.Next() {
+		if isFlatKey(it.Key(), prefix, length) {
+			size += int64(len(it.Key()) + len(it.Value()))
+		}
+	}
+	return size
+}
+
+// bloomCounts contains the outcomes of the bloom filter checks of one kind of
+// snapshot lookup.
+type bloomCounts struct {
+	TrueHits          int64   `json:"trueHits"`          // Passed the filter, found in a diff layer
+	FalseHits         int64   `json:"falseHits"`         // Passed the filter, missing from all diff layers
+	Misses            int64   `json:"misses"`            // Rejected by the filter, read from the disk layer
+	HitRate           float64 `json:"hitRate"`           // Fraction of the lookups served by the diff layers
+	FalsePositiveRate float64 `json:"falsePositiveRate"` // Fraction of the lookups missing from the diff layers let through
+}
+
+func newBloomCounts(trueHits, falseHits, misses int64) bloomCounts {
+	c := bloomCounts{TrueHits: trueHits, FalseHits: falseHits, Misses: misses}
+	if total := trueHits + falseHits + misses; total > 0 {
+		c.HitRate = float64(trueHits) / float64(total)
+	}
+	if absent := falseHits + misses; absent > 0 {
+		c.FalsePositiveRate = float64(falseHits) / float64(absent)
+	}
+	return c
+}
+
+// bloomStats contains the effectiveness of the diff layer bloom filters of the
+// snapshot over a phase.
+type bloomStats struct {
+	Account bloomCounts `json:"account"`
+	Storage bloomCounts `json:"storage"`
+	Error   float64     `json:"estimatedError"` // False positive rate expected from the filter sizing
+}
+
+// trackBloom starts counting the snapshot bloom filter checks, returning a
+// function that reports the checks made since. Only the diff layers consult
+// the filter, nil is reported without a snapshot or if none was checked.
+func (b *bench) trackBloom() func() *bloomStats {
+	if b.snaps == nil {
+		return func() *bloomStats { return nil }
+	}
+	start := snapshot.ReadBloomStats()
+	return func() *bloomStats {
+		end := snapshot.ReadBloomStats()
+		s := &bloomStats{
+			Account: newBloomCounts(end.AccountTrueHits-start.AccountTrueHits, end.AccountFalseHits-start.AccountFalseHits, end.AccountMisses-start.AccountMisses),
+			Storage: newBloomCounts(end.StorageTrueHits-start.StorageTrueHits, end.StorageFalseHits-start.StorageFalseHits, end.StorageMisses-start.StorageMisses),
+			Error:   end.Error,
+		}
+		if s.Account == (bloomCounts{}) && s.Storage == (bloomCounts{}) {
+			return nil
+		}
+		return s
+	}
+}
+
+// printBloom writes the bloom filter effectiveness of a phase into w, if any
+// filter was checked.
+func printBloom(w io.Writer, s *bloomStats) {
+	if s == nil {
+		return
+	}
+	for _, kind := range []struct {
+		name string
+		c    bloomCounts
+	}{{"account", s.Account}, {"storage", s.Storage}} {
+		fmt.Fprintf(w, "Snapshot Bloom (%s): %.1f%% hit rate, %.3f%% false positives | %d true hits, %d false hits, %d misses\n",
+			kind.name, kind.c.HitRate*100, kind.c.FalsePositiveRate*100, kind.c.TrueHits, kind.c.FalseHits, kind.c.Misses)
+	}
+	fmt.Fprintf(w, "Snapshot Bloom Sizing: %.3f%% false positives estimated\n", s.Error*100)
+}
@@ -0,0 +1,77 @@
+// The sqlite command records the JSON reports of mpt_bench into an SQLite
+// database, one row of runs per report and one row of batches per committed
+// batch, so that a sweep can be aggregated with SQL:
+//
+//	mpt_bench -output json > run.json
+//	sqlite -db runs.db run.json
+//
+// It is its own module, keeping the SQLite driver out of the dependencies of
+// go-ethereum.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	dbPath := flag.String("db", "", "Path of the SQLite database to append the runs into (tables runs and batches)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlite -db <path> [report.json ...]\n\nReads the reports of mpt_bench -output json from the files, or from stdin if none\nare given. A file may hold several reports one after the other.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *dbPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	db, err := openDB(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, path := range files {
+		if err := recordFile(db, path); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// recordFile records every report found in the file at path, stdin if "-".
+func recordFile(db *resultsDB, path string) error {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open report: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	dec := json.NewDecoder(in)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode report from %s: %v", path, err)
+		}
+		id, err := db.record(raw)
+		if err != nil {
+			return fmt.Errorf("failed to record report from %s: %v", path, err)
+		}
+		fmt.Printf("Recorded run %s into %s\n", id, db.path)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables of the results database, if missing. Every
+// run is one row of runs, holding its parameters, summary metrics and the full
+// JSON report, while its committed batches are rows of batches.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id                          TEXT PRIMARY KEY,
+	started_at                  TEXT NOT NULL,
+	scheme                      TEXT NOT NULL,
+	tree                        TEXT NOT NULL,
+	accounts                    INTEGER NOT NULL,
+	slots                       INTEGER NOT NULL,
+	slot_dist                   TEXT NOT NULL,
+	addr_mode                   TEXT NOT NULL,
+	modify                      INTEGER NOT NULL,
+	blocks                      INTEGER NOT NULL,
+	txs_per_block               INTEGER NOT NULL,
+	workload                    TEXT NOT NULL,
+	dist                        TEXT NOT NULL,
+	mod_seed                    INTEGER NOT NULL,
+	batch                       INTEGER NOT NULL,
+	workers                     INTEGER NOT NULL,
+	cache_mb                    INTEGER NOT NULL,
+	compression                 TEXT NOT NULL,
+	dirty_cache_mb              INTEGER NOT NULL,
+	clean_cache_mb              INTEGER NOT NULL,
+	cap_layers                  INTEGER NOT NULL,
+	flat_storage                INTEGER NOT NULL,
+	dry_run                     INTEGER NOT NULL,
+	interrupted                 INTEGER NOT NULL,
+	creation_root               TEXT,
+	creation_elapsed_ns         INTEGER,
+	creation_slots_per_sec      REAL,
+	creation_commit_p95_ns      INTEGER,
+	modification_root           TEXT,
+	modification_elapsed_ns     INTEGER,
+	modification_slots_per_sec  REAL,
+	modification_commit_p95_ns  INTEGER,
+	reads_per_sec               REAL,
+	final_root                  TEXT NOT NULL,
+	disk_bytes                  INTEGER,
+	elapsed_ns                  INTEGER NOT NULL,
+	report                      TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS batches (
+	run_id          TEXT NOT NULL REFERENCES runs(id),
+	phase           TEXT NOT NULL,
+	batch           INTEGER NOT NULL,
+	block           INTEGER NOT NULL,
+	accounts        INTEGER NOT NULL,
+	slots           INTEGER NOT NULL,
+	root            TEXT NOT NULL,
+	disk_bytes      INTEGER,
+	mem_alloc_bytes INTEGER NOT NULL,
+	duration_ns     INTEGER NOT NULL,
+	hash_ns         INTEGER,
+	commit_ns       INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS batches_run ON batches(run_id);
+`
+
+// report contains the fields of a mpt_bench JSON report recorded into the
+// columns of the database, the rest is only kept in the full report.
+type report struct {
+	Started     time.Time `json:"startedAt"`
+	Interrupted bool      `json:"interrupted"`
+	Scheme      string    `json:"scheme"`
+	Tree        string    `json:"tree"`
+	Params      struct {
+		Accounts int    `json:"accounts"`
+		Slots    int    `json:"slots"`
+		Modify   int    `json:"modify"`
+		Workload string `json:"workload"`
+		Batch    int    `json:"batch"`
+		Workers  int    `json:"workers"`
+	} `json:"params"`
+	DryRun       bool   `json:"dryRun"`
+	CacheMB      int    `json:"cacheMB"`
+	Compression  string `json:"compression"`
+	FlatStorage  bool   `json:"flatStorage"`
+	DirtyCacheMB int    `json:"dirtyCacheMB"`
+	CleanCacheMB int    `json:"cleanCacheMB"`
+	CapLayers    int    `json:"capLayers"`
+	Distribution string `json:"distribution"`
+	ModSeed      int64  `json:"modSeed"`
+	Blocks       int    `json:"blocks"`
+	TxsPerBlock  int    `json:"txsPerBlock"`
+	AddrMode     string `json:"addressMode"`
+	SlotDist     string `json:"slotDistribution"`
+
+	Warmup       *phase `json:"warmup"`
+	Replay       *phase `json:"replay"`
+	Creation     *phase `json:"creation"`
+	Modification *phase `json:"modification"`
+	Reorg        *phase `json:"reorg"`
+	Destruction  *phase `json:"storageDestruction"`
+	Deletion     *phase `json:"deletion"`
+	Reads        *struct {
+		Throughput float64 `json:"readsPerSecond"`
+	} `json:"reads"`
+
+	Root     string        `json:"root"`
+	DiskSize int64         `json:"diskBytes"`
+	Elapsed  time.Duration `json:"elapsedNs"`
+}
+
+// phase contains the fields of a phase of a report recorded into the database.
+type phase struct {
+	Name       string        `json:"name"`
+	Elapsed    time.Duration `json:"elapsedNs"`
+	Throughput float64       `json:"slotsPerSecond"`
+	Root       string        `json:"root"`
+	Latency    struct {
+		P95 time.Duration `json:"p95Ns"`
+	} `json:"commitLatency"`
+	Batches []batch `json:"batches"`
+}
+
+// batch contains the fields of a committed batch recorded into the database.
+type batch struct {
+	Batch    int           `json:"batch"`
+	Block    uint64        `json:"block"`
+	Accounts int           `json:"accounts"`
+	Slots    int64         `json:"slots"`
+	Root     string        `json:"root"`
+	DiskSize int64         `json:"diskBytes"`
+	MemAlloc uint64        `json:"memAllocBytes"`
+	Duration time.Duration `json:"durationNs"`
+	Hash     time.Duration `json:"hashNs"`
+	Commit   time.Duration `json:"commitNs"`
+}
+
+// resultsDB is an SQLite database the reports are recorded into.
+type resultsDB struct {
+	db   *sql.DB
+	path string
+}
+
+// openDB opens the SQLite database at path, creating the tables on first use.
+func openDB(path string) (*resultsDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open results database: %v", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create results tables: %v", err)
+	}
+	return &resultsDB{db: db, path: path}, nil
+}
+
+// Close closes the database.
+func (db *resultsDB) Close() error {
+	return db.db.Close()
+}
+
+// newRunID returns a random id for a recorded run.
+func newRunID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// record inserts the JSON report raw into the database and returns the id of
+// the run. The whole run is inserted in a single transaction, so a sweep
+// aggregating over the database never sees half a run.
+func (db *resultsDB) record(raw []byte) (string, error) {
+	var res report
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return "", err
+	}
+	if res.Scheme == "" || res.Root == "" || res.Started.IsZero() {
+		return "", errors.New("not the report of a single benchmark run")
+	}
+	id, err := newRunID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate run id: %v", err)
+	}
+	tx, err := db.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to start results transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		creation = phaseColumns(res.Creation)
+		mod      = phaseColumns(res.Modification)
+		reads    any
+		disk     any
+	)
+	if res.Reads != nil {
+		reads = res.Reads.Throughput
+	}
+	if !res.DryRun {
+		disk = res.DiskSize
+	}
+	params := res.Params
+	_, err = tx.Exec(`INSERT INTO runs VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, res.Started.UTC().Format(time.RFC3339Nano), res.Scheme, res.Tree,
+		params.Accounts, params.Slots, res.SlotDist, res.AddrMode,
+		params.Modify, res.Blocks, res.TxsPerBlock, params.Workload, res.Distribution, res.ModSeed,
+		params.Batch, params.Workers, res.CacheMB, res.Compression, res.DirtyCacheMB, res.CleanCacheMB, res.CapLayers,
+		res.FlatStorage, res.DryRun, res.Interrupted,
+		creation.root, creation.elapsed, creation.throughput, creation.p95,
+		mod.root, mod.elapsed, mod.throughput, mod.p95,
+		reads, res.Root, disk, int64(res.Elapsed), string(raw),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert run: %v", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO batches VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare batch insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range []*phase{res.Warmup, res.Replay, res.Creation, res.Modification, res.Reorg, res.Destruction, res.Deletion} {
+		if p == nil {
+			continue
+		}
+		for _, s := range p.Batches {
+			var disk, hash any
+			if !res.DryRun {
+				disk = s.DiskSize
+			}
+			if s.Hash > 0 {
+				hash = int64(s.Hash)
+			}
+			if _, err := stmt.Exec(id, p.Name, s.Batch, s.Block, s.Accounts, s.Slots, s.Root, disk, s.MemAlloc, int64(s.Duration), hash, int64(s.Commit)); err != nil {
+				return "", fmt.Errorf("failed to insert batch: %v", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit results: %v", err)
+	}
+	return id, nil
+}
+
+// phaseSummary contains the summary columns of a phase, all NULL if it didn't
+// run.
+type phaseSummary struct {
+	root, elapsed, throughput, p95 any
+}
+
+func phaseColumns(p *phase) phaseSummary {
+	if p == nil || len(p.Batches) == 0 {
+		return phaseSummary{}
+	}
+	return phaseSummary{
+		root:       p.Root,
+		elapsed:    int64(p.Elapsed),
+		throughput: p.Throughput,
+		p95:        int64(p.Latency.P95),
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "runs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	raw := []byte(`{
+		"startedAt": "2026-10-14T12:00:00Z", "scheme": "path", "tree": "mpt",
+		"params": {"accounts": 10, "slots": 5, "modify": 2, "workload": "bulk", "batch": 5, "workers": 1},
+		"creation": {"name": "creation", "elapsedNs": 1000, "slotsPerSecond": 50, "root": "0x01", "commitLatency": {"p95Ns": 10},
+			"batches": [{"batch": 1, "accounts": 5, "slots": 25, "root": "0x02"}, {"batch": 2, "accounts": 10, "slots": 50, "root": "0x01"}]},
+		"modification": {"name": "modification", "batches": []},
+		"root": "0x03", "diskBytes": 4096, "elapsedNs": 2000
+	}`)
+	id, err := db.record(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 32 {
+		t.Fatalf("run id %q, want 16 hex encoded bytes", id)
+	}
+	var (
+		accounts, batches int
+		modRoot           any
+	)
+	if err := db.db.QueryRow(`SELECT accounts, modification_root FROM runs WHERE id = ?`, id).Scan(&accounts, &modRoot); err != nil {
+		t.Fatal(err)
+	}
+	if accounts != 10 || modRoot != nil {
+		t.Fatalf("run recorded with %d accounts and modification root %v, want 10 and NULL", accounts, modRoot)
+	}
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM batches WHERE run_id = ?`, id).Scan(&batches); err != nil {
+		t.Fatal(err)
+	}
+	if batches != 2 {
+		t.Fatalf("%d batches recorded, want 2", batches)
+	}
+	if _, err := db.record([]byte(`{"schemes": ["path", "hash"]}`)); err == nil {
+		t.Fatal("recorded a report that isn't of a single run")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// percentile returns the p-th percentile (0 < p <= 100) of the given samples
+// using the nearest-rank method. The samples are sorted in place.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	slices.Sort(samples)
+
+	rank := int(p/100*float64(len(samples))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(samples) {
+		rank = len(samples) - 1
+	}
+	return samples[rank]
+}
+
+// latencyStats summarizes a set of duration samples.
+type latencyStats struct {
+	P50 time.Duration `json:"p50Ns"`
+	P95 time.Duration `json:"p95Ns"`
+	P99 time.Duration `json:"p99Ns"`
+	Max time.Duration `json:"maxNs"`
+}
+
+// summarize computes the latency percentiles of the given samples. The samples
+// are sorted in place.
+func summarize(samples []time.Duration) latencyStats {
+	if len(samples) == 0 {
+		return latencyStats{}
+	}
+	return latencyStats{
+		P50: percentile(samples, 50),
+		P95: percentile(samples, 95),
+		P99: percentile(samples, 99),
+		Max: samples[len(samples)-1],
+	}
+}
+
+// countStats summarizes a set of per-account counts.
+type countStats struct {
+	Min    int   `json:"min"`
+	Median int   `json:"median"`
+	Max    int   `json:"max"`
+	Total  int64 `json:"total"`
+}
+
+// meanStddev returns the mean and the sample standard deviation of the given
+// values, the latter zero for a single value.
+func meanStddev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) == 1 {
+		return mean, 0
+	}
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)-1))
+}
+
+// summarizeCounts computes the distribution of the given counts, leaving the
+// slice untouched.
+func summarizeCounts(counts []int) *countStats {
+	if len(counts) == 0 {
+		return &countStats{}
+	}
+	sorted := slices.Sorted(slices.Values(counts))
+
+	stats := &countStats{
+		Min:    sorted[0],
+		Median: sorted[len(sorted)/2],
+		Max:    sorted[len(sorted)-1],
+	}
+	for _, n := range sorted {
+		stats.Total += int64(n)
+	}
+	return stats
+}
+
+// String implements fmt.Stringer.
+func (s *countStats) String() string {
+	return fmt.Sprintf("Min %d | Median %d | Max %d | Total %d", s.Min, s.Median, s.Max, s.Total)
+}
+
+// gcStats contains the garbage collections of a run.
+type gcStats struct {
+	Cycles uint32        `json:"cycles"`
+	Pause  time.Duration `json:"pauseNs"` // Total stop-the-world pause time
+}
+
+// gcSince returns the garbage collections completed since the start snapshot
+// of the memory statistics was taken.
+func gcSince(start *runtime.MemStats) *gcStats {
+	var end runtime.MemStats
+	runtime.ReadMemStats(&end)
+	return &gcStats{
+		Cycles: end.NumGC - start.NumGC,
+		Pause:  time.Duration(end.PauseTotalNs - start.PauseTotalNs),
+	}
+}
+
+// allocStats contains the heap allocations made during a phase, relative to the
+// slots written in it.
+type allocStats struct {
+	Mallocs      uint64  `json:"mallocs"`
+	Bytes        uint64  `json:"bytes"` // Cumulative bytes allocated, including the freed ones
+	PerSlot      float64 `json:"mallocsPerSlot"`
+	BytesPerSlot float64 `json:"bytesPerSlot"`
+}
+
+// trackAllocs starts counting the heap allocations of the process, returning a
+// function that reports the allocations made since over the given slot count.
+func trackAllocs() func(slots int64) *allocStats {
+	var start runtime.MemStats
+	runtime.ReadMemStats(&start)
+	return func(slots int64) *allocStats {
+		var end runtime.MemStats
+		runtime.ReadMemStats(&end)
+		s := &allocStats{
+			Mallocs: end.Mallocs - start.Mallocs,
+			Bytes:   end.TotalAlloc - start.TotalAlloc,
+		}
+		if slots > 0 {
+			s.PerSlot = float64(s.Mallocs) / float64(slots)
+			s.BytesPerSlot = float64(s.Bytes) / float64(slots)
+		}
+		return s
+	}
+}
+
+// String implements fmt.Stringer.
+func (s latencyStats) String() string {
+	return fmt.Sprintf("P50 %v | P95 %v | P99 %v | Max %v", s.P50, s.P95, s.P99, s.Max)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i))
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{1, 1},
+		{50, 50},
+		{95, 95},
+		{99, 99},
+		{100, 100},
+	}
+	for _, tt := range tests {
+		if have := percentile(samples, tt.p); have != tt.want {
+			t.Errorf("p%v: have %v, want %v", tt.p, have, tt.want)
+		}
+	}
+	if have := percentile(nil, 50); have != 0 {
+		t.Errorf("empty samples: have %v, want 0", have)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	samples := []time.Duration{5, 1, 4, 2, 3}
+	want := latencyStats{P50: 3, P95: 5, P99: 5, Max: 5}
+	if have := summarize(samples); have != want {
+		t.Errorf("have %+v, want %+v", have, want)
+	}
+	if have := summarize(nil); have != (latencyStats{}) {
+		t.Errorf("empty samples: have %+v, want zero stats", have)
+	}
+}
+
+func TestSummarizeCounts(t *testing.T) {
+	counts := []int{7, 0, 3, 9, 1}
+	have := summarizeCounts(counts)
+	want := countStats{Min: 0, Median: 3, Max: 9, Total: 20}
+	if *have != want {
+		t.Errorf("have %+v, want %+v", *have, want)
+	}
+	if counts[0] != 7 || counts[4] != 1 {
+		t.Errorf("counts modified: %v", counts)
+	}
+	if have := summarizeCounts(nil); *have != (countStats{}) {
+		t.Errorf("empty counts: have %+v, want zero", *have)
+	}
+}
+
+func TestDepthStats(t *testing.T) {
+	var s depthStats
+	for _, depth := range []int{4, 2, 3, 3} {
+		s.add(depth)
+	}
+	if s.Leaves != 4 || s.Min != 2 || s.Max != 4 || s.Avg != 3 {
+		t.Fatalf("have %+v, want 4 leaves, min 2, max 4, avg 3", s)
+	}
+	if want := []int64{0, 0, 1, 2, 1}; !slices.Equal(s.Histogram, want) {
+		t.Fatalf("have histogram %v, want %v", s.Histogram, want)
+	}
+}
+
+func TestMeanStddev(t *testing.T) {
+	mean, stddev := meanStddev([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if mean != 5 || math.Abs(stddev-2.138) > 0.001 {
+		t.Fatalf("have mean %v, stddev %v, want 5 and 2.138", mean, stddev)
+	}
+	if mean, stddev = meanStddev([]float64{3}); mean != 3 || stddev != 0 {
+		t.Fatalf("have mean %v, stddev %v for a single value, want 3 and 0", mean, stddev)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// workerSweep contains the results of the same workload committed with every
+// limit of concurrently committed storage tries from one to the number of CPUs.
+type workerSweep struct {
+	Workers     []int     `json:"commitWorkers"`
+	Runs        []*result `json:"runs"`
+	Interrupted bool      `json:"interrupted,omitempty"` // Whether the remaining runs were skipped
+	Mismatches  []string  `json:"mismatches,omitempty"`  // Roots differing across the runs
+}
+
+// runSweep runs the workload described by cfg once per commit worker count
+// from one to the number of CPUs, in a fresh temporary database each. The
+// limit only changes the scheduling of the commits, so all the runs must end
+// up with the same roots.
+func runSweep(ctx context.Context, cfg *config, out io.Writer) (*workerSweep, error) {
+	dir, err := os.MkdirTemp("", "mpt_bench_sweep")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sweep := new(workerSweep)
+	for n := 1; n <= runtime.NumCPU(); n++ {
+		sub := *cfg
+		sub.commitWorkers, sub.sweepWorkers = n, false
+		sub.dbPath = filepath.Join(dir, fmt.Sprintf("workers-%d", n))
+		sub.clear = true
+		if sub.csvPath != "" {
+			sub.csvPath = fmt.Sprintf("%s.w%d", cfg.csvPath, n)
+		}
+		fmt.Fprintf(out, "\n=== Run %d/%d: %d commit workers ===\n", n, runtime.NumCPU(), n)
+		res, err := run(ctx, &sub, out)
+		if err != nil {
+			return nil, fmt.Errorf("%d commit workers: %v", n, err)
+		}
+		sweep.Workers = append(sweep.Workers, n)
+		sweep.Runs = append(sweep.Runs, res)
+		if res.Interrupted {
+			sweep.Interrupted = true
+			return sweep, nil
+		}
+	}
+	first := sweep.Runs[0]
+	for i, res := range sweep.Runs[1:] {
+		check := func(phase string, want, have *phaseResult) {
+			if want != nil && have != nil && want.Root != have.Root {
+				sweep.Mismatches = append(sweep.Mismatches, fmt.Sprintf("%s root: 1 worker %x, %d workers %x",
+					phase, want.Root, sweep.Workers[i+1], have.Root))
+			}
+		}
+		check("creation", first.Creation, res.Creation)
+		check("modification", first.Modification, res.Modification)
+		check("destruction", first.Destruction, res.Destruction)
+		check("deletion", first.Deletion, res.Deletion)
+		if first.Root != res.Root {
+			sweep.Mismatches = append(sweep.Mismatches, fmt.Sprintf("final root: 1 worker %x, %d workers %x",
+				first.Root, sweep.Workers[i+1], res.Root))
+		}
+	}
+	return sweep, nil
+}
+
+// print writes the throughput of every worker count into w in the requested
+// format, along with its speedup over the single worker run.
+func (s *workerSweep) print(w io.Writer, format string) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+	if format == outputBench {
+		printBenchHeader(w)
+		for _, r := range s.Runs {
+			printBench(w, r)
+		}
+		return nil
+	}
+	throughput := func(p, base *phaseResult) (string, string) {
+		if p == nil {
+			return "-", "-"
+		}
+		if base == nil || base.Throughput == 0 {
+			return fmt.Sprintf("%.2f", p.Throughput), "-"
+		}
+		return fmt.Sprintf("%.2f", p.Throughput), fmt.Sprintf("%.2fx", p.Throughput/base.Throughput)
+	}
+	fmt.Fprintf(w, "\n--- Commit Worker Scaling (%s scheme) ---\n", s.Runs[0].Scheme)
+	fmt.Fprintf(w, "%-8s %20s %8s %24s %8s %12s\n", "Workers", "Creation (slots/s)", "Speedup", "Modification (slots/s)", "Speedup", "Elapsed")
+	for i, r := range s.Runs {
+		create, createUp := throughput(r.Creation, s.Runs[0].Creation)
+		modify, modifyUp := throughput(r.Modification, s.Runs[0].Modification)
+		fmt.Fprintf(w, "%-8d %20s %8s %24s %8s %12s\n", s.Workers[i], create, createUp, modify, modifyUp, r.Elapsed.Round(1e6))
+	}
+	switch {
+	case s.Interrupted:
+		fmt.Fprintf(w, "Interrupted, the remaining worker counts were skipped\n")
+	case len(s.Mismatches) > 0:
+		fmt.Fprintf(w, "ROOT MISMATCH, the worker counts disagree on the identical workload:\n")
+		for _, m := range s.Mismatches {
+			fmt.Fprintf(w, "  %s\n", m)
+		}
+	default:
+		fmt.Fprintf(w, "Roots match across all worker counts\n")
+	}
+	return nil
+}
+
+// exitCode implements report, failing on any mismatch between the runs.
+func (s *workerSweep) exitCode() int {
+	switch {
+	case len(s.Mismatches) > 0:
+		return 1
+	case s.Interrupted:
+		return exitInterrupted
+	}
+	return 0
+}
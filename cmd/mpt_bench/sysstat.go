@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// sysSample contains the resource usage of the process at a batch boundary,
+// beyond the Go heap: the resident memory includes the Pebble block cache and
+// the memory mapped files, the disk I/O the bytes that actually hit storage.
+type sysSample struct {
+	RSS   uint64        `json:"rssBytes"`
+	CPU   time.Duration `json:"cpuNs"`      // CPU time of the process since the previous sample
+	Wall  time.Duration `json:"wallNs"`     // Wall time since the previous sample
+	Read  uint64        `json:"readBytes"`  // Bytes read from storage since the previous sample
+	Write uint64        `json:"writeBytes"` // Bytes written to storage since the previous sample
+}
+
+// sysStats summarizes the resource usage sampled over the batches of a phase.
+type sysStats struct {
+	PeakRSS uint64        `json:"peakRSSBytes"`
+	CPU     time.Duration `json:"cpuNs"`
+	Wall    time.Duration `json:"wallNs"`
+	Read    uint64        `json:"readBytes"`
+	Write   uint64        `json:"writeBytes"`
+}
+
+// add accumulates a sample into the summary.
+func (s *sysStats) add(o *sysSample) {
+	s.PeakRSS = max(s.PeakRSS, o.RSS)
+	s.CPU += o.CPU
+	s.Wall += o.Wall
+	s.Read += o.Read
+	s.Write += o.Write
+}
+
+// cpuPercent returns the CPU utilization over a wall time, 100% per busy core.
+func cpuPercent(cpu, wall time.Duration) float64 {
+	if wall <= 0 {
+		return 0
+	}
+	return float64(cpu) / float64(wall) * 100
+}
+
+// sysCounters are the cumulative counters of the process the samples are the
+// deltas of.
+type sysCounters struct {
+	time  time.Time
+	cpu   time.Duration
+	read  uint64
+	write uint64
+}
+
+// sysSampler samples the resource usage of the process. Every sample covers
+// the interval since the previous one, the samples of a run must not overlap.
+type sysSampler struct {
+	proc *process.Process
+	last sysCounters
+}
+
+// newSysSampler creates a sampler of the current process, failing if the
+// platform doesn't expose its resource usage.
+func newSysSampler() (*sysSampler, error) {
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the process stats: %v", err)
+	}
+	s := &sysSampler{proc: proc}
+	if s.last, err = s.counters(); err != nil {
+		return nil, err
+	}
+	if _, err := proc.MemoryInfo(); err != nil {
+		return nil, fmt.Errorf("failed to read the process memory: %v", err)
+	}
+	return s, nil
+}
+
+// counters reads the cumulative counters of the process.
+func (s *sysSampler) counters() (sysCounters, error) {
+	now := time.Now()
+	times, err := s.proc.Times()
+	if err != nil {
+		return sysCounters{}, fmt.Errorf("failed to read the process CPU time: %v", err)
+	}
+	io, err := s.proc.IOCounters()
+	if err != nil {
+		return sysCounters{}, fmt.Errorf("failed to read the process I/O counters: %v", err)
+	}
+	return sysCounters{
+		time:  now,
+		cpu:   time.Duration((times.User + times.System) * float64(time.Second)),
+		read:  io.ReadBytes,
+		write: io.WriteBytes,
+	}, nil
+}
+
+// sample returns the resource usage since the previous sample.
+func (s *sysSampler) sample() (*sysSample, error) {
+	mem, err := s.proc.MemoryInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the process memory: %v", err)
+	}
+	now, err := s.counters()
+	if err != nil {
+		return nil, err
+	}
+	last := s.last
+	s.last = now
+	return &sysSample{
+		RSS:   mem.RSS,
+		CPU:   now.cpu - last.cpu,
+		Wall:  now.time.Sub(last.time),
+		Read:  now.read - last.read,
+		Write: now.write - last.write,
+	}, nil
+}
+
+// printSys writes the resource usage sampled over a phase into w, if sampled.
+func printSys(w io.Writer, p *phaseResult) {
+	s := p.Sys
+	if s == nil {
+		return
+	}
+	fmt.Fprintf(w, "System:        peak RSS %.2f MB | CPU %.0f%% | disk %.2f MB read, %.2f MB written\n",
+		float64(s.PeakRSS)/(1024*1024), cpuPercent(s.CPU, s.Wall), float64(s.Read)/(1024*1024), float64(s.Write)/(1024*1024))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSysSampler(t *testing.T) {
+	s, err := newSysSampler()
+	if err != nil {
+		t.Skipf("resource usage not exposed: %v", err)
+	}
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+	sample, err := s.sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.RSS == 0 {
+		t.Error("no resident memory sampled")
+	}
+	if sample.Wall < 20*time.Millisecond || sample.CPU <= 0 {
+		t.Errorf("have CPU time %v over %v, want the busy loop", sample.CPU, sample.Wall)
+	}
+	var stats sysStats
+	stats.add(sample)
+	stats.add(&sysSample{RSS: 1, Wall: sample.Wall})
+	if stats.PeakRSS != sample.RSS || stats.Wall != 2*sample.Wall {
+		t.Errorf("bad summary %+v of %+v", stats, sample)
+	}
+}
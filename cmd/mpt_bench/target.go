@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// targetTolerance is the fraction of the target size the compacted database
+// may stay below of when the creation stops. Every compaction shrinks the
+// database below the target again, without a tolerance the last accounts
+// would be written at one compaction per batch.
+const targetTolerance = 0.01
+
+// sizeTarget keeps the creation phase writing accounts until the database
+// reaches a target size. The size measured after every batch includes the
+// overwritten and deleted entries Pebble hasn't compacted away yet, so the
+// database is compacted whenever it crosses the target, and the creation only
+// stops once the compacted size is there too.
+type sizeTarget struct {
+	Target      int64         `json:"targetBytes"`
+	DiskSize    int64         `json:"diskBytes"` // Compacted size the creation stopped at
+	Compactions int           `json:"compactions"`
+	Compaction  time.Duration `json:"compactionNs"` // Time spent compacting, excluded from the phase
+}
+
+func newSizeTarget(gb float64) *sizeTarget {
+	return &sizeTarget{Target: int64(gb * 1024 * 1024 * 1024)}
+}
+
+// reached reports whether the database of size bytes after a batch reached
+// the target, compacting it first if it seems to.
+func (t *sizeTarget) reached(b *bench, size int64) (bool, error) {
+	if size < t.Target {
+		return false, nil
+	}
+	start := time.Now()
+	if err := b.diskdb.Compact(nil, nil); err != nil {
+		return false, fmt.Errorf("failed to compact database: %v", err)
+	}
+	t.Compactions++
+	t.Compaction += time.Since(start)
+	t.DiskSize = getDirSize(b.cfg.dbPath)
+	fmt.Fprintf(b.out, "\n[Target] Compacted %.2f GB into %.2f GB in %v\n", gb(size), gb(t.DiskSize), time.Since(start).Round(time.Millisecond))
+	return float64(t.DiskSize) >= float64(t.Target)*(1-targetTolerance), nil
+}
+
+// progress returns the human-readable progress towards the target, estimating
+// the accounts and the time left from the disk usage per account so far.
+func (t *sizeTarget) progress(accounts int, size int64, elapsed time.Duration) string {
+	line := fmt.Sprintf("%.2f/%.2f GB", gb(size), gb(t.Target))
+	if accounts == 0 || size == 0 || size >= t.Target {
+		return line
+	}
+	var (
+		perAccount = float64(size) / float64(accounts)
+		remaining  = int(float64(t.Target-size) / perAccount)
+		eta        = time.Duration(float64(elapsed) / float64(accounts) * float64(remaining))
+	)
+	return fmt.Sprintf("%s, ~%d accounts and %v left", line, remaining, eta.Round(time.Second))
+}
+
+// printTarget writes the size the creation phase stopped at into w, if it
+// had a target.
+func printTarget(w io.Writer, t *sizeTarget) {
+	if t == nil {
+		return
+	}
+	fmt.Fprintf(w, "Target Size:   %.2f GB reached at %.2f GB compacted (%d compactions in %v)\n",
+		gb(t.Target), gb(t.DiskSize), t.Compactions, t.Compaction.Round(time.Millisecond))
+}
+
+func gb(bytes int64) float64 {
+	return float64(bytes) / (1024 * 1024 * 1024)
+}
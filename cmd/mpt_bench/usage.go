@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	ethpebble "github.com/ethereum/go-ethereum/ethdb/pebble"
+)
+
+// diskBreakdown attributes the key-value store contents to the kinds of state
+// data. The sizes are the logical key and value lengths, they don't include
+// the compression and the storage engine overhead.
+type diskBreakdown struct {
+	Code      int64 `json:"codeBytes"`
+	Storage   int64 `json:"storageBytes"`  // Storage trie nodes and flat slots
+	Accounts  int64 `json:"accountBytes"`  // Account trie nodes and flat accounts
+	TrieNodes int64 `json:"trieNodeBytes"` // Hash scheme trie nodes, which can't be told apart
+	Preimages int64 `json:"preimageBytes"` // Preimages of the hashed trie keys, if recorded
+	Other     int64 `json:"otherBytes"`
+}
+
+// inspectUsage iterates over the whole key-value store, summing up the size of
+// the entries by kind.
+func inspectUsage(db ethdb.Database) *diskBreakdown {
+	var (
+		usage = new(diskBreakdown)
+		it    = db.NewIterator(nil, nil)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		key, val := it.Key(), it.Value()
+		size := int64(len(key) + len(val))
+
+		switch {
+		case isCode(key):
+			usage.Code += size
+		case rawdb.IsStorageTrieNode(key), isFlatKey(key, rawdb.SnapshotStoragePrefix, 2*common.HashLength):
+			usage.Storage += size
+		case rawdb.IsAccountTrieNode(key), isFlatKey(key, rawdb.SnapshotAccountPrefix, common.HashLength):
+			usage.Accounts += size
+		case rawdb.IsLegacyTrieNode(key, val):
+			usage.TrieNodes += size
+		case isFlatKey(key, rawdb.PreimagePrefix, common.HashLength):
+			usage.Preimages += size
+		default:
+			usage.Other += size
+		}
+	}
+	return usage
+}
+
+// state returns the size of the trie nodes and flat state entries of the
+// accounts and the storage slots.
+func (u *diskBreakdown) state() int64 {
+	return u.TrieNodes + u.Storage + u.Accounts
+}
+
+func isCode(key []byte) bool {
+	ok, _ := rawdb.IsCodeKey(key)
+	return ok
+}
+
+func isFlatKey(key []byte, prefix []byte, length int) bool {
+	return len(key) == len(prefix)+length && bytes.HasPrefix(key, prefix)
+}
+
+// print writes the human-readable breakdown into w.
+func (u *diskBreakdown) print(w io.Writer) {
+	mb := func(n int64) float64 { return float64(n) / 1024 / 1024 }
+
+	fmt.Fprintf(w, "Disk Breakdown (logical key-value sizes):\n")
+	fmt.Fprintf(w, "  Code:        %.2f MB\n", mb(u.Code))
+	if u.TrieNodes > 0 {
+		fmt.Fprintf(w, "  Trie Nodes:  %.2f MB (accounts and storage)\n", mb(u.TrieNodes))
+	}
+	fmt.Fprintf(w, "  Storage:     %.2f MB\n", mb(u.Storage))
+	fmt.Fprintf(w, "  Accounts:    %.2f MB\n", mb(u.Accounts))
+	if u.Preimages > 0 {
+		fmt.Fprintf(w, "  Preimages:   %.2f MB (%.2fx the trie and flat state)\n", mb(u.Preimages), float64(u.Preimages)/float64(u.state()))
+	}
+	fmt.Fprintf(w, "  Other:       %.2f MB\n", mb(u.Other))
+}
+
+// compactStats contains the measurements of the full compaction run before
+// the final disk usage is measured.
+type compactStats struct {
+	DiskBefore int64         `json:"diskBeforeBytes"`
+	Elapsed    time.Duration `json:"elapsedNs"`
+	Check      *compactCheck `json:"check,omitempty"` // Full trie checks around the compaction, with -verify-after-compact
+}
+
+// compact compacts the whole key-value store, so the final disk usage is the
+// steady-state footprint without the overwritten and deleted entries still
+// waiting in the upper levels. before is the disk usage measured up front.
+func (b *bench) compact(before int64) (*compactStats, error) {
+	start := time.Now()
+	if err := b.diskdb.Compact(nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to compact database: %v", err)
+	}
+	return &compactStats{DiskBefore: before, Elapsed: time.Since(start)}, nil
+}
+
+// lsmStats describes the shape of the Pebble LSM tree at the end of a run, to
+// tell how much of the disk usage is still waiting to be compacted.
+type lsmStats struct {
+	Levels          []lsmLevel `json:"levels"`
+	CompactionDebt  uint64     `json:"compactionDebtBytes"` // Estimated bytes to compact until no level needs it
+	Compactions     int64      `json:"compactionsInProgress"`
+	CompactingBytes int64      `json:"compactingBytes"`
+	MemTables       int64      `json:"memTables"`
+	MemTableSize    uint64     `json:"memTableBytes"`
+}
+
+// lsmLevel contains the sstables of a single level of the LSM tree.
+type lsmLevel struct {
+	Level  int     `json:"level"`
+	Tables int64   `json:"tables"`
+	Bytes  int64   `json:"bytes"`
+	Score  float64 `json:"score"` // Compaction score, the level is compacted once above 1
+}
+
+// inspectLSM collects the shape of the LSM tree of db.
+func inspectLSM(db *ethpebble.Database) *lsmStats {
+	stats := db.LSMStats()
+	res := &lsmStats{
+		CompactionDebt:  stats.CompactionDebt,
+		Compactions:     stats.Compactions,
+		CompactingBytes: stats.CompactingBytes,
+		MemTables:       stats.MemTables,
+		MemTableSize:    stats.MemTableSize,
+	}
+	for i, level := range stats.Levels {
+		res.Levels = append(res.Levels, lsmLevel{Level: i, Tables: level.Tables, Bytes: level.Size, Score: level.Score})
+	}
+	return res
+}
+
+// print writes the human-readable LSM tree shape into w, skipping the empty
+// levels.
+func (s *lsmStats) print(w io.Writer) {
+	mb := func(n int64) float64 { return float64(n) / 1024 / 1024 }
+
+	var (
+		tables int64
+		size   int64
+	)
+	for _, level := range s.Levels {
+		tables += level.Tables
+		size += level.Bytes
+	}
+	fmt.Fprintf(w, "LSM Tree:      %.2f MB in %d tables | compaction debt %.2f MB, %d compactions running | %d memtables, %.2f MB\n",
+		mb(size), tables, mb(int64(s.CompactionDebt)), s.Compactions, s.MemTables, mb(int64(s.MemTableSize)))
+	for _, level := range s.Levels {
+		if level.Tables == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  L%d: %5d tables, %10.2f MB (score %.2f)\n", level.Level, level.Tables, mb(level.Bytes), level.Score)
+	}
+}
@@ -0,0 +1,867 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// configChecks are the rules the configuration is validated against, one per
+// feature, grouped by the flags they check. A rule spanning several features
+// belongs to the one it restricts, and returns nil unless it applies.
+var configChecks = []func(c *config) error{
+	// The accounts of phase 1, registered by stateFlags.
+	checkSlots,
+	checkContracts,
+	checkAddrMode,
+	checkSlotDist,
+	checkBalances,
+	// The write phases, registered by writeFlags.
+	checkBatch,
+	checkDuration,
+	checkTargetSize,
+	checkModifications,
+	checkBlocks,
+	checkWorkload,
+	checkWorkers,
+	checkReaders,
+	checkReorgs,
+	checkDestruction,
+	checkDist,
+	checkReplay,
+	checkCrash,
+	checkContinueOnError,
+	// The read phases, registered by queryFlags.
+	checkReads,
+	checkHistorical,
+	checkProofs,
+	checkIterate,
+	// The regression checks, registered by checkFlags.
+	checkVerifyTrie,
+	checkVerifyMods,
+	checkRNG,
+	checkZeroNoop,
+	// The database, registered by storeFlags.
+	checkScheme,
+	checkCaches,
+	checkCompression,
+	checkKeyScheme,
+	checkInsertOrder,
+	checkHashCache,
+	checkVerkle,
+	checkStateHistory,
+	// The layout of the state, registered by layoutFlags.
+	checkResume,
+	checkDurability,
+	checkFlatStorage,
+	checkSnapshot,
+	checkCapLayers,
+	checkShards,
+	checkInstances,
+	// The output, registered by outputFlags.
+	checkOutput,
+	// The measurements, registered by metricFlags.
+	checkMeasureHash,
+	checkSysstat,
+	checkGrowth,
+	checkCompact,
+	checkDetailed,
+	checkChecksum,
+	checkDepthReport,
+	checkTrackAccess,
+	checkMaxHeap,
+	checkDump,
+	// The runs repeating the workload.
+	checkSweep,
+	checkIterations,
+	checkCompare,
+}
+
+// validate checks the configuration for values the benchmark can't run with.
+func (c *config) validate() error {
+	for _, check := range configChecks {
+		if err := check(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSlots checks the slot counts of the accounts.
+func checkSlots(c *config) error {
+	if c.slots < 0 {
+		return fmt.Errorf("invalid slot count %d", c.slots)
+	}
+	if c.slots == 0 && (c.reads > 0 || c.proofs > 0) {
+		return fmt.Errorf("account-only workloads (-slots 0) have no slots to read or prove")
+	}
+	if c.totalSlots < 0 {
+		return fmt.Errorf("invalid total slot count %d", c.totalSlots)
+	}
+	return nil
+}
+
+// checkContracts checks the code and share of the contract accounts.
+func checkContracts(c *config) error {
+	if c.codeSize < 0 {
+		return fmt.Errorf("invalid code size %d", c.codeSize)
+	}
+	if c.contractRatio < 0 || c.contractRatio > 1 {
+		return fmt.Errorf("invalid contract ratio %v, want 0 <= ratio <= 1", c.contractRatio)
+	}
+	return nil
+}
+
+// checkAddrMode checks the derivation of the account addresses.
+func checkAddrMode(c *config) error {
+	switch c.addrMode {
+	case addrModeHashed, addrModeSequential, addrModePrefixed:
+	default:
+		return fmt.Errorf("unknown address mode %q", c.addrMode)
+	}
+	return nil
+}
+
+// checkSlotDist checks the distribution of the slot counts of the accounts.
+func checkSlotDist(c *config) error {
+	switch c.slotDist {
+	case slotDistUniform:
+	case slotDistNormal:
+		if c.slotStddev < 0 {
+			return fmt.Errorf("invalid slot standard deviation %v", c.slotStddev)
+		}
+	case slotDistPareto:
+		if c.paretoAlpha <= 1 {
+			return fmt.Errorf("invalid pareto alpha %v, want alpha > 1", c.paretoAlpha)
+		}
+	default:
+		return fmt.Errorf("unknown slot count distribution %q", c.slotDist)
+	}
+	return nil
+}
+
+// checkBalances checks the distributions of the account balances and nonces.
+func checkBalances(c *config) error {
+	switch c.balanceDist {
+	case balanceDistFixed, balanceDistRandom:
+	default:
+		return fmt.Errorf("unknown balance distribution %q", c.balanceDist)
+	}
+	switch c.nonceDist {
+	case nonceDistIndex, nonceDistZero, nonceDistRandom:
+	default:
+		return fmt.Errorf("unknown nonce distribution %q", c.nonceDist)
+	}
+	if c.zeroBalance < 0 || c.zeroBalance > 1 {
+		return fmt.Errorf("invalid zero balance fraction %v, want 0 <= fraction <= 1", c.zeroBalance)
+	}
+	return nil
+}
+
+// checkBatch checks the commit batch size.
+func checkBatch(c *config) error {
+	if c.batch <= 0 {
+		return fmt.Errorf("invalid commit batch size %d", c.batch)
+	}
+	return nil
+}
+
+// checkDuration checks the time budget of the creation phase.
+func checkDuration(c *config) error {
+	if c.duration < 0 {
+		return fmt.Errorf("invalid duration %v", c.duration)
+	}
+	if c.duration > 0 && c.resumeRoot != nil {
+		return fmt.Errorf("a time budget can't be combined with resuming, the account count is needed to replay the creation")
+	}
+	return nil
+}
+
+// checkTargetSize checks the database size bounding the creation phase.
+func checkTargetSize(c *config) error {
+	if c.targetSize < 0 {
+		return fmt.Errorf("invalid target size %v GB", c.targetSize)
+	}
+	if c.targetSize > 0 {
+		switch {
+		case c.duration > 0:
+			return fmt.Errorf("-target-size-gb and -duration both bound the creation phase, pick one")
+		case c.resumeRoot != nil, c.dryRun, c.shards > 1, c.instances > 1, c.replayPath != "":
+			return fmt.Errorf("-target-size-gb measures the database written by phase 1, not supported when resuming, in dry-run mode, sharded runs, with -instances or -replay")
+		}
+	}
+	return nil
+}
+
+// checkModifications checks the batched modifications of phase 2.
+func checkModifications(c *config) error {
+	if c.modDelete < 0 || c.modDelete > 1 {
+		return fmt.Errorf("invalid modification delete ratio %v, want 0 <= ratio <= 1", c.modDelete)
+	}
+	switch c.modMode {
+	case modModeStorage, modModeMixed:
+	case modModeBalance:
+		if c.verifyMods > 0 {
+			return fmt.Errorf("-mod-mode %s writes no slots for -verify-mods to read back", modModeBalance)
+		}
+	default:
+		return fmt.Errorf("unknown modification mode %q, want %s, %s or %s", c.modMode, modModeStorage, modModeBalance, modModeMixed)
+	}
+	if c.modMode != modModeStorage && c.blocks > 0 {
+		return fmt.Errorf("-mod-mode applies to the batched modifications, not supported with -blocks")
+	}
+	if c.phase2Block < -1 {
+		return fmt.Errorf("invalid phase 2 block offset %d", c.phase2Block)
+	}
+	return nil
+}
+
+// checkBlocks checks the block mode of the write phases.
+func checkBlocks(c *config) error {
+	if c.blocks < 0 {
+		return fmt.Errorf("invalid block count %d", c.blocks)
+	}
+	if c.blocks > 0 && c.txsPerBlock <= 0 {
+		return fmt.Errorf("invalid transactions per block %d", c.txsPerBlock)
+	}
+	if c.blocks > 0 && c.shards > 1 {
+		return fmt.Errorf("sharded runs don't support the block mode")
+	}
+	return nil
+}
+
+// checkWorkload checks the transactions executed by the workload.
+func checkWorkload(c *config) error {
+	switch c.workload {
+	case workloadBulk:
+	case workloadMixed:
+		if c.blocks == 0 {
+			return fmt.Errorf("-workload %s executes its transactions in blocks, set -blocks", workloadMixed)
+		}
+		if c.opsPerTx <= 0 {
+			return fmt.Errorf("invalid operations per transaction %d", c.opsPerTx)
+		}
+		if c.flatStorage || c.verifyMods > 0 {
+			return fmt.Errorf("-workload %s reads and deletes through the storage tries, not supported with flat storage or -verify-mods", workloadMixed)
+		}
+	default:
+		return fmt.Errorf("unknown workload %q, want %s or %s", c.workload, workloadBulk, workloadMixed)
+	}
+	return nil
+}
+
+// checkWorkers checks the workers applying and committing the writes.
+func checkWorkers(c *config) error {
+	if c.workers <= 0 {
+		return fmt.Errorf("invalid worker count %d", c.workers)
+	}
+	if c.commitWorkers < 0 {
+		return fmt.Errorf("invalid commit worker count %d", c.commitWorkers)
+	}
+	if c.commitWorkers > 0 && (c.verkle || c.flatStorage) {
+		return fmt.Errorf("-commit-workers limits the storage tries committed concurrently, not supported with -verkle or -flat-storage")
+	}
+	return nil
+}
+
+// checkReaders checks the readers run concurrently with phase 2.
+func checkReaders(c *config) error {
+	if c.readers < 0 {
+		return fmt.Errorf("invalid concurrent reader count %d", c.readers)
+	}
+	if c.readers > 0 && (c.dryRun || c.resumeRoot != nil || c.shards > 1) {
+		return fmt.Errorf("concurrent readers need the committed states of phase 1, not supported in dry-run, resumed or sharded runs")
+	}
+	return nil
+}
+
+// checkReorgs checks the reorgs rolling phase 2 back.
+func checkReorgs(c *config) error {
+	if c.reorgs < 0 {
+		return fmt.Errorf("invalid reorg count %d", c.reorgs)
+	}
+	if c.reorgs > 0 {
+		switch {
+		case c.reorgDepth <= 0:
+			return fmt.Errorf("invalid reorg depth %d", c.reorgDepth)
+		case c.scheme != rawdb.PathScheme || c.verkle:
+			return fmt.Errorf("reorgs roll back through the state history of the merkle path scheme, require -scheme %s without -verkle", rawdb.PathScheme)
+		case c.dryRun, c.flatStorage, c.shards > 1, c.instances > 1, c.replayPath != "":
+			return fmt.Errorf("reorgs need the committed roots of a single run of phases 1 and 2, not supported in dry-run mode, with flat storage, sharded runs, -instances or -replay")
+		case c.history > 0 && uint64(c.reorgDepth) > c.history:
+			return fmt.Errorf("reorg depth %d exceeds the %d blocks of state history retained", c.reorgDepth, c.history)
+		}
+	}
+	return nil
+}
+
+// checkDestruction checks the storage destruction and account deletion phases.
+func checkDestruction(c *config) error {
+	if c.destroy < 0 {
+		return fmt.Errorf("invalid storage destruction count %d", c.destroy)
+	}
+	switch c.destroyMode {
+	case destroyModeZero, destroyModeWipe:
+	default:
+		return fmt.Errorf("unknown storage destruction mode %q", c.destroyMode)
+	}
+	switch c.deleteMode {
+	case deleteModeSelfDestruct, deleteModeEmptyAccount:
+	default:
+		return fmt.Errorf("unknown delete mode %q", c.deleteMode)
+	}
+	return nil
+}
+
+// checkDist checks the distribution of the modified slots.
+func checkDist(c *config) error {
+	switch c.dist {
+	case distUniform:
+	case distZipf:
+		if c.zipfS <= 1 || c.zipfV < 1 {
+			return fmt.Errorf("invalid zipf parameters s=%v v=%v, want s > 1 and v >= 1", c.zipfS, c.zipfV)
+		}
+	default:
+		return fmt.Errorf("unknown slot distribution %q", c.dist)
+	}
+	return nil
+}
+
+// checkReplay checks the replay of a recorded workload.
+func checkReplay(c *config) error {
+	if c.replayPath != "" {
+		switch {
+		case c.duration > 0, c.warmup > 0, c.blocks > 0, c.readers > 0, c.reads > 0, c.proofs > 0, c.destroy > 0, c.delete > 0:
+			return fmt.Errorf("-replay replaces the synthetic workload, not supported with -duration, -warmup, -blocks, -concurrent-readers, -reads, -proofs, -destroy-storage or -delete")
+		case c.verifyMods > 0, c.expectModRoot != nil, c.dumpPath != "", c.crashAfter > 0, c.shards > 1:
+			return fmt.Errorf("-replay has no phase 1 and 2 to check, dump or crash, not supported with -verify-mods, -expect-mod-root, -dump-keys, -inject-crash-after or sharded runs")
+		}
+	}
+	return nil
+}
+
+// checkCrash checks the crash injection and the recovery of a crashed database.
+func checkCrash(c *config) error {
+	if c.crashAfter < 0 {
+		return fmt.Errorf("invalid crash batch %d", c.crashAfter)
+	}
+	if c.crashAfter > 0 && (c.dryRun || c.resumeRoot != nil || c.shards > 1 || len(c.compare) > 0) {
+		return fmt.Errorf("crash injection needs the committed batches of phase 1, not supported in dry-run, resumed, sharded or compared runs")
+	}
+	if c.recover {
+		switch {
+		case c.clear:
+			return fmt.Errorf("recovering a crashed database requires -clear=false")
+		case c.crashAfter > 0:
+			return fmt.Errorf("-recover and -inject-crash-after are separate runs")
+		case c.dryRun, c.shards > 1, len(c.compare) > 0:
+			return fmt.Errorf("recovery needs a single database on disk, not supported in dry-run, sharded or compared runs")
+		}
+	}
+	return nil
+}
+
+// checkContinueOnError checks the rollback of failed batches.
+func checkContinueOnError(c *config) error {
+	if c.continueOnError && (c.flatStorage || c.shards > 1 || c.instances > 1) {
+		return fmt.Errorf("-continue-on-error rolls a failed batch back to the last committed root, not supported with -flat-storage, -shards or -instances")
+	}
+	return nil
+}
+
+// checkReads checks the random reads of the final state.
+func checkReads(c *config) error {
+	if c.dryRun && c.reads > 0 {
+		return fmt.Errorf("random reads need a committed state, not supported in dry-run mode")
+	}
+	if c.batchReads < 0 {
+		return fmt.Errorf("invalid read batch size %d", c.batchReads)
+	}
+	if c.batchReads > 0 && c.reads == 0 {
+		return fmt.Errorf("-batch-reads repeats the random reads in batches, set -reads")
+	}
+	if c.prewarm {
+		switch {
+		case c.reads == 0:
+			return fmt.Errorf("-prewarm-cache fills the caches for the random reads, set -reads")
+		case c.dryRun, c.noWAL:
+			return fmt.Errorf("-prewarm-cache reopens the databases, not supported with -dry-run or -no-wal")
+		}
+	}
+	return nil
+}
+
+// checkHistorical checks the reads at a past root.
+func checkHistorical(c *config) error {
+	if c.historical < 0 {
+		return fmt.Errorf("invalid historical read depth %d", c.historical)
+	}
+	if c.historical > 0 {
+		switch {
+		case c.scheme != rawdb.PathScheme:
+			return fmt.Errorf("historical reads are served by the state history, -read-historical requires -scheme %s", rawdb.PathScheme)
+		case c.reads == 0:
+			return fmt.Errorf("-read-historical repeats the random reads at a past root, set -reads")
+		case c.dryRun, c.verkle, c.shards > 1, c.instances > 1:
+			return fmt.Errorf("historical reads need a single merkle state on disk, not supported with -dry-run, -verkle, -shards or -instances")
+		}
+	}
+	return nil
+}
+
+// checkProofs checks the proofs of the final state.
+func checkProofs(c *config) error {
+	if c.proofs > 0 && c.dryRun {
+		return fmt.Errorf("proofs need a committed state, not supported in dry-run mode")
+	}
+	if (c.verify || c.proofMissing) && c.proofs == 0 {
+		return fmt.Errorf("-verify and -proof-missing require -proofs")
+	}
+	return nil
+}
+
+// checkIterate checks the iteration of the final state.
+func checkIterate(c *config) error {
+	if c.iterate && c.dryRun {
+		return fmt.Errorf("state iteration needs a committed state, not supported in dry-run mode")
+	}
+	if len(c.iterateFrom) > 0 && !c.iterate {
+		return fmt.Errorf("-iterate-from requires -iterate")
+	}
+	if len(c.iterateFrom) > common.HashLength {
+		return fmt.Errorf("invalid iteration start %x, longer than a hashed key", c.iterateFrom)
+	}
+	return nil
+}
+
+// checkVerifyTrie checks the verification and rehashing of the committed tries.
+func checkVerifyTrie(c *config) error {
+	if c.verifyTrie && c.dryRun {
+		return fmt.Errorf("trie verification needs a committed state, not supported in dry-run mode")
+	}
+	if c.rehash && (c.dryRun || c.verkle) {
+		return fmt.Errorf("the full rehash check iterates the committed merkle trie, not supported in dry-run or verkle mode")
+	}
+	return nil
+}
+
+// checkVerifyMods checks the read back of the modified slots.
+func checkVerifyMods(c *config) error {
+	if c.verifyMods < 0 {
+		return fmt.Errorf("invalid modification sample %d", c.verifyMods)
+	}
+	if c.verifyMods > 0 && (c.dryRun || c.flatStorage || c.verkle || c.shards > 1) {
+		return fmt.Errorf("-verify-mods reads the slots back from the committed storage tries, not supported in dry-run, flat storage, verkle or sharded runs")
+	}
+	return nil
+}
+
+// checkRNG checks the logging and verification of the random draws.
+func checkRNG(c *config) error {
+	if (c.rngLogPath != "" || c.rngVerifyPath != "") && c.instances > 1 {
+		return fmt.Errorf("the random draws of concurrent instances interleave, -rng-log and -rng-verify are not supported with -instances")
+	}
+	return nil
+}
+
+// checkZeroNoop checks the zeroing of absent slots.
+func checkZeroNoop(c *config) error {
+	if c.zeroNoop < 0 {
+		return fmt.Errorf("invalid absent slot count %d", c.zeroNoop)
+	}
+	if c.zeroNoop > 0 && (c.dryRun || c.flatStorage || c.verkle || c.replayPath != "") {
+		return fmt.Errorf("zeroing absent slots needs the committed storage tries of phase 1, not supported in dry-run, flat storage, verkle or replay mode")
+	}
+	return nil
+}
+
+// checkScheme checks the state scheme.
+func checkScheme(c *config) error {
+	switch c.scheme {
+	case rawdb.PathScheme, rawdb.HashScheme:
+	default:
+		return fmt.Errorf("unknown state scheme %q", c.scheme)
+	}
+	return nil
+}
+
+// checkCaches checks the sizes of the caches and write buffers.
+func checkCaches(c *config) error {
+	if c.cacheMB <= 0 {
+		return fmt.Errorf("invalid cache size %d MB", c.cacheMB)
+	}
+	if c.batchMB < 0 {
+		return fmt.Errorf("invalid ethdb batch size %v MB", c.batchMB)
+	}
+	if c.batchMB > 0 && c.scheme != rawdb.HashScheme {
+		return fmt.Errorf("the path scheme flushes its write buffer in a single atomic batch, -ethdb-batch-mb requires -scheme %s", rawdb.HashScheme)
+	}
+	if c.dirtyCacheMB <= 0 || c.dirtyCacheMB > maxDirtyCacheMB {
+		return fmt.Errorf("invalid dirty cache size %d MB, want 0 < size <= %d", c.dirtyCacheMB, maxDirtyCacheMB)
+	}
+	if c.preimageMB < 0 {
+		return fmt.Errorf("invalid preimage cache size %d MB", c.preimageMB)
+	}
+	if c.secKeyLimit < 0 {
+		return fmt.Errorf("invalid key cache limit %d", c.secKeyLimit)
+	}
+	if c.cleanCacheMB <= 0 {
+		return fmt.Errorf("invalid clean cache size %d MB", c.cleanCacheMB)
+	}
+	return nil
+}
+
+// checkCompression checks the compression of the key-value store.
+func checkCompression(c *config) error {
+	if _, ok := compressions[c.compression]; !ok {
+		return fmt.Errorf("unknown compression %q", c.compression)
+	}
+	return nil
+}
+
+// checkKeyScheme checks the keys of the merkle tries.
+func checkKeyScheme(c *config) error {
+	switch c.keyScheme {
+	case keySchemeSecure:
+	case keySchemeRaw:
+		switch {
+		case c.verkle, c.snapshot, c.preimages, c.proofs > 0, c.iterate, c.destroy > 0, c.delete > 0:
+			return fmt.Errorf("-key-scheme %s only changes the merkle trie keys, the snapshots, preimages, proofs, iterators and storage wiping keep hashing them: not supported with -verkle, -snapshot, -preimages, -proofs, -iterate, -destroy or -delete", keySchemeRaw)
+		}
+	default:
+		return fmt.Errorf("unknown key scheme %q, want %s or %s", c.keyScheme, keySchemeSecure, keySchemeRaw)
+	}
+	return nil
+}
+
+// checkInsertOrder checks the order of the trie updates.
+func checkInsertOrder(c *config) error {
+	switch c.insertOrder {
+	case insertOrderRandom:
+	case insertOrderSorted:
+		if c.flatStorage || c.verkle {
+			return fmt.Errorf("-insert-order %s orders the updates of the merkle storage tries, not supported with -flat-storage or -verkle", insertOrderSorted)
+		}
+	default:
+		return fmt.Errorf("unknown insert order %q, want %s or %s", c.insertOrder, insertOrderRandom, insertOrderSorted)
+	}
+	return nil
+}
+
+// checkHashCache checks the bypass of the cached node hashes.
+func checkHashCache(c *config) error {
+	if c.noHashCache && c.verkle {
+		return fmt.Errorf("-no-hash-cache bypasses the node hashes cached by the merkle tries, not supported with -verkle")
+	}
+	return nil
+}
+
+// checkVerkle checks the verkle state.
+func checkVerkle(c *config) error {
+	if c.verkle {
+		if c.scheme != rawdb.PathScheme {
+			return fmt.Errorf("verkle state requires -scheme %s", rawdb.PathScheme)
+		}
+		switch {
+		case c.shards > 1, len(c.compare) > 0, c.verifyTrie, c.compactTrie, c.reads > 0, c.readers > 0, c.proofs > 0, c.iterate, c.destroy > 0, c.delete > 0:
+			return fmt.Errorf("verkle state only supports the creation and modification phases of unsharded runs")
+		}
+	}
+	return nil
+}
+
+// checkStateHistory checks the retention and measurement of the pathdb state history.
+func checkStateHistory(c *config) error {
+	if c.archive && c.scheme != rawdb.PathScheme {
+		return fmt.Errorf("-archive retains the state history of pathdb, requires -scheme %s", rawdb.PathScheme)
+	}
+	if c.freezer {
+		switch {
+		case c.scheme != rawdb.PathScheme:
+			return fmt.Errorf("-freezer-stats measures the state history of pathdb, requires -scheme %s", rawdb.PathScheme)
+		case c.dryRun, c.shards > 1, c.instances > 1:
+			return fmt.Errorf("-freezer-stats measures the freezer of a single state on disk, not supported in dry-run mode, with -shards or -instances")
+		}
+	}
+	return nil
+}
+
+// checkResume checks resuming from an existing root.
+func checkResume(c *config) error {
+	if c.resumeRoot != nil && c.clear {
+		return fmt.Errorf("resuming from an existing root requires -clear=false")
+	}
+	return nil
+}
+
+// checkDurability checks the syncing, write-ahead log and reopening of the database.
+func checkDurability(c *config) error {
+	if c.syncCommit && (c.noWAL || c.dryRun) {
+		return fmt.Errorf("-sync-commit needs the write-ahead log on disk, not supported with -no-wal or -dry-run")
+	}
+	if c.noWAL && (c.resumeRoot != nil || c.reopen) {
+		return fmt.Errorf("resuming and reopening need a durable database, not supported with -no-wal")
+	}
+	if c.reopen && c.dryRun {
+		return fmt.Errorf("reopening needs a committed state, not supported in dry-run mode")
+	}
+	return nil
+}
+
+// checkFlatStorage checks the flat storage layout.
+func checkFlatStorage(c *config) error {
+	if c.flatStorage {
+		switch {
+		case c.dryRun, c.shards > 1, c.batchSlots, c.snapshot, c.warmup > 0, c.reads > 0, c.proofs > 0, c.iterate, c.destroy > 0, c.delete > 0:
+			return fmt.Errorf("flat storage only supports the creation and modification phases on disk")
+		}
+	}
+	return nil
+}
+
+// checkSnapshot checks the snapshot generation.
+func checkSnapshot(c *config) error {
+	if c.snapshot && c.scheme != rawdb.HashScheme {
+		return fmt.Errorf("the path scheme maintains its own flat state, -snapshot requires -scheme %s", rawdb.HashScheme)
+	}
+	if c.snapshot && c.dryRun {
+		return fmt.Errorf("snapshot generation needs a committed state, not supported in dry-run mode")
+	}
+	return nil
+}
+
+// checkCapLayers checks the capping of the pathdb diff layers.
+func checkCapLayers(c *config) error {
+	if c.capLayers < 0 || c.capLayers > maxCapLayers {
+		return fmt.Errorf("invalid diff layer count %d, want 0 <= layers <= %d", c.capLayers, maxCapLayers)
+	}
+	if c.capLayers > 0 && c.scheme != rawdb.PathScheme {
+		return fmt.Errorf("-cap-layers requires -scheme %s", rawdb.PathScheme)
+	}
+	if c.capLayers > 0 && c.dryRun {
+		return fmt.Errorf("capping layers needs committed states, not supported in dry-run mode")
+	}
+	return nil
+}
+
+// checkShards checks the sharded runs.
+func checkShards(c *config) error {
+	if c.shards <= 0 {
+		return fmt.Errorf("invalid shard count %d", c.shards)
+	}
+	if c.shards > 1 {
+		switch {
+		case c.resumeRoot != nil, c.dryRun, c.snapshot, c.reopen, c.measureHash, c.measureOpen, c.compact, c.compactTrie, c.verifyTrie, c.rehash, c.zeroNoop > 0, c.dumpPath != "", c.duration > 0, c.warmup > 0, c.reads > 0, c.proofs > 0, c.iterate, c.destroy > 0, c.delete > 0, c.prealloc:
+			return fmt.Errorf("sharded runs only support the creation and modification phases")
+		}
+	}
+	return nil
+}
+
+// checkInstances checks the concurrent instances.
+func checkInstances(c *config) error {
+	if c.instances <= 0 {
+		return fmt.Errorf("invalid instance count %d", c.instances)
+	}
+	if c.instances > 1 {
+		switch {
+		case c.shards > 1, len(c.compare) > 0, c.crashAfter > 0, c.recover:
+			return fmt.Errorf("-instances is not supported with -shards, -compare, -inject-crash-after or -recover")
+		case c.resumeRoot != nil, c.dryRun, c.snapshot, c.reopen, c.flatStorage, c.verkle, c.measureHash, c.measureOpen, c.compact, c.compactTrie, c.verifyTrie, c.dumpPath != "", c.replayPath != "", c.csvPath != "",
+			c.duration > 0, c.warmup > 0, c.blocks > 0, c.readers > 0, c.reads > 0, c.proofs > 0, c.iterate, c.destroy > 0, c.delete > 0, c.verifyMods > 0, c.zeroNoop > 0, c.rehash, c.trackAccess, c.maxHeapMB > 0:
+			return fmt.Errorf("concurrent instances only support the creation and modification phases")
+		}
+	}
+	return nil
+}
+
+// checkOutput checks the output format and progress reports.
+func checkOutput(c *config) error {
+	switch c.output {
+	case outputText, outputJSON, outputBench:
+	default:
+		return fmt.Errorf("unknown output format %q", c.output)
+	}
+	if c.progressInterval < 0 {
+		return fmt.Errorf("invalid progress interval %v", c.progressInterval)
+	}
+	return nil
+}
+
+// checkMeasureHash checks the timing of the intermediate hashing.
+func checkMeasureHash(c *config) error {
+	if c.measureHash && c.dryRun {
+		return fmt.Errorf("dry runs only hash the state, -measure-intermediate needs commits to compare against")
+	}
+	return nil
+}
+
+// checkSysstat checks the sampling of the process stats.
+func checkSysstat(c *config) error {
+	if c.sysstat && c.instances > 1 {
+		return fmt.Errorf("-sysstat samples the whole process, not supported with -instances running concurrently")
+	}
+	return nil
+}
+
+// checkGrowth checks the growth report of the creation phase.
+func checkGrowth(c *config) error {
+	if c.growth && (c.resumeRoot != nil || c.replayPath != "" || c.shards > 1 || c.instances > 1) {
+		return fmt.Errorf("-growth-report samples the creation phase of a single state, not supported with resuming, -replay, -shards or -instances")
+	}
+	return nil
+}
+
+// checkCompact checks the compaction before the report.
+func checkCompact(c *config) error {
+	if (c.compact || c.compactTrie) && c.dryRun {
+		return fmt.Errorf("dry runs leave nothing on disk to compact")
+	}
+	return nil
+}
+
+// checkDetailed checks the detailed commit timings.
+func checkDetailed(c *config) error {
+	if c.detailed && (c.dryRun || c.shards > 1) {
+		return fmt.Errorf("-detailed-commit times the commits of a single state on disk, not supported in dry-run mode or sharded runs")
+	}
+	return nil
+}
+
+// checkChecksum checks the checksum of the key-value store.
+func checkChecksum(c *config) error {
+	if c.checksum && (c.dryRun || c.shards > 1 || c.instances > 1) {
+		return fmt.Errorf("-checksum hashes the single key-value store of a run, not supported in dry-run mode, sharded runs or with -instances")
+	}
+	return nil
+}
+
+// checkDepthReport checks the depth report of the committed tries.
+func checkDepthReport(c *config) error {
+	if c.depthReport && (c.dryRun || c.verkle) {
+		return fmt.Errorf("-depth-report iterates the committed merkle trie, not supported in dry-run or verkle mode")
+	}
+	if c.depthTries && (!c.depthReport || c.flatStorage) {
+		return fmt.Errorf("-depth-report-storage iterates the storage tries, requires -depth-report without -flat-storage")
+	}
+	return nil
+}
+
+// checkTrackAccess checks the counting of the SetState calls.
+func checkTrackAccess(c *config) error {
+	if c.trackAccess && (c.batchSlots || c.shards > 1) {
+		return fmt.Errorf("-track-access counts the SetState calls of a single state, not supported with -batch-slots or sharded runs")
+	}
+	return nil
+}
+
+// checkMaxHeap checks the heap cap.
+func checkMaxHeap(c *config) error {
+	if c.maxHeapMB < 0 {
+		return fmt.Errorf("invalid heap cap %d MB", c.maxHeapMB)
+	}
+	if c.maxHeapMB > 0 && c.shards > 1 {
+		return fmt.Errorf("-max-heap-mb guards the heap of a single state, not supported in sharded runs")
+	}
+	return nil
+}
+
+// checkDump checks the dump of the written keys.
+func checkDump(c *config) error {
+	if c.dumpPath != "" && (c.dumpSample <= 0 || c.dumpSample > 100) {
+		return fmt.Errorf("invalid dump sample %v%%, want 0 < sample <= 100", c.dumpSample)
+	}
+	return nil
+}
+
+// checkSweep checks the sweep over the commit worker counts.
+func checkSweep(c *config) error {
+	if c.sweepWorkers {
+		switch {
+		case c.commitWorkers > 0:
+			return fmt.Errorf("-commit-workers-sweep runs every commit worker count itself, not supported with -commit-workers")
+		case len(c.compare) > 0, c.shards > 1, c.instances > 1, c.crashAfter > 0, c.recover:
+			return fmt.Errorf("-commit-workers-sweep is not supported with -compare, -shards, -instances, -inject-crash-after or -recover")
+		case c.resumeRoot != nil, c.verkle, c.flatStorage:
+			return fmt.Errorf("-commit-workers-sweep needs fresh storage tries, not supported with resuming, -verkle or -flat-storage")
+		}
+	}
+	return nil
+}
+
+// checkIterations checks the repeated runs.
+func checkIterations(c *config) error {
+	if c.iterations <= 0 {
+		return fmt.Errorf("invalid iteration count %d", c.iterations)
+	}
+	if c.iterations > 1 {
+		switch {
+		case c.sweepWorkers, len(c.compare) > 0, c.crashAfter > 0, c.recover:
+			return fmt.Errorf("-iterations is not supported with -commit-workers-sweep, -compare, -inject-crash-after or -recover")
+		case c.rngLogPath != "" || c.rngVerifyPath != "":
+			return fmt.Errorf("-iterations draws the workload once per run, not supported with -rng-log or -rng-verify")
+		case c.resumeRoot != nil && c.scheme != rawdb.HashScheme:
+			return fmt.Errorf("-iterations resuming from an existing state needs -scheme %s, the path scheme moves its disk layer past the resumed root", rawdb.HashScheme)
+		}
+	}
+	return nil
+}
+
+// checkCompare checks the two schemes compared.
+func checkCompare(c *config) error {
+	if len(c.compare) == 0 {
+		return nil
+	}
+	for _, scheme := range c.compare {
+		switch scheme {
+		case rawdb.PathScheme, rawdb.HashScheme, compareFlat:
+		case compareRaw:
+			if c.keyScheme == keySchemeRaw {
+				return fmt.Errorf("comparing raw trie keys runs the baseline with hashed ones, not supported with -key-scheme %s", keySchemeRaw)
+			}
+		case compareAccount:
+			if c.batch == 1 {
+				return fmt.Errorf("comparing per-account commits runs the baseline in batches, not supported with -k 1")
+			}
+		case comparePrealloc:
+			if c.prealloc {
+				return fmt.Errorf("comparing pre-sized maps runs the baseline growing them, not supported with -prealloc")
+			}
+		case compareNoHashCache:
+			if c.noHashCache {
+				return fmt.Errorf("comparing cold hashing runs the baseline with the hash cache, not supported with -no-hash-cache")
+			}
+		case comparePreimages:
+			if c.preimages {
+				return fmt.Errorf("comparing preimage recording runs the baseline without it, not supported with -preimages")
+			}
+		case compareArchive:
+			if c.scheme != rawdb.PathScheme || c.history == 0 {
+				return fmt.Errorf("comparing archive retention runs the baseline pruning the state history, requires -scheme %s and a -history limit", rawdb.PathScheme)
+			}
+		case compareSorted:
+			if c.insertOrder == insertOrderSorted {
+				return fmt.Errorf("comparing sorted trie insertion runs the baseline in random order, not supported with -insert-order %s", insertOrderSorted)
+			}
+		case compareSync:
+			if c.syncCommit {
+				return fmt.Errorf("comparing synced commits runs the baseline unsynced, not supported with -sync-commit")
+			}
+		case compareSnapshot:
+			if c.scheme != rawdb.HashScheme {
+				return fmt.Errorf("the path scheme maintains its own flat state, comparing snapshot commits requires -scheme %s", rawdb.HashScheme)
+			}
+		default:
+			return fmt.Errorf("unknown state scheme %q to compare", scheme)
+		}
+	}
+	if c.compare[0] == c.compare[1] {
+		return fmt.Errorf("comparing the %s scheme against itself", c.compare[0])
+	}
+	switch {
+	case c.resumeRoot != nil, c.snapshot, c.capLayers > 0:
+		return fmt.Errorf("-compare doesn't support resuming, snapshots or capping layers, which only apply to one scheme")
+	}
+	return nil
+}
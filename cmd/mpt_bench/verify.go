@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb/database"
+)
+
+// verifyResult contains the outcome of a full trie integrity check.
+type verifyResult struct {
+	Root         common.Hash   `json:"root"`
+	Accounts     int           `json:"accounts"`
+	AccountNodes int           `json:"accountNodes"`
+	StorageNodes int           `json:"storageNodes"`
+	Elapsed      time.Duration `json:"elapsedNs"`
+}
+
+// runVerify checks the integrity of the existing state at the configured root.
+func runVerify(cfg *config, out io.Writer) (*verifyResult, error) {
+	stores, err := openStores(cfg, cfg.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer stores.diskdb.Close()
+
+	b := &bench{stores: *stores, cfg: cfg, out: out, progress: newProgress(cfg, out), root: *cfg.resumeRoot}
+	fmt.Fprintf(out, "Verifying trie at root %x...\n", b.root)
+	res, err := b.verifyTrie()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(out)
+	return res, nil
+}
+
+// verifyTrie iterates the account trie at the current root along with every
+// storage trie, checking that each node stored on its own hashes to the key
+// it is referenced by. The first missing or corrupted node fails the check.
+func (b *bench) verifyTrie() (*verifyResult, error) {
+	var (
+		res   = &verifyResult{Root: b.root}
+		start = time.Now()
+	)
+	reader, err := b.trieDB.NodeReader(b.root)
+	if err != nil {
+		return nil, fmt.Errorf("state %x is not available: %v", b.root, err)
+	}
+	accTrie, err := trie.NewStateTrie(trie.StateTrieID(b.root), b.trieDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open account trie: %v", err)
+	}
+	accIter, err := accTrie.NodeIterator(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate account trie: %v", err)
+	}
+	for accIter.Next(true) {
+		res.AccountNodes++
+		if err := verifyNode(reader, common.Hash{}, accIter); err != nil {
+			return nil, err
+		}
+		if !accIter.Leaf() {
+			continue
+		}
+		res.Accounts++
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			return nil, fmt.Errorf("invalid account %x: %v", accIter.LeafKey(), err)
+		}
+		if acc.Root == types.EmptyRootHash {
+			continue
+		}
+		owner := b.leafOwner(accIter.LeafKey())
+		storageTrie, err := trie.NewStateTrie(trie.StorageTrieID(b.root, owner, acc.Root), b.trieDB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open storage trie %x of %x: %v", acc.Root, owner, err)
+		}
+		storageIter, err := storageTrie.NodeIterator(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate storage trie %x of %x: %v", acc.Root, owner, err)
+		}
+		for storageIter.Next(true) {
+			res.StorageNodes++
+			if err := verifyNode(reader, owner, storageIter); err != nil {
+				return nil, err
+			}
+		}
+		if err := storageIter.Error(); err != nil {
+			return nil, fmt.Errorf("failed to iterate storage trie %x of %x: %v", acc.Root, owner, err)
+		}
+		if res.Accounts%1000 == 0 {
+			b.progress.update("verification", res.Accounts, 0, "...verified %d accounts, %d nodes", res.Accounts, res.AccountNodes+res.StorageNodes)
+		}
+	}
+	if err := accIter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to iterate account trie: %v", err)
+	}
+	res.Elapsed = time.Since(start)
+	return res, nil
+}
+
+// verifyNode checks that the node the iterator is positioned at is present in
+// the database and hashes to its key. Nodes embedded in their parent have no
+// hash of their own and are covered by the parent's check.
+func verifyNode(reader database.NodeReader, owner common.Hash, it trie.NodeIterator) error {
+	hash := it.Hash()
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	kind := "account"
+	if owner != (common.Hash{}) {
+		kind = fmt.Sprintf("storage (owner %x)", owner)
+	}
+	blob, err := reader.Node(owner, it.Path(), hash)
+	if err != nil || len(blob) == 0 {
+		return fmt.Errorf("missing %s trie node at path %x, hash %x: %v", kind, it.Path(), hash, err)
+	}
+	if have := crypto.Keccak256Hash(blob); have != hash {
+		return fmt.Errorf("corrupted %s trie node at path %x: hash %x, content hashes to %x", kind, it.Path(), hash, have)
+	}
+	return nil
+}
+
+// print implements report, writing the outcome of a standalone check into w.
+func (v *verifyResult) print(w io.Writer, format string) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	printVerify(w, v)
+	return nil
+}
+
+// exitCode implements report, a failed check is returned as an error instead.
+func (v *verifyResult) exitCode() int {
+	return 0
+}
+
+// printVerify writes the outcome of a trie integrity check into w, if one ran.
+func printVerify(w io.Writer, v *verifyResult) {
+	if v == nil {
+		return
+	}
+	fmt.Fprintf(w, "Trie Verified: %d accounts | %d account nodes, %d storage nodes | %v\n",
+		v.Accounts, v.AccountNodes, v.StorageNodes, v.Elapsed)
+}
+
+// rehashResult contains the outcome of rebuilding the state trie from scratch.
+type rehashResult struct {
+	Root     common.Hash   `json:"root"`        // Incrementally maintained root
+	Rebuilt  common.Hash   `json:"rebuiltRoot"` // Root of the trie rebuilt from the leaves
+	Accounts int           `json:"accounts"`
+	Slots    int           `json:"slots"`
+	Elapsed  time.Duration `json:"elapsedNs"`
+}
+
+// rehashTrie iterates every account and slot at the current root and inserts
+// them into fresh tries, recomputing each storage root and the account root
+// from the leaves alone. The leaves are iterated in key order, so stack tries
+// hash them without holding the tries in memory. A root differing from the
+// incrementally maintained one fails the check, pointing to a bug in the
+// update or deletion handling.
+func (b *bench) rehashTrie() (*rehashResult, error) {
+	var (
+		res   = &rehashResult{Root: b.root}
+		start = time.Now()
+		accST = trie.NewStackTrie(nil)
+	)
+	accTrie, err := trie.NewStateTrie(trie.StateTrieID(b.root), b.trieDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open account trie: %v", err)
+	}
+	accIter, err := accTrie.NodeIterator(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate account trie: %v", err)
+	}
+	for accIter.Next(true) {
+		if !accIter.Leaf() {
+			continue
+		}
+		res.Accounts++
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			return nil, fmt.Errorf("invalid account %x: %v", accIter.LeafKey(), err)
+		}
+		if acc.Root != types.EmptyRootHash {
+			owner := b.leafOwner(accIter.LeafKey())
+			storageTrie, err := trie.NewStateTrie(trie.StorageTrieID(b.root, owner, acc.Root), b.trieDB)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open storage trie %x of %x: %v", acc.Root, owner, err)
+			}
+			storageIter, err := storageTrie.NodeIterator(nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to iterate storage trie %x of %x: %v", acc.Root, owner, err)
+			}
+			storageST := trie.NewStackTrie(nil)
+			for storageIter.Next(true) {
+				if !storageIter.Leaf() {
+					continue
+				}
+				res.Slots++
+				if err := storageST.Update(storageIter.LeafKey(), storageIter.LeafBlob()); err != nil {
+					return nil, fmt.Errorf("failed to insert slot %x of %x: %v", storageIter.LeafKey(), owner, err)
+				}
+			}
+			if err := storageIter.Error(); err != nil {
+				return nil, fmt.Errorf("failed to iterate storage trie %x of %x: %v", acc.Root, owner, err)
+			}
+			if rebuilt := storageST.Hash(); rebuilt != acc.Root {
+				return nil, fmt.Errorf("storage root mismatch of %x: incremental %x, rebuilt %x", owner, acc.Root, rebuilt)
+			}
+		}
+		blob, err := rlp.EncodeToBytes(&acc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode account %x: %v", accIter.LeafKey(), err)
+		}
+		if err := accST.Update(accIter.LeafKey(), blob); err != nil {
+			return nil, fmt.Errorf("failed to insert account %x: %v", accIter.LeafKey(), err)
+		}
+		if res.Accounts%1000 == 0 {
+			b.progress.update("rehash", res.Accounts, int64(res.Slots), "...rehashed %d accounts, %d slots", res.Accounts, res.Slots)
+		}
+	}
+	if err := accIter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to iterate account trie: %v", err)
+	}
+	res.Rebuilt = accST.Hash()
+	res.Elapsed = time.Since(start)
+	if res.Rebuilt != res.Root {
+		return nil, fmt.Errorf("root mismatch: incremental %x, rebuilt from %d accounts and %d slots %x", res.Root, res.Accounts, res.Slots, res.Rebuilt)
+	}
+	return res, nil
+}
+
+// printRehash writes the outcome of the full rebuild into w, if it ran.
+func printRehash(w io.Writer, r *rehashResult) {
+	if r == nil {
+		return
+	}
+	fmt.Fprintf(w, "Full Rehash:   %d accounts, %d slots in %v | incremental root %x, rebuilt %x\n",
+		r.Accounts, r.Slots, r.Elapsed, r.Root, r.Rebuilt)
+}
+
+// compactCheck contains the full trie checks run at the final root before the
+// key-value store is compacted and after the databases are reopened on top of
+// the rewritten tables.
+type compactCheck struct {
+	Before *verifyResult `json:"before"`
+	After  *verifyResult `json:"after"`
+}
+
+// compactVerified compacts the whole key-value store like compact, checking
+// every trie node of the current root before the compaction and again after
+// reopening the databases, so no cache can hide a node the compaction lost.
+// A missing or corrupted node, or any difference in the root and the node
+// counts between the two checks, fails the run.
+func (b *bench) compactVerified(before int64) (*compactStats, error) {
+	check := new(compactCheck)
+	var err error
+	if check.Before, err = b.verifyTrie(); err != nil {
+		return nil, fmt.Errorf("trie check before compaction: %v", err)
+	}
+	res, err := b.compact(before)
+	if err != nil {
+		return nil, err
+	}
+	// In path mode the buffered layers were journaled at the end of the run
+	// and are recovered from the journal on reopen
+	if err := b.reopenStores(); err != nil {
+		return nil, err
+	}
+	if check.After, err = b.verifyTrie(); err != nil {
+		return nil, fmt.Errorf("trie check after compaction: %v", err)
+	}
+	if have, want := check.After, check.Before; have.Root != want.Root || have.Accounts != want.Accounts || have.AccountNodes != want.AccountNodes || have.StorageNodes != want.StorageNodes {
+		return nil, fmt.Errorf("trie changed over the compaction: root %x with %d accounts, %d account nodes, %d storage nodes before, root %x with %d accounts, %d account nodes, %d storage nodes after",
+			want.Root, want.Accounts, want.AccountNodes, want.StorageNodes, have.Root, have.Accounts, have.AccountNodes, have.StorageNodes)
+	}
+	res.Check = check
+	return res, nil
+}
+
+// printCompactCheck writes the node counts found around the compaction into
+// w, if checked.
+func printCompactCheck(w io.Writer, c *compactCheck) {
+	if c == nil {
+		return
+	}
+	fmt.Fprintf(w, "Compact Check: %d account nodes, %d storage nodes before and after reopening (root %x intact, %v and %v)\n",
+		c.After.AccountNodes, c.After.StorageNodes, c.After.Root, c.Before.Elapsed.Round(time.Millisecond), c.After.Elapsed.Round(time.Millisecond))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// modCheck contains the outcome of reading back the sampled writes of phase 2.
+type modCheck struct {
+	Root    common.Hash   `json:"root"`
+	Slots   int           `json:"slots"`  // Sampled slots read back
+	Writes  int64         `json:"writes"` // Slot writes of the phase sampled from
+	Elapsed time.Duration `json:"elapsedNs"`
+}
+
+// sampledSlot is a slot written in phase 2.
+type sampledSlot struct {
+	addr common.Address
+	key  common.Hash
+}
+
+// modSample is a uniform sample of the slots written in phase 2 along with the
+// last value written into each, picked by reservoir sampling over the writes.
+// A slot written again after it was sampled has its value updated, so the
+// recorded value is always the final one.
+type modSample struct {
+	size   int
+	seen   int64 // Writes of slots not in the sample when written
+	r      *rand.Rand
+	slots  []sampledSlot
+	values []common.Hash
+	index  map[sampledSlot]int
+}
+
+// newModSample creates an empty sample of at most size slots.
+func newModSample(size int) *modSample {
+	return &modSample{
+		size:  size,
+		r:     rand.New(rand.NewSource(1)), // Independent of the workload's random source
+		index: make(map[sampledSlot]int, size),
+	}
+}
+
+// add records a write of value into the slot key of addr.
+func (s *modSample) add(addr common.Address, key, value common.Hash) {
+	slot := sampledSlot{addr: addr, key: key}
+	if i, ok := s.index[slot]; ok {
+		s.values[i] = value
+		return
+	}
+	s.seen++
+	if len(s.slots) < s.size {
+		s.index[slot] = len(s.slots)
+		s.slots = append(s.slots, slot)
+		s.values = append(s.values, value)
+		return
+	}
+	if i := s.r.Int63n(s.seen); i < int64(s.size) {
+		delete(s.index, s.slots[i])
+		s.index[slot] = int(i)
+		s.slots[i], s.values[i] = slot, value
+	}
+}
+
+// sampledWriter forwards the slot writes to the wrapped destination, adding
+// them to the sample on the way.
+type sampledWriter struct {
+	storageWriter
+	sample *modSample
+}
+
+// SetState implements storageWriter.
+func (w sampledWriter) SetState(addr common.Address, key, value common.Hash) common.Hash {
+	w.sample.add(addr, key, value)
+	return w.storageWriter.SetState(addr, key, value)
+}
+
+// verifyMods opens a fresh state at the current root and checks that every
+// sampled slot holds the value it was last written, failing on the first
+// mismatches to catch writes silently lost on the commit path.
+func (b *bench) verifyMods(sample *modSample) (*modCheck, error) {
+	start := time.Now()
+	statedb, err := state.New(b.root, b.sdb)
+	if err != nil {
+		return nil, fmt.Errorf("state %x is not available: %v", b.root, err)
+	}
+	var (
+		lost  int
+		first error
+	)
+	for i, slot := range sample.slots {
+		have := statedb.GetState(slot.addr, slot.key)
+		if err := statedb.Error(); err != nil {
+			return nil, fmt.Errorf("failed to read slot %x of %x: %v", slot.key, slot.addr, err)
+		}
+		if want := sample.values[i]; have != want {
+			if lost == 0 {
+				first = fmt.Errorf("slot %x of %x holds %x, last written %x", slot.key, slot.addr, have, want)
+			}
+			lost++
+		}
+	}
+	if lost > 0 {
+		return nil, fmt.Errorf("%d/%d sampled slot writes lost at root %x, first: %v", lost, len(sample.slots), b.root, first)
+	}
+	return &modCheck{
+		Root:    b.root,
+		Slots:   len(sample.slots),
+		Elapsed: time.Since(start),
+	}, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/bintrie"
+)
+
+const (
+	treeMPT    = "mpt"
+	treeBinary = "binary"
+	treeVerkle = "verkle"
+)
+
+// treeType opens the account trie at root, returning the kind of tree the
+// state is built with. The verkle mode of the trie database is served by
+// whichever stateless tree the build implements, the binary trie currently.
+func treeType(sdb *state.CachingDB, root common.Hash) (string, error) {
+	tr, err := sdb.OpenTrie(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to open account trie: %v", err)
+	}
+	switch tr.(type) {
+	case *bintrie.BinaryTrie:
+		return treeBinary, nil
+	case *trie.VerkleTrie:
+		return treeVerkle, nil
+	default:
+		return treeMPT, nil
+	}
+}
+
+// commitStats contains the sizes of the tree nodes committed in a phase,
+// each of which carries one commitment to its children.
+type commitStats struct {
+	Nodes int64   `json:"nodes"`
+	Bytes int64   `json:"bytes"`
+	Avg   float64 `json:"avgNodeBytes"`
+}
+
+// commitments derives the committed node sizes from the node statistics of a
+// phase, nil if they weren't tracked.
+func commitments(nodes *nodeStats) *commitStats {
+	if nodes == nil || nodes.Written == 0 {
+		return nil
+	}
+	return &commitStats{
+		Nodes: nodes.Written,
+		Bytes: nodes.FlushedBytes,
+		Avg:   float64(nodes.FlushedBytes) / float64(nodes.Written),
+	}
+}
+
+// printTree writes the tree the state was built with into w, along with the
+// commitment sizes of the stateless trees.
+func printTree(w io.Writer, r *result) {
+	switch r.Tree {
+	case treeMPT:
+		fmt.Fprintf(w, "Tree:          merkle patricia trie\n")
+		return
+	case treeBinary:
+		fmt.Fprintf(w, "Tree:          binary trie (verkle mode, 32-byte commitments)\n")
+	case treeVerkle:
+		fmt.Fprintf(w, "Tree:          verkle trie\n")
+	}
+	for _, p := range []*phaseResult{r.Creation, r.Modification} {
+		if p != nil && p.Commitment != nil {
+			fmt.Fprintf(w, "Commitments:   %s %d nodes, %.2f MB (avg %.0f bytes per node)\n",
+				p.Name, p.Commitment.Nodes, float64(p.Commitment.Bytes)/(1024*1024), p.Commitment.Avg)
+		}
+	}
+	// Witnesses can't be collected from the binary trie of this build yet
+	fmt.Fprintf(w, "Witness:       n/a, not implemented by the %s trie\n", r.Tree)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// warmup writes w accounts before the measured phases start, so that the lazy
+// Pebble initialization and the cold caches don't skew the first batches. The
+// accounts live in a dedicated key namespace and use their own random source,
+// leaving the measured workload untouched.
+func (b *bench) warmup(ctx context.Context, phase *phaseResult, w int) error {
+	var (
+		r     = b.cfg.rng.newRand("warmup", 7)
+		slots int64
+		start = time.Now()
+	)
+	b.batchStart = start
+
+	// The warm-up blocks are numbered apart, leave the numbering of the
+	// measured phases where it was
+	defer func(next uint64) { b.next = next }(b.next)
+	for i := 0; i < w; i++ {
+		addr := common.BytesToAddress(crypto.Keccak256([]byte(fmt.Sprintf("warmup-account-%d", i)))[:20])
+		b.statedb.SetBalance(addr, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
+		b.statedb.SetNonce(addr, uint64(i), tracing.NonceChangeUnspecified)
+
+		var n int
+		if b.cfg.slots > 0 {
+			n = r.Intn(b.cfg.slots * 2)
+		}
+		vals := slotValues(r, n)
+		for j := 0; j < n; j++ {
+			key := common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("warmup-acc-%d-slot-%d", i, j))))
+			b.statedb.SetState(addr, key, vals[j])
+		}
+		slots += int64(n)
+
+		interrupted := ctx.Err() != nil
+		if (i+1)%b.cfg.batch == 0 || i+1 == w || interrupted {
+			if _, err := b.commit(phase, uint64(i/b.cfg.batch)+3000000, i+1, slots); err != nil { // different block space
+				return err
+			}
+			b.progress.update(phase.Name, i+1, slots, "...warmed up with %d/%d accounts (%.1f%%)", i+1, w, float64(i+1)/float64(w)*100)
+		}
+		if interrupted {
+			phase.Accounts = i + 1
+			phase.finish(slots, time.Since(start), b.root)
+			return errInterrupted
+		}
+	}
+	phase.finish(slots, time.Since(start), b.root)
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	distUniform = "uniform"
+	distZipf    = "zipf"
+)
+
+const (
+	addrModeHashed     = "hashed"
+	addrModeSequential = "sequential"
+	addrModePrefixed   = "prefixed"
+)
+
+const (
+	slotDistUniform = "uniform"
+	slotDistNormal  = "normal"
+	slotDistPareto  = "pareto"
+)
+
+// maxSlotsPerAccount caps the slot count drawn from the unbounded tail of the
+// pareto distribution, keeping a single account within memory.
+const maxSlotsPerAccount = 1 << 24
+
+// minParallelSlots is the slot count below which deriving the keys on a single
+// goroutine is cheaper than spinning up the workers.
+const minParallelSlots = 256
+
+// accountAddress derives the address of the i-th account in the given mode.
+//
+// The account trie is keyed by the hash of the address, so sequential addresses
+// still end up spread uniformly. Only the prefixed mode clusters the trie keys,
+// by grinding the address until its hash starts with a zero nibble.
+func accountAddress(mode string, i int) common.Address {
+	switch mode {
+	case addrModeSequential:
+		var addr common.Address
+		binary.BigEndian.PutUint64(addr[common.AddressLength-8:], uint64(i))
+		return addr
+	case addrModePrefixed:
+		for nonce := 0; ; nonce++ {
+			addr := common.BytesToAddress(crypto.Keccak256([]byte(fmt.Sprintf("account-%d-%d", i, nonce)))[:20])
+			if crypto.Keccak256(addr.Bytes())[0]>>4 == 0 {
+				return addr
+			}
+		}
+	default:
+		return common.BytesToAddress(crypto.Keccak256([]byte(fmt.Sprintf("account-%d", i)))[:20])
+	}
+}
+
+// slotKey derives the unique storage key of the j-th slot of the i-th account.
+func slotKey(i, j int) common.Hash {
+	return common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("acc-%d-slot-%d", i, j))))
+}
+
+// slotKeys derives the first n slot keys of the given account, spreading the
+// hashing over the requested number of workers.
+func slotKeys(account int, n int, workers int) []common.Hash {
+	keys := make([]common.Hash, n)
+	if workers <= 1 || n < minParallelSlots {
+		for j := range keys {
+			keys[j] = slotKey(account, j)
+		}
+		return keys
+	}
+	var (
+		wg    sync.WaitGroup
+		chunk = (n + workers - 1) / workers
+	)
+	for start := 0; start < n; start += chunk {
+		end := min(start+chunk, n)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := start; j < end; j++ {
+				keys[j] = slotKey(account, j)
+			}
+		}()
+	}
+	wg.Wait()
+	return keys
+}
+
+// slotCount draws the number of slots of an account from r, following the
+// configured slot count distribution with a mean of cfg.slots.
+func slotCount(cfg *config, r *rand.Rand) int {
+	if cfg.slots == 0 {
+		return 0 // Account-only workload, nothing to draw
+	}
+	switch cfg.slotDist {
+	case slotDistNormal:
+		n := math.Round(float64(cfg.slots) + r.NormFloat64()*cfg.slotStddev*float64(cfg.slots))
+		return int(math.Max(0, math.Min(n, maxSlotsPerAccount)))
+	case slotDistPareto:
+		// Pick the scale yielding the requested mean, alpha/(alpha-1) times it
+		scale := float64(cfg.slots) * (cfg.paretoAlpha - 1) / cfg.paretoAlpha
+		n := math.Round(scale / math.Pow(1-r.Float64(), 1/cfg.paretoAlpha))
+		return int(math.Min(n, maxSlotsPerAccount))
+	default:
+		// Borrowed from C#: Variable slots to simulate real world distribution (avg nSlots)
+		return r.Intn(cfg.slots * 2)
+	}
+}
+
+// slotBudget caps the slots of the accounts created in phase 1 to the total of
+// -total-slots. The slot counts are still drawn in full, the accounts after the
+// cap are left with fewer slots or none, so that the capped workload is the
+// prefix of the uncapped one.
+type slotBudget struct {
+	left int64
+}
+
+// newSlotBudget returns the slot budget of cfg, nil if uncapped.
+func newSlotBudget(cfg *config) *slotBudget {
+	if cfg.totalSlots == 0 {
+		return nil
+	}
+	return &slotBudget{left: cfg.totalSlots}
+}
+
+// take returns the number of the n slots drawn for the next account that fit
+// into the budget, deducting them.
+func (s *slotBudget) take(n int) int {
+	if s == nil {
+		return n
+	}
+	n = int(min(int64(n), s.left))
+	s.left -= int64(n)
+	return n
+}
+
+// printSlotCap writes whether phase 1 wrote exactly the capped total of slots
+// into w, if capped.
+func printSlotCap(w io.Writer, limit, written int64) {
+	switch {
+	case limit == 0:
+		return
+	case written == limit:
+		fmt.Fprintf(w, "Slot Cap:      %d total slots, reached exactly\n", limit)
+	default:
+		fmt.Fprintf(w, "Slot Cap:      %d total slots, NOT reached: the accounts drew %d slots only\n", limit, written)
+	}
+}
+
+// slotValues draws n slot values from the random source. The values are always
+// drawn sequentially so the generated workload only depends on the seed, never
+// on the number of workers deriving the keys.
+func slotValues(r *rand.Rand, n int) []common.Hash {
+	vals := make([]common.Hash, n)
+	for j := range vals {
+		// Borrowed from C#: 30% probability for zero or small values to test RLP compression
+		dice := r.Intn(100)
+		if dice < 20 {
+			// Keep zero
+		} else if dice < 30 {
+			vals[j][31] = 1 // Small value
+		} else {
+			r.Read(vals[j][:]) // Random 32 bytes
+		}
+	}
+	return vals
+}
+
+// valueStats is a histogram of the slot values written in a phase.
+type valueStats struct {
+	Zero    int64 `json:"zero"`    // Zero values, deleting the slot from the storage trie
+	Small   int64 `json:"small"`   // Values with a single nonzero byte
+	Random  int64 `json:"random"`  // Any other value
+	Pruned  int64 `json:"pruned"`  // Zero values that deleted an existing slot
+	Updated int64 `json:"updated"` // Nonzero values overwriting an existing slot
+
+	RLP sizeStats `json:"rlpSizes"` // Encoded sizes of a sample of the nonzero values
+}
+
+// add records a slot write of val over the previous value prev.
+func (s *valueStats) add(prev, val common.Hash) {
+	if val != (common.Hash{}) && prev != (common.Hash{}) {
+		s.Updated++
+	}
+	switch nonzero := nonzeroBytes(val); {
+	case nonzero == 0:
+		s.Zero++
+		if prev != (common.Hash{}) {
+			s.Pruned++
+		}
+	case nonzero == 1:
+		s.Small++
+	default:
+		s.Random++
+	}
+	if val != (common.Hash{}) && (s.Small+s.Random)%rlpSampleEvery == 1 {
+		s.RLP.add(slotRLPSize(val))
+	}
+}
+
+// nonzeroBytes returns the number of nonzero bytes in h.
+func nonzeroBytes(h common.Hash) int {
+	var n int
+	for _, b := range h {
+		if b != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// newDeletePicker returns a function deciding whether the next slot write of
+// phase 2 deletes the slot instead, writing zero in place of the value drawn.
+// The decisions come from their own random source, so the slots picked and the
+// values drawn are the same for any ratio.
+func newDeletePicker(cfg *config) func() bool {
+	if cfg.modDelete == 0 {
+		return func() bool { return false }
+	}
+	r := cfg.rng.newRand("mod-delete", cfg.modSeed+1)
+	return func() bool { return r.Float64() < cfg.modDelete }
+}
+
+// newSlotPicker returns a function selecting the index of the slot to modify
+// next, following the configured slot access distribution.
+func newSlotPicker(cfg *config, r *rand.Rand) func() int {
+	if cfg.dist == distZipf {
+		zipf := rand.NewZipf(r, cfg.zipfS, cfg.zipfV, uint64(cfg.slots-1))
+		return func() int { return int(zipf.Uint64()) }
+	}
+	return func() int { return r.Intn(cfg.slots) }
+}
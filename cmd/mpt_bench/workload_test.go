@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestSlotCountAccountOnly(t *testing.T) {
+	for _, dist := range []string{slotDistUniform, slotDistNormal, slotDistPareto} {
+		cfg := defaultConfig()
+		cfg.slots, cfg.slotDist = 0, dist
+
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100; i++ {
+			if have := slotCount(cfg, r); have != 0 {
+				t.Fatalf("%s: have %d slots, want 0", dist, have)
+			}
+		}
+	}
+}
+
+func TestSlotBudget(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.totalSlots = 10
+
+	var (
+		budget = newSlotBudget(cfg)
+		have   []int
+	)
+	for _, n := range []int{4, 5, 3, 2} {
+		have = append(have, budget.take(n))
+	}
+	if want := []int{4, 5, 1, 0}; !slices.Equal(have, want) {
+		t.Fatalf("have slot counts %v, want %v", have, want)
+	}
+	if n := (*slotBudget)(nil).take(7); n != 7 {
+		t.Fatalf("uncapped budget took %d of 7 slots", n)
+	}
+}
+
+func TestAccountValuesEmpty(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.balanceDist, cfg.nonceDist, cfg.zeroBalance = balanceDistRandom, nonceDistZero, 1
+
+	v := newAccountValues(cfg)
+	for i := 0; i < 10; i++ {
+		if balance, nonce := v.draw(i, i%2 == 0); !balance.IsZero() || nonce != 0 {
+			t.Fatalf("account %d: have balance %v and nonce %d, want both zero", i, balance, nonce)
+		}
+	}
+	// Only the accounts without code are empty
+	if s := v.summary(); s.ZeroBalance != 10 || s.ZeroNonce != 10 || s.Empty != 5 || !slices.Equal(s.empties, []int{1, 3, 5, 7, 9}) {
+		t.Fatalf("have %+v, want 10 zero balances and nonces, 5 empty accounts", s)
+	}
+	if newAccountValues(defaultConfig()) != nil {
+		t.Fatal("default values drawn, want the fixed ones")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// zeroCheck contains the outcome of writing zeroes into absent slots.
+type zeroCheck struct {
+	Root     common.Hash   `json:"root"`
+	Slots    int           `json:"slots"`        // Absent slots zeroed
+	Accounts int           `json:"accounts"`     // Accounts the slots belong to
+	Nodes    int           `json:"storageNodes"` // Storage trie nodes of the accounts, the same before and after
+	Elapsed  time.Duration `json:"elapsedNs"`
+}
+
+// zeroSlot is a slot that no phase ever writes into.
+type zeroSlot struct {
+	addr common.Address
+	key  common.Hash
+}
+
+// absentSlots returns n slots of the created accounts, spread over them
+// round-robin, whose keys are outside of the slot keys of the workload.
+func (b *bench) absentSlots(n int) []zeroSlot {
+	slots := make([]zeroSlot, n)
+	for i := range slots {
+		idx := i % len(b.addrs)
+		slots[i] = zeroSlot{
+			addr: b.addrs[idx],
+			key:  crypto.Keccak256Hash([]byte(fmt.Sprintf("acc-%d-absent-%d", idx, i/len(b.addrs)))),
+		}
+	}
+	return slots
+}
+
+// assertZeroNoop writes zero values into n absent slots on top of the current
+// root and commits them, failing unless the root and the storage trie nodes of
+// the accounts stay the same. Deleting a slot that does not exist must not
+// touch the trie, any change is a bug of the delete-vs-noop logic and is
+// reported along with the first offending slot.
+func (b *bench) assertZeroNoop(n int) (*zeroCheck, error) {
+	var (
+		start = time.Now()
+		slots = b.absentSlots(n)
+		addrs = make(map[common.Address]struct{})
+	)
+	for _, slot := range slots {
+		addrs[slot.addr] = struct{}{}
+	}
+	before, err := b.countStorageNodes(b.root, addrs)
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := openState(b.sdb, b.root, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range slots {
+		if have := statedb.GetState(slot.addr, slot.key); have != (common.Hash{}) {
+			return nil, fmt.Errorf("slot %x of %x is not absent, holds %x", slot.key, slot.addr, have)
+		}
+		statedb.SetState(slot.addr, slot.key, common.Hash{})
+	}
+	// Find the offending slot before committing, the superseded root is gone
+	// afterwards in path mode
+	if root := statedb.IntermediateRoot(false); root != b.root {
+		return nil, b.zeroOffender(slots, root)
+	}
+	root, _, err := commitState(statedb, b.trieDB, b.root, 0, b.cfg.capLayers)
+	if err != nil {
+		return nil, err
+	}
+	if root != b.root {
+		return nil, fmt.Errorf("zeroing %d absent slots committed root %x, want the unchanged %x", n, root, b.root)
+	}
+	after, err := b.countStorageNodes(root, addrs)
+	if err != nil {
+		return nil, err
+	}
+	if after != before {
+		return nil, fmt.Errorf("zeroing %d absent slots changed the storage trie nodes of %d accounts from %d to %d", n, len(addrs), before, after)
+	}
+	// Continue from a clean state, dropping the cached no-op writes
+	if b.statedb, err = state.New(root, b.sdb); err != nil {
+		return nil, fmt.Errorf("failed to open state: %v", err)
+	}
+	return &zeroCheck{
+		Root:     root,
+		Slots:    n,
+		Accounts: len(addrs),
+		Nodes:    after,
+		Elapsed:  time.Since(start),
+	}, nil
+}
+
+// zeroOffender zeroes the absent slots one by one on top of the current root,
+// returning the failure of the first one changing it.
+func (b *bench) zeroOffender(slots []zeroSlot, root common.Hash) error {
+	for _, slot := range slots {
+		statedb, err := openState(b.sdb, b.root, false)
+		if err != nil {
+			return err
+		}
+		statedb.SetState(slot.addr, slot.key, common.Hash{})
+		if have := statedb.IntermediateRoot(false); have != b.root {
+			return fmt.Errorf("zeroing absent slot %x of %x changed root %x to %x", slot.key, slot.addr, b.root, have)
+		}
+	}
+	return fmt.Errorf("zeroing %d absent slots changed root %x to %x, but none of them on its own", len(slots), b.root, root)
+}
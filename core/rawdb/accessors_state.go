@@ -162,6 +162,36 @@ func ReadStateHistoryMeta(db ethdb.AncientReaderOp, id uint64) []byte {
 	return blob
 }
 
+// ReadStateHistorySize returns the total size of the state histories retained
+// in the freezer, summed up over its tables. The histories pruned from the tail
+// are excluded, even though their files may not be deleted yet.
+func ReadStateHistorySize(db ethdb.AncientReaderOp) (uint64, error) {
+	sizes, err := ReadStateHistoryTableSizes(db)
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	for _, size := range sizes {
+		total += size
+	}
+	return total, nil
+}
+
+// ReadStateHistoryTableSizes returns the size of the state histories retained
+// in every table of the freezer, keyed by the table name. Like the total size,
+// the histories pruned from the tail are excluded.
+func ReadStateHistoryTableSizes(db ethdb.AncientReaderOp) (map[string]uint64, error) {
+	sizes := make(map[string]uint64)
+	for _, kind := range []string{stateHistoryMeta, stateHistoryAccountIndex, stateHistoryStorageIndex, stateHistoryAccountData, stateHistoryStorageData} {
+		size, err := db.AncientSize(kind)
+		if err != nil {
+			return nil, err
+		}
+		sizes[kind] = size
+	}
+	return sizes, nil
+}
+
 // ReadStateHistoryMetaList retrieves a batch of meta objects with the specified
 // start position and count. Compute the position of state history in freezer by
 // minus one since the id of first state history starts from one(zero for initial
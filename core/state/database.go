@@ -163,6 +163,14 @@ type CachingDB struct {
 	codeSizeCache *lru.Cache[common.Hash, int]
 	pointCache    *utils.PointCache
 
+	// commitWorkers caps the storage tries hashed and committed concurrently
+	// by the states opened over the database, zero leaving it unlimited.
+	commitWorkers int
+
+	// sortedUpdates makes the states opened over the database update their
+	// storage tries in the order of the hashed slot keys.
+	sortedUpdates bool
+
 	// Transition-specific fields
 	TransitionStatePerRoot *lru.Cache[common.Hash, *overlay.TransitionState]
 }
@@ -186,6 +194,22 @@ func NewDatabaseForTesting() *CachingDB {
 	return NewDatabase(triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil), nil)
 }
 
+// SetCommitWorkers limits the number of storage tries the states opened after
+// the call hash and commit concurrently to n, zero removing the limit. It is
+// meant to be called once after creating the database and the limit has no
+// effect on verkle, which is updated sequentially anyway.
+func (db *CachingDB) SetCommitWorkers(n int) {
+	db.commitWorkers = n
+}
+
+// SetSortedStorageUpdates makes the states opened after the call insert the
+// changed slots into their storage tries in the order of the hashed slot keys,
+// instead of the random order of the maps tracking them. The resulting roots
+// are the same, only the cost of the trie updates differs.
+func (db *CachingDB) SetSortedStorageUpdates(sorted bool) {
+	db.sortedUpdates = sorted
+}
+
 // Reader returns a state reader associated with the specified state root.
 func (db *CachingDB) Reader(stateRoot common.Hash) (Reader, error) {
 	var readers []StateReader
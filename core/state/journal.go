@@ -173,6 +173,13 @@ func (j *journal) storageChange(addr common.Address, key, prev, origin common.Ha
 	})
 }
 
+func (j *journal) storageBatchChange(addr common.Address, changes []storageBatchItem) {
+	j.append(storageBatchChange{
+		account: addr,
+		changes: changes,
+	})
+}
+
 func (j *journal) transientStateChange(addr common.Address, key, prev common.Hash) {
 	j.append(transientStorageChange{
 		account:  addr,
@@ -258,11 +265,22 @@ type (
 		prevvalue common.Hash
 		origvalue common.Hash
 	}
+	storageBatchChange struct {
+		account common.Address
+		changes []storageBatchItem
+	}
 	codeChange struct {
 		account  common.Address
 		prevCode []byte
 	}
 
+	// storageBatchItem is a single slot change of a storageBatchChange.
+	storageBatchItem struct {
+		key       common.Hash
+		prevvalue common.Hash
+		origvalue common.Hash
+	}
+
 	// Changes to other state values.
 	refundChange struct {
 		prev uint64
@@ -412,6 +430,24 @@ func (ch storageChange) copy() journalEntry {
 	}
 }
 
+func (ch storageBatchChange) revert(s *StateDB) {
+	obj := s.getStateObject(ch.account)
+	for i := len(ch.changes) - 1; i >= 0; i-- {
+		obj.setState(ch.changes[i].key, ch.changes[i].prevvalue, ch.changes[i].origvalue)
+	}
+}
+
+func (ch storageBatchChange) dirtied() *common.Address {
+	return &ch.account
+}
+
+func (ch storageBatchChange) copy() journalEntry {
+	return storageBatchChange{
+		account: ch.account,
+		changes: slices.Clone(ch.changes),
+	}
+}
+
 func (ch transientStorageChange) revert(s *StateDB) {
 	s.setTransientState(ch.account, ch.key, ch.prevalue)
 }
@@ -51,3 +51,33 @@ var (
 	// snapStorageCleanCounter measures time spent on deleting storages
 	snapStorageCleanCounter = metrics.NewRegisteredCounter("state/snapshot/generation/duration/storage/clean", nil)
 )
+
+// BloomStats contains the outcomes of the bloom filter checks done by the diff
+// layers before descending into their maps.
+type BloomStats struct {
+	AccountTrueHits  int64 // Account lookups passing the filter and found in a diff layer
+	AccountFalseHits int64 // Account lookups passing the filter but missing from all diff layers
+	AccountMisses    int64 // Account lookups rejected by the filter, served by the disk layer
+	StorageTrueHits  int64 // Storage lookups passing the filter and found in a diff layer
+	StorageFalseHits int64 // Storage lookups passing the filter but missing from all diff layers
+	StorageMisses    int64 // Storage lookups rejected by the filter, served by the disk layer
+
+	// Error is the false positive rate estimated for the most recently built
+	// bloom filter, based on the number of items it holds.
+	Error float64
+}
+
+// ReadBloomStats returns the cumulative outcomes of the bloom filter checks of
+// all the snapshot trees in the process. The counters are kept regardless of
+// whether metrics collection is enabled.
+func ReadBloomStats() BloomStats {
+	return BloomStats{
+		AccountTrueHits:  snapshotBloomAccountTrueHitMeter.Snapshot().Count(),
+		AccountFalseHits: snapshotBloomAccountFalseHitMeter.Snapshot().Count(),
+		AccountMisses:    snapshotBloomAccountMissMeter.Snapshot().Count(),
+		StorageTrueHits:  snapshotBloomStorageTrueHitMeter.Snapshot().Count(),
+		StorageFalseHits: snapshotBloomStorageFalseHitMeter.Snapshot().Count(),
+		StorageMisses:    snapshotBloomStorageMissMeter.Snapshot().Count(),
+		Error:            snapshotBloomErrorGauge.Snapshot().Value(),
+	}
+}
@@ -105,9 +105,9 @@ func newObject(db *StateDB, address common.Address, acct *types.StateAccount) *s
 		origin:             origin,
 		data:               *acct,
 		originStorage:      make(Storage),
-		dirtyStorage:       make(Storage),
-		pendingStorage:     make(Storage),
-		uncommittedStorage: make(Storage),
+		dirtyStorage:       make(Storage, db.slotHint),
+		pendingStorage:     make(Storage, db.slotHint),
+		uncommittedStorage: make(Storage, db.slotHint),
 	}
 }
 
@@ -170,15 +170,50 @@ func (s *stateObject) getState(key common.Hash) (common.Hash, common.Hash) {
 	return origin, origin
 }
 
+// GetStates retrieves the values associated with multiple keys, in the order
+// of the keys. It returns the same values as calling GetState for every key,
+// but the dirty slots are served without resolving their committed value and
+// the slots read from the database are scheduled for prefetching all at once.
+func (s *stateObject) GetStates(keys []common.Hash) []common.Hash {
+	var (
+		values = make([]common.Hash, len(keys))
+		loaded []common.Hash
+	)
+	for i, key := range keys {
+		if value, dirty := s.dirtyStorage[key]; dirty {
+			values[i] = value
+			continue
+		}
+		value, read := s.committedState(key)
+		if read {
+			loaded = append(loaded, key)
+		}
+		values[i] = value
+	}
+	s.prefetchStorage(loaded)
+	return values
+}
+
 // GetCommittedState retrieves the value associated with the specific key
 // without any mutations caused in the current execution.
 func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
+	value, read := s.committedState(key)
+	if read {
+		s.prefetchStorage([]common.Hash{key})
+	}
+	return value
+}
+
+// committedState retrieves the value associated with the specific key without
+// any mutations caused in the current execution, reporting whether it was read
+// from the database rather than the caches.
+func (s *stateObject) committedState(key common.Hash) (common.Hash, bool) {
 	// If we have a pending write or clean cached, return that
 	if value, pending := s.pendingStorage[key]; pending {
-		return value
+		return value, false
 	}
 	if value, cached := s.originStorage[key]; cached {
-		return value
+		return value, false
 	}
 	// If the object was destructed in *this* block (and potentially resurrected),
 	// the storage has been cleared out, and we should *not* consult the previous
@@ -188,7 +223,7 @@ func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
 	//   2) we don't have new values, and can deliver empty response back
 	if _, destructed := s.db.stateObjectsDestruct[s.address]; destructed {
 		s.originStorage[key] = common.Hash{} // track the empty slot as origin value
-		return common.Hash{}
+		return common.Hash{}, false
 	}
 	s.db.StorageLoaded++
 
@@ -196,18 +231,23 @@ func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
 	value, err := s.db.reader.Storage(s.address, key)
 	if err != nil {
 		s.db.setError(err)
-		return common.Hash{}
+		return common.Hash{}, false
 	}
 	s.db.StorageReads += time.Since(start)
 
-	// Schedule the resolved storage slots for prefetching if it's enabled.
-	if s.db.prefetcher != nil && s.data.Root != types.EmptyRootHash {
-		if err = s.db.prefetcher.prefetch(s.addrHash, s.origin.Root, s.address, nil, []common.Hash{key}, true); err != nil {
-			log.Error("Failed to prefetch storage slot", "addr", s.address, "key", key, "err", err)
-		}
-	}
 	s.originStorage[key] = value
-	return value
+	return value, true
+}
+
+// prefetchStorage schedules the storage slots resolved from the database for
+// prefetching if it's enabled.
+func (s *stateObject) prefetchStorage(keys []common.Hash) {
+	if len(keys) == 0 || s.db.prefetcher == nil || s.data.Root == types.EmptyRootHash {
+		return
+	}
+	if err := s.db.prefetcher.prefetch(s.addrHash, s.origin.Root, s.address, nil, keys, true); err != nil {
+		log.Error("Failed to prefetch storage slots", "addr", s.address, "keys", len(keys), "err", err)
+	}
 }
 
 // SetState updates a value in account storage.
@@ -225,6 +265,23 @@ func (s *stateObject) SetState(key, value common.Hash) common.Hash {
 	return prev
 }
 
+// SetStates updates multiple storage slots, journaling all the changes that
+// are different from the current values in a single entry.
+func (s *stateObject) SetStates(kv map[common.Hash]common.Hash) {
+	var changes []storageBatchItem
+	for key, value := range kv {
+		prev, origin := s.getState(key)
+		if prev == value {
+			continue
+		}
+		changes = append(changes, storageBatchItem{key: key, prevvalue: prev, origvalue: origin})
+		s.setState(key, value, origin)
+	}
+	if len(changes) > 0 {
+		s.db.journal.storageBatchChange(s.address, changes)
+	}
+}
+
 // setState updates a value in account dirty storage. The dirtiness will be
 // removed if the value being set equals to the original value.
 func (s *stateObject) setState(key common.Hash, value common.Hash, origin common.Hash) {
@@ -327,21 +384,21 @@ func (s *stateObject) updateTrie() (Trie, error) {
 		deletions []common.Hash
 		used      = make([]common.Hash, 0, len(s.uncommittedStorage))
 	)
-	for key, origin := range s.uncommittedStorage {
+	update := func(key, origin common.Hash) error {
 		// Skip noop changes, persist actual changes
 		value, exist := s.pendingStorage[key]
 		if value == origin {
 			log.Error("Storage update was noop", "address", s.address, "slot", key)
-			continue
+			return nil
 		}
 		if !exist {
 			log.Error("Storage slot is not found in pending area", "address", s.address, "slot", key)
-			continue
+			return nil
 		}
 		if (value != common.Hash{}) {
 			if err := tr.UpdateStorage(s.address, key[:], common.TrimLeftZeroes(value[:])); err != nil {
 				s.db.setError(err)
-				return nil, err
+				return err
 			}
 			s.db.StorageUpdated.Add(1)
 		} else {
@@ -349,6 +406,20 @@ func (s *stateObject) updateTrie() (Trie, error) {
 		}
 		// Cache the items for preloading
 		used = append(used, key) // Copy needed for closure
+		return nil
+	}
+	if s.db.sortedStorageUpdates() {
+		for _, key := range sortedByHash(s.uncommittedStorage) {
+			if err := update(key, s.uncommittedStorage[key]); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for key, origin := range s.uncommittedStorage {
+			if err := update(key, origin); err != nil {
+				return nil, err
+			}
+		}
 	}
 	for _, key := range deletions {
 		if err := tr.DeleteStorage(s.address, key[:]); err != nil {
@@ -364,6 +435,26 @@ func (s *stateObject) updateTrie() (Trie, error) {
 	return tr, nil
 }
 
+// sortedByHash returns the slot keys of storage in the order of their hashes,
+// the order they are laid out in the storage trie.
+func sortedByHash(storage Storage) []common.Hash {
+	type hashedKey struct {
+		hash, key common.Hash
+	}
+	keys := make([]hashedKey, 0, len(storage))
+	for key := range storage {
+		keys = append(keys, hashedKey{crypto.Keccak256Hash(key[:]), key})
+	}
+	slices.SortFunc(keys, func(a, b hashedKey) int {
+		return a.hash.Cmp(b.hash)
+	})
+	sorted := make([]common.Hash, len(keys))
+	for i, k := range keys {
+		sorted[i] = k.key
+	}
+	return sorted
+}
+
 // updateRoot flushes all cached storage mutations to trie, recalculating the
 // new storage trie root.
 func (s *stateObject) updateRoot() {
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 func BenchmarkCutOriginal(b *testing.B) {
@@ -44,3 +45,59 @@ func BenchmarkCutCustomTrim(b *testing.B) {
 		common.TrimLeftZeroes(value[:])
 	}
 }
+
+// countingReader is a state reader counting the storage slots read through it.
+type countingReader struct {
+	Reader
+	slots int
+}
+
+func (r *countingReader) Storage(addr common.Address, slot common.Hash) (common.Hash, error) {
+	r.slots++
+	return r.Reader.Storage(addr, slot)
+}
+
+// Tests that batched storage reads serve the dirty slots without reading their
+// committed value from the database.
+func TestGetStatesDirtySlots(t *testing.T) {
+	var (
+		db    = NewDatabaseForTesting()
+		addr  = common.HexToAddress("0xaffeaffeaffeaffeaffeaffeaffeaffeaffeaffe")
+		dirty = common.HexToHash("0x01")
+		clean = common.HexToHash("0x02")
+	)
+	state, _ := New(types.EmptyRootHash, db)
+	state.SetState(addr, dirty, common.HexToHash("0x11"))
+	state.SetState(addr, clean, common.HexToHash("0x22"))
+	root, err := state.Commit(0, false, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	reader, err := db.Reader(root)
+	if err != nil {
+		t.Fatalf("failed to open reader: %v", err)
+	}
+	counter := &countingReader{Reader: reader}
+	state, err = NewWithReader(root, db, counter)
+	if err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	// Mark the slot dirty without resolving its committed value first
+	obj := state.getStateObject(addr)
+	obj.dirtyStorage[dirty] = common.HexToHash("0x33")
+
+	values := state.GetStorageBatch(addr, []common.Hash{dirty})
+	if values[0] != common.HexToHash("0x33") {
+		t.Fatalf("dirty slot value mismatch: have %x, want %x", values[0], common.HexToHash("0x33"))
+	}
+	if counter.slots != 0 {
+		t.Fatalf("dirty slot read %d times from the database", counter.slots)
+	}
+	values = state.GetStorageBatch(addr, []common.Hash{dirty, clean})
+	if values[0] != common.HexToHash("0x33") || values[1] != common.HexToHash("0x22") {
+		t.Fatalf("slot values mismatch: have %x", values)
+	}
+	if counter.slots != 1 {
+		t.Fatalf("clean slot read %d times from the database, want once", counter.slots)
+	}
+}
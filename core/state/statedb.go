@@ -104,6 +104,11 @@ type StateDB struct {
 	// perspective. This map is populated at the transaction boundaries.
 	mutations map[common.Address]*mutation
 
+	// Number of storage slots expected to be written per account, pre-sizing
+	// the storage maps of the state objects. Zero leaves them growing on
+	// demand.
+	slotHint int
+
 	// DB error.
 	// State objects are used by the consensus core and VM which are
 	// unable to deal with database-level errors. Any error that occurs
@@ -241,6 +246,39 @@ func (s *StateDB) Error() error {
 	return s.dbErr
 }
 
+// SetSizeHint pre-sizes the internal maps of the state for a workload updating
+// about accounts accounts with slots storage slots each, sparing the repeated
+// growth of the maps when populating large states. The account maps are only
+// resized while still empty, the storage maps of the accounts loaded after the
+// call are sized upfront.
+func (s *StateDB) SetSizeHint(accounts, slots int) {
+	if len(s.stateObjects) == 0 {
+		s.stateObjects = make(map[common.Address]*stateObject, accounts)
+	}
+	if len(s.mutations) == 0 {
+		s.mutations = make(map[common.Address]*mutation, accounts)
+	}
+	s.slotHint = slots
+}
+
+// commitWorkers returns the number of storage tries to hash and commit
+// concurrently, zero meaning no limit.
+func (s *StateDB) commitWorkers() int {
+	if db, ok := s.db.(*CachingDB); ok {
+		return db.commitWorkers
+	}
+	return 0
+}
+
+// sortedStorageUpdates reports whether the storage tries are updated in the
+// order of the hashed slot keys.
+func (s *StateDB) sortedStorageUpdates() bool {
+	if db, ok := s.db.(*CachingDB); ok {
+		return db.sortedUpdates
+	}
+	return false
+}
+
 func (s *StateDB) AddLog(log *types.Log) {
 	s.journal.logChange(s.thash)
 
@@ -388,6 +426,16 @@ func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
 	return common.Hash{}
 }
 
+// GetStorageBatch retrieves the values associated with multiple keys of the
+// specified account, in the order of the keys. It is equivalent to calling
+// GetState for every key, but looks up the state object only once.
+func (s *StateDB) GetStorageBatch(addr common.Address, keys []common.Hash) []common.Hash {
+	if stateObject := s.getStateObject(addr); stateObject != nil {
+		return stateObject.GetStates(keys)
+	}
+	return make([]common.Hash, len(keys))
+}
+
 // GetCommittedState retrieves the value associated with the specific key
 // without any mutations caused in the current execution.
 func (s *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
@@ -480,6 +528,16 @@ func (s *StateDB) SetState(addr common.Address, key, value common.Hash) common.H
 	return common.Hash{}
 }
 
+// SetStorageBatch sets multiple storage slots of the specified account at once.
+// It is equivalent to calling SetState for every slot, but looks up the state
+// object only once and tracks all the changes in a single journal entry that is
+// reverted as a whole.
+func (s *StateDB) SetStorageBatch(addr common.Address, kv map[common.Hash]common.Hash) {
+	if stateObject := s.getOrNewStateObject(addr); stateObject != nil {
+		stateObject.SetStates(kv)
+	}
+}
+
 // SetStorage replaces the entire storage for the specified account with given
 // storage. This function should only be used for debugging and the mutations
 // must be discarded afterwards.
@@ -689,6 +747,7 @@ func (s *StateDB) Copy() *StateDB {
 		logs:                 make(map[common.Hash][]*types.Log, len(s.logs)),
 		logSize:              s.logSize,
 		preimages:            maps.Clone(s.preimages),
+		slotHint:             s.slotHint,
 
 		// Do we need to copy the access list and transient storage?
 		// In practice: No. At the start of a transaction, these two lists are empty.
@@ -835,6 +894,8 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 		// need concurrency support within the trie itself. That's a TODO for a
 		// later time.
 		workers.SetLimit(1)
+	} else if n := s.commitWorkers(); n > 0 {
+		workers.SetLimit(n)
 	}
 	for addr, op := range s.mutations {
 		if op.applied || op.isDelete() {
@@ -1233,6 +1294,9 @@ func (s *StateDB) commit(deleteEmptyObjects bool, noStorageWiping bool, blockNum
 		root    common.Hash
 		workers errgroup.Group
 	)
+	if n := s.commitWorkers(); n > 0 {
+		workers.SetLimit(n + 1) // the account trie is committed on top
+	}
 	// Schedule the account trie first since that will be the biggest, so give
 	// it the most time to crunch.
 	//
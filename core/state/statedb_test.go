@@ -1368,3 +1368,181 @@ func TestStorageDirtiness(t *testing.T) {
 	state.RevertToSnapshot(snap)
 	checkDirty(common.Hash{0x1}, common.Hash{0x1}, true)
 }
+
+// TestSetStorageBatch tests that setting storage slots in batches results in
+// the same state as setting them one by one, and that the batches are reverted
+// to the same values.
+func TestSetStorageBatch(t *testing.T) {
+	var (
+		addr  = common.HexToAddress("0x1")
+		slots = make(map[common.Hash]common.Hash)
+		mods  = make(map[common.Hash]common.Hash)
+	)
+	for i := byte(1); i <= 16; i++ {
+		slots[common.Hash{i}] = common.Hash{i}
+		// Modify some slots, keep some and clear some
+		switch i % 3 {
+		case 0:
+			mods[common.Hash{i}] = common.Hash{i, i}
+		case 1:
+			mods[common.Hash{i}] = common.Hash{i}
+		case 2:
+			mods[common.Hash{i}] = common.Hash{}
+		}
+	}
+	mods[common.Hash{0xff}] = common.Hash{0xff}
+
+	single, _ := New(types.EmptyRootHash, NewDatabaseForTesting())
+	batch, _ := New(types.EmptyRootHash, NewDatabaseForTesting())
+	for key, value := range slots {
+		single.SetState(addr, key, value)
+	}
+	batch.SetStorageBatch(addr, slots)
+	if want, have := single.IntermediateRoot(false), batch.IntermediateRoot(false); want != have {
+		t.Fatalf("root mismatch after creation: want %x, have %x", want, have)
+	}
+	// Apply the modifications and revert them, expecting the created slots back
+	snapSingle, snapBatch := single.Snapshot(), batch.Snapshot()
+	for key, value := range mods {
+		single.SetState(addr, key, value)
+	}
+	batch.SetStorageBatch(addr, mods)
+	for key := range mods {
+		if want, have := single.GetState(addr, key), batch.GetState(addr, key); want != have {
+			t.Fatalf("slot %x mismatch after modification: want %x, have %x", key, want, have)
+		}
+	}
+	single.RevertToSnapshot(snapSingle)
+	batch.RevertToSnapshot(snapBatch)
+	for key := range mods {
+		if want, have := single.GetState(addr, key), batch.GetState(addr, key); want != have {
+			t.Fatalf("slot %x mismatch after revert: want %x, have %x", key, want, have)
+		}
+		if want, have := slots[key], batch.GetState(addr, key); want != have {
+			t.Fatalf("slot %x not reverted: want %x, have %x", key, want, have)
+		}
+	}
+	if want, have := single.IntermediateRoot(false), batch.IntermediateRoot(false); want != have {
+		t.Fatalf("root mismatch after revert: want %x, have %x", want, have)
+	}
+}
+
+// TestGetStorageBatch tests that reading storage slots in batches returns the
+// same values as reading them one by one, from the database, the caches and
+// the dirty storage alike.
+func TestGetStorageBatch(t *testing.T) {
+	var (
+		addr = common.HexToAddress("0x1")
+		db   = NewDatabaseForTesting()
+		keys []common.Hash
+	)
+	state, _ := New(types.EmptyRootHash, db)
+	for i := byte(1); i <= 16; i++ {
+		state.SetState(addr, common.Hash{i}, common.Hash{i})
+		keys = append(keys, common.Hash{i})
+	}
+	keys = append(keys, common.Hash{0xff}) // Never written
+	root, _ := state.Commit(0, false, false)
+
+	single, _ := New(root, db)
+	batch, _ := New(root, db)
+	for _, s := range []*StateDB{single, batch} {
+		s.GetState(addr, common.Hash{1})                    // Cached
+		s.SetState(addr, common.Hash{2}, common.Hash{2, 2}) // Dirty
+		s.SetState(addr, common.Hash{3}, common.Hash{})     // Cleared
+	}
+	values := batch.GetStorageBatch(addr, keys)
+	if len(values) != len(keys) {
+		t.Fatalf("value count mismatch: want %d, have %d", len(keys), len(values))
+	}
+	for i, key := range keys {
+		if want := single.GetState(addr, key); values[i] != want {
+			t.Fatalf("slot %x mismatch: want %x, have %x", key, want, values[i])
+		}
+	}
+	for i, value := range batch.GetStorageBatch(common.HexToAddress("0x2"), keys) {
+		if value != (common.Hash{}) {
+			t.Fatalf("slot %x of missing account: want zero, have %x", keys[i], value)
+		}
+	}
+}
+
+func TestCommitWorkers(t *testing.T) {
+	commit := func(workers int) common.Hash {
+		db := NewDatabaseForTesting()
+		db.SetCommitWorkers(workers)
+
+		state, _ := New(types.EmptyRootHash, db)
+		for i := byte(1); i <= 16; i++ {
+			addr := common.Address{i}
+			state.SetBalance(addr, uint256.NewInt(uint64(i)), tracing.BalanceChangeUnspecified)
+			for j := byte(1); j <= i; j++ {
+				state.SetState(addr, common.Hash{j}, common.Hash{i, j})
+			}
+		}
+		root, err := state.Commit(0, false, false)
+		if err != nil {
+			t.Fatalf("failed to commit with %d workers: %v", workers, err)
+		}
+		return root
+	}
+	want := commit(0)
+	for _, workers := range []int{1, 2, 4} {
+		if have := commit(workers); have != want {
+			t.Fatalf("root mismatch with %d workers: want %x, have %x", workers, want, have)
+		}
+	}
+}
+
+// TestSortedStorageUpdates tests that updating the storage tries in hashed key
+// order commits the same roots as the unordered updates, deletions included.
+func TestSortedStorageUpdates(t *testing.T) {
+	commit := func(sorted bool) common.Hash {
+		db := NewDatabaseForTesting()
+		db.SetSortedStorageUpdates(sorted)
+
+		state, _ := New(types.EmptyRootHash, db)
+		for i := byte(1); i <= 16; i++ {
+			addr := common.Address{i}
+			state.SetBalance(addr, uint256.NewInt(uint64(i)), tracing.BalanceChangeUnspecified)
+			for j := byte(1); j <= 32; j++ {
+				state.SetState(addr, common.Hash{j}, common.Hash{i, j})
+			}
+		}
+		root, err := state.Commit(0, false, false)
+		if err != nil {
+			t.Fatalf("failed to commit (sorted: %v): %v", sorted, err)
+		}
+		state, _ = New(root, db)
+		for i := byte(1); i <= 16; i++ {
+			for j := byte(1); j <= 32; j += 2 {
+				state.SetState(common.Address{i}, common.Hash{j}, common.Hash{})
+			}
+		}
+		if root, err = state.Commit(1, false, false); err != nil {
+			t.Fatalf("failed to commit the deletions (sorted: %v): %v", sorted, err)
+		}
+		return root
+	}
+	if want, have := commit(false), commit(true); want != have {
+		t.Fatalf("root mismatch: want %x, have %x", want, have)
+	}
+}
+
+func TestSetSizeHint(t *testing.T) {
+	populate := func(hint bool) common.Hash {
+		state, _ := New(types.EmptyRootHash, NewDatabaseForTesting())
+		if hint {
+			state.SetSizeHint(16, 8)
+		}
+		for i := byte(1); i <= 16; i++ {
+			for j := byte(1); j <= 8; j++ {
+				state.SetState(common.Address{i}, common.Hash{j}, common.Hash{i, j})
+			}
+		}
+		return state.IntermediateRoot(false)
+	}
+	if want, have := populate(false), populate(true); want != have {
+		t.Fatalf("root mismatch with size hint: want %x, have %x", want, have)
+	}
+}
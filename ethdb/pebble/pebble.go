@@ -28,7 +28,6 @@ import (
 
 	"github.com/cockroachdb/pebble"
 	"github.com/cockroachdb/pebble/bloom"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -477,6 +476,76 @@ func (d *Database) Stat() (string, error) {
 	return d.db.Metrics().String(), nil
 }
 
+// WriteStats contains the cumulative number of bytes physically written by
+// Pebble since the database was opened, split by their origin.
+type WriteStats struct {
+	WAL       uint64 // Bytes appended to the write-ahead log
+	Flushed   uint64 // Bytes of sstables written by memtable flushes
+	Compacted uint64 // Bytes of sstables written by compactions
+}
+
+// Total returns the total number of bytes written.
+func (s WriteStats) Total() uint64 {
+	return s.WAL + s.Flushed + s.Compacted
+}
+
+// WriteStats returns the number of bytes physically written by the database
+// since it was opened, e.g. to compute the write amplification.
+func (d *Database) WriteStats() WriteStats {
+	var (
+		stats   = d.db.Metrics()
+		written = WriteStats{WAL: stats.WAL.BytesWritten}
+	)
+	for _, level := range stats.Levels {
+		written.Flushed += level.BytesFlushed
+		written.Compacted += level.BytesCompacted
+	}
+	return written
+}
+
+// LevelStats contains the sstables of a single level of the LSM tree.
+type LevelStats struct {
+	Tables int64   // Number of sstables in the level
+	Size   int64   // Bytes of the sstables in the level
+	Score  float64 // Compaction score of the level, compacted once above 1
+}
+
+// LSMStats describes the shape of the LSM tree and the compaction work the
+// database is behind on.
+type LSMStats struct {
+	Levels          []LevelStats
+	CompactionDebt  uint64 // Estimated bytes to compact until no level needs it
+	Compactions     int64  // Number of compactions in progress
+	CompactingBytes int64  // Bytes of the sstables being compacted
+	MemTables       int64  // Number of memtables, including the mutable one
+	MemTableSize    uint64 // Bytes allocated by the memtables
+
+	WriteStalls    int64         // Writes stalled by compactions since the database was opened
+	WriteStallTime time.Duration // Total time the writes were stalled for
+}
+
+// LSMStats returns the current shape of the LSM tree, e.g. to tell whether the
+// disk usage of the database is inflated by data pending compaction.
+func (d *Database) LSMStats() LSMStats {
+	var (
+		metrics = d.db.Metrics()
+		stats   = LSMStats{
+			Levels:          make([]LevelStats, len(metrics.Levels)),
+			CompactionDebt:  metrics.Compact.EstimatedDebt,
+			Compactions:     metrics.Compact.NumInProgress,
+			CompactingBytes: metrics.Compact.InProgressBytes,
+			MemTables:       metrics.MemTable.Count,
+			MemTableSize:    metrics.MemTable.Size,
+			WriteStalls:     d.writeDelayCount.Load(),
+			WriteStallTime:  time.Duration(d.writeDelayTime.Load()),
+		}
+	)
+	for i, level := range metrics.Levels {
+		stats.Levels[i] = LevelStats{Tables: level.NumFiles, Size: level.Size, Score: level.Score}
+	}
+	return stats
+}
+
 // Compact flattens the underlying data store for the given key range. In essence,
 // deleted and overwritten versions are discarded, and the data is rearranged to
 // reduce the cost of operations needed to access them.
@@ -504,6 +573,18 @@ func (d *Database) Path() string {
 	return d.fn
 }
 
+// SetSyncWrites switches the database between the asynchronous write mode of
+// the default and the synchronous one, where every write and batch commit waits
+// for the write-ahead log to reach the disk. It must not be called concurrently
+// with any write.
+func (d *Database) SetSyncWrites(sync bool) {
+	if sync {
+		d.writeOptions = pebble.Sync
+	} else {
+		d.writeOptions = pebble.NoSync
+	}
+}
+
 // SyncKeyValue flushes all pending writes in the write-ahead-log to disk,
 // ensuring data durability up to that point.
 func (d *Database) SyncKeyValue() error {
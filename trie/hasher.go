@@ -32,6 +32,7 @@ type hasher struct {
 	tmp      []byte
 	encbuf   rlp.EncoderBuffer
 	parallel bool // Whether to use parallel threads when hashing
+	noCache  bool // Whether to rehash the nodes with a cached hash too
 }
 
 // hasherPool holds pureHashers
@@ -48,6 +49,7 @@ var hasherPool = sync.Pool{
 func newHasher(parallel bool) *hasher {
 	h := hasherPool.Get().(*hasher)
 	h.parallel = parallel
+	h.noCache = false
 	return h
 }
 
@@ -58,7 +60,7 @@ func returnHasherToPool(h *hasher) {
 // hash collapses a node down into a hash node.
 func (h *hasher) hash(n node, force bool) []byte {
 	// Return the cached hash if it's available
-	if hash, _ := n.cache(); hash != nil {
+	if hash, _ := n.cache(); hash != nil && !h.noCache {
 		return hash
 	}
 	// Trie not processed yet, walk the children
@@ -148,9 +150,10 @@ func (h *hasher) encodeFullNode(n *fullNode) []byte {
 			go func(i int) {
 				defer wg.Done()
 
-				h := newHasher(false)
-				fn.Children[i] = h.hash(n.Children[i], false)
-				returnHasherToPool(h)
+				hasher := newHasher(false)
+				hasher.noCache = h.noCache
+				fn.Children[i] = hasher.hash(n.Children[i], false)
+				returnHasherToPool(hasher)
 			}(i)
 		}
 		wg.Wait()
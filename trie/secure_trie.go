@@ -20,11 +20,41 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie/trienode"
 	"github.com/ethereum/go-ethereum/triedb/database"
 )
 
+var (
+	// secKeyHitMeter and secKeyMissMeter count the preimage lookups served by
+	// the key cache of a StateTrie and the ones passed to the preimage store.
+	secKeyHitMeter  = metrics.NewRegisteredMeter("trie/seckey/hit", nil)
+	secKeyMissMeter = metrics.NewRegisteredMeter("trie/seckey/miss", nil)
+
+	// secKeyHandoverMeter counts the preimages handed over to the preimage
+	// store before the commit, because the key cache filled up.
+	secKeyHandoverMeter = metrics.NewRegisteredMeter("trie/seckey/handover", nil)
+)
+
+// SecKeyCacheStats contains the cumulative activity of the key caches of all
+// the StateTries in the process.
+type SecKeyCacheStats struct {
+	Hits      int64 // Preimage lookups served by the key cache
+	Misses    int64 // Preimage lookups passed to the preimage store
+	Handovers int64 // Preimages handed over early by a full key cache
+}
+
+// ReadSecKeyCacheStats returns the cumulative activity of the key caches. The
+// counters are kept regardless of whether metrics collection is enabled.
+func ReadSecKeyCacheStats() SecKeyCacheStats {
+	return SecKeyCacheStats{
+		Hits:      secKeyHitMeter.Snapshot().Count(),
+		Misses:    secKeyMissMeter.Snapshot().Count(),
+		Handovers: secKeyHandoverMeter.Snapshot().Count(),
+	}
+}
+
 // preimageStore wraps the methods of a backing store for reading and writing
 // trie node preimages.
 type preimageStore interface {
@@ -38,6 +68,24 @@ type preimageStore interface {
 	PreimageEnabled() bool
 }
 
+// secKeyCacheLimiter is implemented by the preimage stores bounding the number
+// of hashed key preimages a StateTrie caches before handing them over.
+type secKeyCacheLimiter interface {
+	// SecKeyCacheLimit returns the maximum number of preimages cached by a
+	// trie, 0 if unbounded.
+	SecKeyCacheLimit() int
+}
+
+// rawKeyer is implemented by the node databases whose state tries insert the
+// keys unhashed, the legacy layout of the non-secure trie. Only the methods
+// taking an address or a slot key honour it. GetAccountByHash, GetKey and the
+// snapshots, flat states and preimages kept alongside still assume hashed
+// keys, so raw keys are only meant for benchmarking the trie layout.
+type rawKeyer interface {
+	// RawKeys returns whether the keys are inserted without hashing.
+	RawKeys() bool
+}
+
 // SecureTrie is the old name of StateTrie.
 // Deprecated: use StateTrie.
 type SecureTrie = StateTrie
@@ -68,6 +116,8 @@ type StateTrie struct {
 	db          database.NodeDatabase
 	preimages   preimageStore
 	secKeyCache map[common.Hash][]byte
+	secKeyLimit int  // Cached preimages handed over early, 0 to wait for the commit
+	raw         bool // Keys inserted unhashed, for comparing against the legacy layout
 }
 
 // NewStateTrie creates a trie with an existing root node from a backing database.
@@ -89,9 +139,17 @@ func NewStateTrie(id *ID, db database.NodeDatabase) (*StateTrie, error) {
 		secKeyCache: make(map[common.Hash][]byte),
 	}
 
+	// Unhashed keys are their own preimages, nothing to record
+	if keyer, ok := db.(rawKeyer); ok && keyer.RawKeys() {
+		tr.raw = true
+		return tr, nil
+	}
 	// link the preimage store if it's supported
 	if preimages, ok := db.(preimageStore); ok && preimages.PreimageEnabled() {
 		tr.preimages = preimages
+		if limiter, ok := db.(secKeyCacheLimiter); ok {
+			tr.secKeyLimit = limiter.SecKeyCacheLimit()
+		}
 	}
 	return tr, nil
 }
@@ -102,14 +160,14 @@ func NewStateTrie(id *ID, db database.NodeDatabase) (*StateTrie, error) {
 // This function will omit any encountered error but just
 // print out an error message.
 func (t *StateTrie) MustGet(key []byte) []byte {
-	return t.trie.MustGet(crypto.Keccak256(key))
+	return t.trie.MustGet(t.hashKey(key))
 }
 
 // GetAccount attempts to retrieve an account with provided account address.
 // If the specified account is not in the trie, nil will be returned.
 // If a trie node is not found in the database, a MissingNodeError is returned.
 func (t *StateTrie) GetAccount(address common.Address) (*types.StateAccount, error) {
-	res, err := t.trie.Get(crypto.Keccak256(address.Bytes()))
+	res, err := t.trie.Get(t.hashKey(address.Bytes()))
 	if res == nil || err != nil {
 		return nil, err
 	}
@@ -136,7 +194,7 @@ func (t *StateTrie) GetAccountByHash(addrHash common.Hash) (*types.StateAccount,
 func (t *StateTrie) PrefetchAccount(addresses []common.Address) error {
 	var keys [][]byte
 	for _, addr := range addresses {
-		keys = append(keys, crypto.Keccak256(addr.Bytes()))
+		keys = append(keys, t.hashKey(addr.Bytes()))
 	}
 	return t.trie.Prefetch(keys)
 }
@@ -146,7 +204,7 @@ func (t *StateTrie) PrefetchAccount(addresses []common.Address) error {
 // If the specified storage slot is not in the trie, nil will be returned.
 // If a trie node is not found in the database, a MissingNodeError is returned.
 func (t *StateTrie) GetStorage(_ common.Address, key []byte) ([]byte, error) {
-	enc, err := t.trie.Get(crypto.Keccak256(key))
+	enc, err := t.trie.Get(t.hashKey(key))
 	if err != nil || len(enc) == 0 {
 		return nil, err
 	}
@@ -159,7 +217,7 @@ func (t *StateTrie) GetStorage(_ common.Address, key []byte) ([]byte, error) {
 func (t *StateTrie) PrefetchStorage(_ common.Address, keys [][]byte) error {
 	var keylist [][]byte
 	for _, key := range keys {
-		keylist = append(keylist, crypto.Keccak256(key))
+		keylist = append(keylist, t.hashKey(key))
 	}
 	return t.trie.Prefetch(keylist)
 }
@@ -182,10 +240,10 @@ func (t *StateTrie) GetNode(path []byte) ([]byte, int, error) {
 // This function will omit any encountered error but just print out an
 // error message.
 func (t *StateTrie) MustUpdate(key, value []byte) {
-	hk := crypto.Keccak256(key)
+	hk := t.hashKey(key)
 	t.trie.MustUpdate(hk, value)
 	if t.preimages != nil {
-		t.secKeyCache[common.Hash(hk)] = common.CopyBytes(key)
+		t.cacheKey(common.Hash(hk), common.CopyBytes(key))
 	}
 }
 
@@ -198,21 +256,21 @@ func (t *StateTrie) MustUpdate(key, value []byte) {
 //
 // If a node is not found in the database, a MissingNodeError is returned.
 func (t *StateTrie) UpdateStorage(_ common.Address, key, value []byte) error {
-	hk := crypto.Keccak256(key)
+	hk := t.hashKey(key)
 	v, _ := rlp.EncodeToBytes(value)
 	err := t.trie.Update(hk, v)
 	if err != nil {
 		return err
 	}
 	if t.preimages != nil {
-		t.secKeyCache[common.Hash(hk)] = common.CopyBytes(key)
+		t.cacheKey(common.Hash(hk), common.CopyBytes(key))
 	}
 	return nil
 }
 
 // UpdateAccount will abstract the write of an account to the secure trie.
 func (t *StateTrie) UpdateAccount(address common.Address, acc *types.StateAccount, _ int) error {
-	hk := crypto.Keccak256(address.Bytes())
+	hk := t.hashKey(address.Bytes())
 	data, err := rlp.EncodeToBytes(acc)
 	if err != nil {
 		return err
@@ -221,7 +279,7 @@ func (t *StateTrie) UpdateAccount(address common.Address, acc *types.StateAccoun
 		return err
 	}
 	if t.preimages != nil {
-		t.secKeyCache[common.Hash(hk)] = address.Bytes()
+		t.cacheKey(common.Hash(hk), address.Bytes())
 	}
 	return nil
 }
@@ -233,7 +291,7 @@ func (t *StateTrie) UpdateContractCode(_ common.Address, _ common.Hash, _ []byte
 // MustDelete removes any existing value for key from the trie. This function
 // will omit any encountered error but just print out an error message.
 func (t *StateTrie) MustDelete(key []byte) {
-	hk := crypto.Keccak256(key)
+	hk := t.hashKey(key)
 	if t.preimages != nil {
 		delete(t.secKeyCache, common.Hash(hk))
 	}
@@ -244,7 +302,7 @@ func (t *StateTrie) MustDelete(key []byte) {
 // If the specified trie node is not in the trie, nothing will be changed.
 // If a node is not found in the database, a MissingNodeError is returned.
 func (t *StateTrie) DeleteStorage(_ common.Address, key []byte) error {
-	hk := crypto.Keccak256(key)
+	hk := t.hashKey(key)
 	if t.preimages != nil {
 		delete(t.secKeyCache, common.Hash(hk))
 	}
@@ -253,7 +311,7 @@ func (t *StateTrie) DeleteStorage(_ common.Address, key []byte) error {
 
 // DeleteAccount abstracts an account deletion from the trie.
 func (t *StateTrie) DeleteAccount(address common.Address) error {
-	hk := crypto.Keccak256(address.Bytes())
+	hk := t.hashKey(address.Bytes())
 	if t.preimages != nil {
 		delete(t.secKeyCache, common.Hash(hk))
 	}
@@ -267,11 +325,34 @@ func (t *StateTrie) GetKey(shaKey []byte) []byte {
 		return nil
 	}
 	if key, ok := t.secKeyCache[common.BytesToHash(shaKey)]; ok {
+		secKeyHitMeter.Mark(1)
 		return key
 	}
+	secKeyMissMeter.Mark(1)
 	return t.preimages.Preimage(common.BytesToHash(shaKey))
 }
 
+// hashKey returns the key the trie stores the value of key under, keccak256
+// of it unless the keys are inserted raw.
+func (t *StateTrie) hashKey(key []byte) []byte {
+	if t.raw {
+		return common.CopyBytes(key)
+	}
+	return crypto.Keccak256(key)
+}
+
+// cacheKey caches the preimage of a hashed key until the trie is committed. If
+// the cache is bounded and full, the preimages cached so far are handed over to
+// the preimage store right away.
+func (t *StateTrie) cacheKey(hk common.Hash, key []byte) {
+	t.secKeyCache[hk] = key
+	if t.secKeyLimit > 0 && len(t.secKeyCache) >= t.secKeyLimit {
+		secKeyHandoverMeter.Mark(int64(len(t.secKeyCache)))
+		t.preimages.InsertPreimage(t.secKeyCache)
+		t.secKeyCache = make(map[common.Hash][]byte)
+	}
+}
+
 // Witness returns a set containing all trie nodes that have been accessed.
 func (t *StateTrie) Witness() map[string][]byte {
 	return t.trie.Witness()
@@ -308,6 +389,8 @@ func (t *StateTrie) Copy() *StateTrie {
 		trie:        *t.trie.Copy(),
 		db:          t.db,
 		secKeyCache: make(map[common.Hash][]byte),
+		secKeyLimit: t.secKeyLimit,
+		raw:         t.raw,
 		preimages:   t.preimages,
 	}
 }
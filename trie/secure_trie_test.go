@@ -111,6 +111,41 @@ func TestSecureGetKey(t *testing.T) {
 	}
 }
 
+// rawKeyDb is a test database inserting the state trie keys unhashed.
+type rawKeyDb struct {
+	*testDb
+}
+
+func (db rawKeyDb) RawKeys() bool { return true }
+
+// Tests that the state tries opened on a database requesting raw keys store
+// the values under the keys themselves, building the trie of the plain one.
+func TestStateTrieRawKeys(t *testing.T) {
+	db := rawKeyDb{newTestDatabase(rawdb.NewMemoryDatabase(), rawdb.HashScheme)}
+	trie, _ := NewStateTrie(TrieID(types.EmptyRootHash), db)
+	plain := NewEmpty(db)
+
+	for i := byte(0); i < 64; i++ {
+		key, val := common.LeftPadBytes([]byte{i % 4, i}, 32), []byte{i}
+		trie.MustUpdate(key, val)
+		plain.MustUpdate(key, val)
+	}
+	key := common.LeftPadBytes([]byte{1, 5}, 32)
+	trie.MustDelete(key)
+	plain.MustDelete(key)
+
+	if have, want := trie.Hash(), plain.Hash(); have != want {
+		t.Fatalf("root mismatch: have %x, want %x", have, want)
+	}
+	key = common.LeftPadBytes([]byte{2, 6}, 32)
+	if v := trie.MustGet(key); !bytes.Equal(v, []byte{6}) {
+		t.Fatalf("wrong value: have %x, want 06", v)
+	}
+	if v := trie.GetKey(crypto.Keccak256(key)); v != nil {
+		t.Fatalf("raw keys recorded a preimage %x", v)
+	}
+}
+
 func TestStateTrieConcurrency(t *testing.T) {
 	// Create an initial trie and copy if for concurrent access
 	_, trie, _ := makeTestStateTrie()
@@ -57,6 +57,10 @@ type Trie struct {
 	// reader is the handler trie can retrieve nodes from.
 	reader *Reader
 
+	// noHashCache makes every hashing recompute the hashes of all the nodes
+	// held in memory, not just the ones changed since the last hashing.
+	noHashCache bool
+
 	// Various tracers for capturing the modifications to trie
 	opTracer       *opTracer
 	prevalueTracer *PrevalueTracer
@@ -76,11 +80,19 @@ func (t *Trie) Copy() *Trie {
 		unhashed:       t.unhashed,
 		uncommitted:    t.uncommitted,
 		reader:         t.reader,
+		noHashCache:    t.noHashCache,
 		opTracer:       t.opTracer.copy(),
 		prevalueTracer: t.prevalueTracer.Copy(),
 	}
 }
 
+// hashCacheDisabler is implemented by the node databases whose tries ignore
+// the cached node hashes, rehashing every node in memory on each hashing.
+type hashCacheDisabler interface {
+	// HashCacheDisabled returns whether the cached node hashes are bypassed.
+	HashCacheDisabled() bool
+}
+
 // New creates the trie instance with provided trie id and the read-only
 // database. The state specified by trie id must be available, otherwise
 // an error will be returned. The trie root specified by trie id can be
@@ -98,6 +110,9 @@ func New(id *ID, db database.NodeDatabase) (*Trie, error) {
 		opTracer:       newOpTracer(),
 		prevalueTracer: NewPrevalueTracer(),
 	}
+	if disabler, ok := db.(hashCacheDisabler); ok {
+		trie.noHashCache = disabler.HashCacheDisabled()
+	}
 	if id.Root != (common.Hash{}) && id.Root != types.EmptyRootHash {
 		rootnode, err := trie.resolveAndTrack(id.Root[:], nil)
 		if err != nil {
@@ -772,6 +787,7 @@ func (t *Trie) hashRoot() []byte {
 	}
 	// If the number of changes is below 100, we let one thread handle it
 	h := newHasher(t.unhashed >= 100)
+	h.noCache = t.noHashCache
 	defer func() {
 		returnHasherToPool(h)
 		t.unhashed = 0
@@ -776,6 +776,29 @@ func TestCommitAfterHash(t *testing.T) {
 	}
 }
 
+func TestHashWithoutCache(t *testing.T) {
+	addresses, accounts := makeAccounts(1000)
+	trie := NewEmpty(newTestDatabase(rawdb.NewMemoryDatabase(), rawdb.HashScheme))
+	for i := 0; i < len(addresses); i++ {
+		trie.MustUpdate(crypto.Keccak256(addresses[i][:]), accounts[i])
+	}
+	exp := trie.Hash()
+
+	// Poison the cached hash of a child of the root, which is only rehashed
+	// if the cache is bypassed
+	child := trie.root.(*fullNode).Children[0].(*fullNode)
+	child.flags.hash = make(hashNode, 32)
+	trie.root.(*fullNode).flags = trie.newFlag()
+	if root := trie.Hash(); root == exp {
+		t.Fatalf("cached hash not used: got %x", root)
+	}
+	trie.root.(*fullNode).flags = trie.newFlag()
+	trie.noHashCache = true
+	if root := trie.Hash(); root != exp {
+		t.Errorf("got %x, exp %x", root, exp)
+	}
+}
+
 func makeAccounts(size int) (addresses [][20]byte, accounts [][]byte) {
 	// Make the random benchmark deterministic
 	random := rand.New(rand.NewSource(0))
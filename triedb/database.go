@@ -31,10 +31,14 @@ import (
 
 // Config defines all necessary options for database.
 type Config struct {
-	Preimages bool           // Flag whether the preimage of node key is recorded
-	IsVerkle  bool           // Flag whether the db is holding a verkle tree
-	HashDB    *hashdb.Config // Configs for hash-based scheme
-	PathDB    *pathdb.Config // Configs for experimental path-based scheme
+	Preimages         bool           // Flag whether the preimage of node key is recorded
+	PreimageCacheSize int            // Memory allowance (in bytes) for caching preimages, 0 for the default, negative to flush on every commit
+	SecKeyCacheLimit  int            // Maximum number of preimages a state trie caches before handing them to the store, 0 for unbounded
+	RawKeys           bool           // Insert the state trie keys unhashed, the legacy non-secure layout (research only)
+	IsVerkle          bool           // Flag whether the db is holding a verkle tree
+	NoHashCache       bool           // Rehash every resolved node on each trie hashing, bypassing the cached node hashes
+	HashDB            *hashdb.Config // Configs for hash-based scheme
+	PathDB            *pathdb.Config // Configs for experimental path-based scheme
 }
 
 // HashDefaults represents a config for using hash-based scheme with
@@ -98,7 +102,7 @@ func NewDatabase(diskdb ethdb.Database, config *Config) *Database {
 	}
 	var preimages *preimageStore
 	if config.Preimages {
-		preimages = newPreimageStore(diskdb)
+		preimages = newPreimageStore(diskdb, config.PreimageCacheSize)
 	}
 	db := &Database{
 		disk:      diskdb,
@@ -227,6 +231,34 @@ func (db *Database) PreimageEnabled() bool {
 	return db.preimages != nil
 }
 
+// SecKeyCacheLimit returns the maximum number of preimages a state trie caches
+// before handing them over to the preimage store, 0 if unbounded.
+func (db *Database) SecKeyCacheLimit() int {
+	return db.config.SecKeyCacheLimit
+}
+
+// RawKeys returns whether the state tries opened on top of the database insert
+// their keys unhashed instead of keccak256 of them.
+func (db *Database) RawKeys() bool {
+	return db.config.RawKeys
+}
+
+// HashCacheDisabled returns whether the tries opened on top of the database
+// rehash every node held in memory on each hashing, ignoring the cached hashes
+// of the unchanged ones.
+func (db *Database) HashCacheDisabled() bool {
+	return db.config.NoHashCache
+}
+
+// PreimageStats returns the activity of the preimage store, or nil if the
+// preimages are not recorded.
+func (db *Database) PreimageStats() *PreimageStats {
+	if db.preimages == nil {
+		return nil
+	}
+	return db.preimages.stats()
+}
+
 // Cap iteratively flushes old but still referenced trie nodes until the total
 // memory usage goes below the given threshold. The held pre-images accumulated
 // up to this point will be flushed in case the size exceeds the threshold.
@@ -243,6 +275,23 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	return hdb.Cap(limit)
 }
 
+// CapLayers flattens the diff layers below the given root into the disk layer
+// until only the requested number of layers remain in memory. The disk layer
+// is only persisted once its write buffer fills up, capping the disk layer
+// itself is a no-op.
+//
+// It's only supported by path-based database and will return an error for others.
+func (db *Database) CapLayers(root common.Hash, layers int) error {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return errors.New("not supported")
+	}
+	if db.preimages != nil {
+		db.preimages.commit(false)
+	}
+	return pdb.Cap(root, layers)
+}
+
 // Reference adds a new reference from a parent node to a child node. This function
 // is used to add reference between internal trie node and external node(e.g. storage
 // trie root), all internal trie nodes are referenced together by database itself.
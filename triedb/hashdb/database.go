@@ -63,6 +63,7 @@ var (
 // Config contains the settings for database.
 type Config struct {
 	CleanCacheSize int // Maximum memory allowance (in bytes) for caching clean nodes
+	BatchSize      int // Size (in bytes) of the batches the flushed nodes are written in, ethdb.IdealBatchSize if zero
 }
 
 // Defaults is the default setting for database if it's not specified.
@@ -95,6 +96,8 @@ type Database struct {
 	dirtiesSize  common.StorageSize // Storage size of the dirty node cache (exc. metadata)
 	childrenSize common.StorageSize // Storage size of the external children tracking
 
+	batchSize int // Size of the batches the nodes are flushed in
+
 	lock sync.RWMutex
 }
 
@@ -132,10 +135,15 @@ func New(diskdb ethdb.Database, config *Config) *Database {
 	if config.CleanCacheSize > 0 {
 		cleans = fastcache.New(config.CleanCacheSize)
 	}
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = ethdb.IdealBatchSize
+	}
 	return &Database{
-		diskdb:  diskdb,
-		cleans:  cleans,
-		dirties: make(map[common.Hash]*cachedNode),
+		diskdb:    diskdb,
+		cleans:    cleans,
+		dirties:   make(map[common.Hash]*cachedNode),
+		batchSize: batchSize,
 	}
 }
 
@@ -345,7 +353,7 @@ func (db *Database) Cap(limit common.StorageSize) error {
 		rawdb.WriteLegacyTrieNode(batch, oldest, node.node)
 
 		// If we exceeded the ideal batch size, commit and reset
-		if batch.ValueSize() >= ethdb.IdealBatchSize {
+		if batch.ValueSize() >= db.batchSize {
 			if err := batch.Write(); err != nil {
 				log.Error("Failed to write flush list to disk", "err", err)
 				return err
@@ -466,7 +474,7 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 	}
 	// If we've reached an optimal batch size, commit and start over
 	rawdb.WriteLegacyTrieNode(batch, hash, node.node)
-	if batch.ValueSize() >= ethdb.IdealBatchSize {
+	if batch.ValueSize() >= db.batchSize {
 		if err := batch.Write(); err != nil {
 			return err
 		}
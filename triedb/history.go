@@ -70,3 +70,39 @@ func (db *Database) HistoryRange() (uint64, uint64, error) {
 	}
 	return pdb.HistoryRange()
 }
+
+// HistoryCount returns the number of state histories retained in the local
+// store.
+//
+// This function is only supported by path mode database.
+func (db *Database) HistoryCount() (uint64, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return 0, errors.New("not supported")
+	}
+	return pdb.HistoryCount()
+}
+
+// HistorySize returns the total size of the state histories retained in the
+// local store.
+//
+// This function is only supported by path mode database.
+func (db *Database) HistorySize() (uint64, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return 0, errors.New("not supported")
+	}
+	return pdb.HistorySize()
+}
+
+// HistoryTableSizes returns the size of the state histories retained in every
+// freezer table of the local store, keyed by the table name.
+//
+// This function is only supported by path mode database.
+func (db *Database) HistoryTableSizes() (map[string]uint64, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return nil, errors.New("not supported")
+	}
+	return pdb.HistoryTableSizes()
+}
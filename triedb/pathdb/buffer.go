@@ -183,6 +183,7 @@ func (b *buffer) flush(root common.Hash, db ethdb.KeyValueStore, freezer ethdb.A
 			return
 		}
 		commitBytesMeter.Mark(int64(size))
+		commitFlushMeter.Mark(1)
 		commitNodesMeter.Mark(int64(nodes))
 		commitAccountsMeter.Mark(int64(accounts))
 		commitStoragesMeter.Mark(int64(slots))
@@ -360,6 +360,28 @@ func (db *Database) Commit(root common.Hash, report bool) error {
 	return db.tree.cap(root, 0)
 }
 
+// Cap traverses downwards the layer tree from a specified layer, flattening
+// all the diff layers beyond the given number into the disk layer. With a
+// non-zero layer count, the diff layers are flattened into the disk layer's
+// buffer, which is only flushed into the persistent state once it's full. A
+// zero layer count force-flushes the buffer like Commit. Capping the disk
+// layer itself, e.g. a state left untouched since the database was opened,
+// is a no-op.
+func (db *Database) Cap(root common.Hash, layers int) error {
+	// Hold the lock to prevent concurrent mutations.
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	// Short circuit if the mutation is not allowed.
+	if err := db.modifyAllowed(); err != nil {
+		return err
+	}
+	if layers > 0 && db.tree.bottom().rootHash() == root {
+		return nil
+	}
+	return db.tree.cap(root, layers)
+}
+
 // Disable deactivates the database and invalidates all available state layers
 // as stale to prevent access to the persistent state, which is in the syncing
 // stage.
@@ -643,6 +665,42 @@ func (db *Database) HistoryRange() (uint64, uint64, error) {
 	return historyRange(db.stateFreezer)
 }
 
+// HistoryCount returns the number of state histories retained in the local
+// store. Zero is returned if the state history freezer is not available.
+func (db *Database) HistoryCount() (uint64, error) {
+	if db.stateFreezer == nil {
+		return 0, nil
+	}
+	tail, err := db.stateFreezer.Tail()
+	if err != nil {
+		return 0, err
+	}
+	head, err := db.stateFreezer.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	return head - tail, nil
+}
+
+// HistorySize returns the total size of the state histories retained in the
+// local store. Zero is returned if the state history freezer is not available.
+func (db *Database) HistorySize() (uint64, error) {
+	if db.stateFreezer == nil {
+		return 0, nil
+	}
+	return rawdb.ReadStateHistorySize(db.stateFreezer)
+}
+
+// HistoryTableSizes returns the size of the state histories retained in every
+// table of the local store, keyed by the table name. Nil is returned if the
+// state history freezer is not available.
+func (db *Database) HistoryTableSizes() (map[string]uint64, error) {
+	if db.stateFreezer == nil {
+		return nil, nil
+	}
+	return rawdb.ReadStateHistoryTableSizes(db.stateFreezer)
+}
+
 // IndexProgress returns the indexing progress made so far. It provides the
 // number of states that remain unindexed.
 func (db *Database) IndexProgress() (uint64, error) {
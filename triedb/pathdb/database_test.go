@@ -777,6 +777,28 @@ func TestCommit(t *testing.T) {
 	}
 }
 
+func TestHistoryCount(t *testing.T) {
+	// Redefine the diff layer depth allowance for faster testing.
+	maxDiffLayers = 4
+	defer func() {
+		maxDiffLayers = 128
+	}()
+
+	tester := newTester(t, &testerConfig{layers: 12, stateHistory: 5})
+	defer tester.release()
+
+	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
+		t.Fatalf("Failed to commit database, err: %v", err)
+	}
+	count, err := tester.db.HistoryCount()
+	if err != nil {
+		t.Fatalf("Failed to retrieve history count, err: %v", err)
+	}
+	if count != 5 {
+		t.Fatalf("Unexpected history count, want: 5, got: %d", count)
+	}
+}
+
 func TestJournal(t *testing.T) {
 	testJournal(t, "")
 	testJournal(t, filepath.Join(t.TempDir(), strconv.Itoa(rand.Intn(10000))))
@@ -1021,3 +1043,20 @@ func TestDatabaseIndexRecovery(t *testing.T) {
 		}
 	}
 }
+
+func TestCapDiskLayer(t *testing.T) {
+	tester := newTester(t, &testerConfig{layers: 4})
+	defer tester.release()
+
+	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
+		t.Fatalf("Failed to commit database, err: %v", err)
+	}
+	// Capping the disk layer, e.g. after a batch leaving the state untouched,
+	// should keep the layer tree as is
+	if err := tester.db.Cap(tester.lastHash(), 2); err != nil {
+		t.Fatalf("Failed to cap disk layer, err: %v", err)
+	}
+	if tester.db.tree.len() != 1 || tester.db.tree.bottom().rootHash() != tester.lastHash() {
+		t.Fatal("Layer tree structure is invalid")
+	}
+}
@@ -173,7 +173,12 @@ func (dl *diffLayer) persist(force bool) (*diskLayer, error) {
 		dl.parent = result
 		dl.lock.Unlock()
 	}
-	return diffToDisk(dl, force)
+	disk, err := diffToDisk(dl, force)
+	if err != nil {
+		return nil, err
+	}
+	diffLayerMergeMeter.Mark(1)
+	return disk, nil
 }
 
 // size returns the approximate memory size occupied by this diff layer.
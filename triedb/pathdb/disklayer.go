@@ -145,6 +145,8 @@ func (dl *diskLayer) node(owner common.Hash, path []byte, depth int) ([]byte, co
 		cleanNodeMissMeter.Mark(1)
 	}
 	// Try to retrieve the trie node from the disk.
+	diskNodeLoadMeter.Mark(1)
+
 	var blob []byte
 	if owner == (common.Hash{}) {
 		blob = rawdb.ReadAccountTrieNode(dl.db.diskdb, path)
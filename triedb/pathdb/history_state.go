@@ -608,6 +608,8 @@ func writeStateHistory(writer ethdb.AncientWriter, dl *diffLayer) error {
 	stateHistoryDataBytesMeter.Mark(int64(dataSize))
 	stateHistoryIndexBytesMeter.Mark(int64(indexSize))
 	stateHistoryBuildTimeMeter.UpdateSince(start)
+	stateHistoryWriteMeter.Mark(1)
+	stateHistoryWriteCounter.Inc(int64(time.Since(start)))
 	log.Debug("Stored state history", "id", dl.stateID(), "block", dl.block, "data", dataSize, "index", indexSize, "elapsed", common.PrettyDuration(time.Since(start)))
 
 	return nil
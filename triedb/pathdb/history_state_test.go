@@ -222,6 +222,43 @@ func TestTruncateTailStateHistories(t *testing.T) {
 	}
 }
 
+func TestStateHistorySize(t *testing.T) {
+	var (
+		hs         = makeStateHistories(10)
+		freezer, _ = rawdb.NewStateFreezer(t.TempDir(), false, false)
+	)
+	defer freezer.Close()
+
+	for i := 0; i < len(hs); i++ {
+		accountData, storageData, accountIndex, storageIndex := hs[i].encode()
+		rawdb.WriteStateHistory(freezer, uint64(i+1), hs[i].meta.encode(), accountIndex, storageIndex, accountData, storageData)
+	}
+	full, err := rawdb.ReadStateHistorySize(freezer)
+	if err != nil {
+		t.Fatalf("Failed to read history size: %v", err)
+	}
+	sizes, err := rawdb.ReadStateHistoryTableSizes(freezer)
+	if err != nil {
+		t.Fatalf("Failed to read history table sizes: %v", err)
+	}
+	var total uint64
+	for _, size := range sizes {
+		total += size
+	}
+	if len(sizes) != 5 || total != full {
+		t.Fatalf("Unexpected history table sizes %v, want 5 tables summing up to %d", sizes, full)
+	}
+	truncateFromTail(freezer, typeStateHistory, uint64(len(hs)/2))
+
+	pruned, err := rawdb.ReadStateHistorySize(freezer)
+	if err != nil {
+		t.Fatalf("Failed to read history size: %v", err)
+	}
+	if pruned == 0 || pruned >= full {
+		t.Fatalf("Unexpected history size after pruning, full: %d, pruned: %d", full, pruned)
+	}
+}
+
 func TestTruncateOutOfRange(t *testing.T) {
 	var (
 		hs         = makeStateHistories(10)
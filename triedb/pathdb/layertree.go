@@ -168,6 +168,7 @@ func (tree *layerTree) add(root common.Hash, parentRoot common.Hash, block uint6
 
 	// Link the given layer into the state mutation history
 	tree.lookup.addLayer(l)
+	diffLayerCreateMeter.Mark(1)
 	return nil
 }
 
@@ -16,7 +16,11 @@
 
 package pathdb
 
-import "github.com/ethereum/go-ethereum/metrics"
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
 
 var (
 	cleanNodeHitMeter   = metrics.NewRegisteredMeter("pathdb/clean/node/hit", nil)
@@ -35,6 +39,8 @@ var (
 	dirtyNodeWriteMeter   = metrics.NewRegisteredMeter("pathdb/dirty/node/write", nil)
 	dirtyNodeHitDepthHist = metrics.NewRegisteredHistogram("pathdb/dirty/node/depth", nil, metrics.NewExpDecaySample(1028, 0.015))
 
+	diskNodeLoadMeter = metrics.NewRegisteredMeter("pathdb/disk/node/load", nil)
+
 	stateAccountInexMeter     = metrics.NewRegisteredMeter("pathdb/state/account/inex/total", nil)
 	stateStorageInexMeter     = metrics.NewRegisteredMeter("pathdb/state/storage/inex/total", nil)
 	stateAccountInexDiskMeter = metrics.NewRegisteredMeter("pathdb/state/account/inex/disk", nil)
@@ -61,6 +67,10 @@ var (
 	commitAccountsMeter = metrics.NewRegisteredMeter("pathdb/commit/accounts", nil)
 	commitStoragesMeter = metrics.NewRegisteredMeter("pathdb/commit/slots", nil)
 	commitBytesMeter    = metrics.NewRegisteredMeter("pathdb/commit/bytes", nil)
+	commitFlushMeter    = metrics.NewRegisteredMeter("pathdb/commit/flushes", nil)
+
+	diffLayerCreateMeter = metrics.NewRegisteredMeter("pathdb/layer/created", nil)
+	diffLayerMergeMeter  = metrics.NewRegisteredMeter("pathdb/layer/merged", nil)
 
 	gcTrieNodeMeter      = metrics.NewRegisteredMeter("pathdb/gc/node/count", nil)
 	gcTrieNodeBytesMeter = metrics.NewRegisteredMeter("pathdb/gc/node/bytes", nil)
@@ -72,6 +82,8 @@ var (
 	stateHistoryBuildTimeMeter  = metrics.NewRegisteredResettingTimer("pathdb/history/state/time", nil)
 	stateHistoryDataBytesMeter  = metrics.NewRegisteredMeter("pathdb/history/state/bytes/data", nil)
 	stateHistoryIndexBytesMeter = metrics.NewRegisteredMeter("pathdb/history/state/bytes/index", nil)
+	stateHistoryWriteMeter      = metrics.NewRegisteredMeter("pathdb/history/state/written", nil)
+	stateHistoryWriteCounter    = metrics.NewRegisteredCounter("pathdb/history/state/duration", nil)
 
 	//nolint:unused
 	trienodeHistoryBuildTimeMeter = metrics.NewRegisteredResettingTimer("pathdb/history/trienode/time", nil)
@@ -116,3 +128,63 @@ var (
 	storageWriteCounter    = metrics.NewRegisteredCounter("pathdb/generation/duration/storage/write", nil)
 	storageCleanCounter    = metrics.NewRegisteredCounter("state/snapshot/generation/duration/storage/clean", nil)
 )
+
+// NodeStats is a snapshot of the trie node counters maintained by the path
+// database. The counters are process wide and cumulative, callers interested
+// in a specific operation should diff two snapshots.
+type NodeStats struct {
+	DirtyHits    int64 // Nodes served by the diff layers and the write buffer
+	CleanHits    int64 // Nodes served by the clean cache
+	DiskReads    int64 // Nodes loaded from the key-value store
+	NodesFlushed int64 // Nodes flushed into the key-value store
+	BytesFlushed int64 // Bytes flushed into the key-value store, states included
+	Flushes      int64 // Number of write buffer flushes into the key-value store
+}
+
+// LayerStats is a snapshot of the diff layer counters maintained by the path
+// database, process wide and cumulative like NodeStats.
+type LayerStats struct {
+	Created int64 // Diff layers added on top of the layer tree
+	Merged  int64 // Diff layers flattened into the disk layer
+}
+
+// ReadLayerStats returns the current values of the diff layer counters.
+func ReadLayerStats() LayerStats {
+	return LayerStats{
+		Created: diffLayerCreateMeter.Snapshot().Count(),
+		Merged:  diffLayerMergeMeter.Snapshot().Count(),
+	}
+}
+
+// HistoryWriteStats is a snapshot of the state history write counters maintained
+// by the path database, process wide and cumulative like NodeStats.
+type HistoryWriteStats struct {
+	Written    int64         // State histories appended into the freezer
+	DataBytes  int64         // Account and storage data appended
+	IndexBytes int64         // Account and storage indexes appended
+	Elapsed    time.Duration // Time spent encoding and appending the histories
+}
+
+// ReadHistoryWriteStats returns the current values of the state history write
+// counters.
+func ReadHistoryWriteStats() HistoryWriteStats {
+	return HistoryWriteStats{
+		Written:    stateHistoryWriteMeter.Snapshot().Count(),
+		DataBytes:  stateHistoryDataBytesMeter.Snapshot().Count(),
+		IndexBytes: stateHistoryIndexBytesMeter.Snapshot().Count(),
+		Elapsed:    time.Duration(stateHistoryWriteCounter.Snapshot().Count()),
+	}
+}
+
+// ReadNodeStats returns the current values of the trie node counters. They are
+// maintained regardless of whether the metrics system is enabled.
+func ReadNodeStats() NodeStats {
+	return NodeStats{
+		DirtyHits:    dirtyNodeHitMeter.Snapshot().Count(),
+		CleanHits:    cleanNodeHitMeter.Snapshot().Count(),
+		DiskReads:    diskNodeLoadMeter.Snapshot().Count(),
+		NodesFlushed: commitNodesMeter.Snapshot().Count(),
+		BytesFlushed: commitBytesMeter.Snapshot().Count(),
+		Flushes:      commitFlushMeter.Snapshot().Count(),
+	}
+}
@@ -18,24 +18,56 @@ package triedb
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
 )
 
+// defaultPreimageCacheSize is the memory allowance of the preimages held before
+// flushing them into the disk, unless configured otherwise.
+const defaultPreimageCacheSize = 4 * 1024 * 1024
+
 // preimageStore is the store for caching preimages of node key.
 type preimageStore struct {
 	lock          sync.RWMutex
 	disk          ethdb.KeyValueStore
+	limit         common.StorageSize     // Size of the preimages cache triggering a flush
 	preimages     map[common.Hash][]byte // Preimages of nodes from the secure trie
 	preimagesSize common.StorageSize     // Storage size of the preimages cache
+	peakSize      common.StorageSize     // Largest storage size the preimages cache reached
+
+	hits    atomic.Int64 // Lookups served by the preimages cache
+	misses  atomic.Int64 // Lookups passed to the disk
+	flushes int          // Flushes of the preimages cache into the disk
+	flushed int          // Preimages flushed into the disk
+}
+
+// PreimageStats contains the activity of the preimage store of a database.
+type PreimageStats struct {
+	Size    common.StorageSize // Storage size of the preimages currently cached
+	Peak    common.StorageSize // Largest storage size the cache reached
+	Hits    int64              // Lookups served by the cache
+	Misses  int64              // Lookups passed to the disk
+	Flushes int                // Flushes of the cache into the disk
+	Flushed int                // Preimages flushed into the disk
 }
 
-// newPreimageStore initializes the store for caching preimages.
-func newPreimageStore(disk ethdb.KeyValueStore) *preimageStore {
+// newPreimageStore initializes the store for caching preimages, holding up to
+// size bytes of them before flushing. The default allowance is used if size is
+// 0, a negative size flushes them on every commit.
+func newPreimageStore(disk ethdb.KeyValueStore, size int) *preimageStore {
+	limit := common.StorageSize(size)
+	switch {
+	case size == 0:
+		limit = defaultPreimageCacheSize
+	case size < 0:
+		limit = 0
+	}
 	return &preimageStore{
 		disk:      disk,
+		limit:     limit,
 		preimages: make(map[common.Hash][]byte),
 	}
 }
@@ -54,6 +86,7 @@ func (store *preimageStore) insertPreimage(preimages map[common.Hash][]byte) {
 		store.preimages[hash] = preimage
 		store.preimagesSize += common.StorageSize(common.HashLength + len(preimage))
 	}
+	store.peakSize = max(store.peakSize, store.preimagesSize)
 }
 
 // preimage retrieves a cached trie node pre-image from memory. If it cannot be
@@ -64,8 +97,10 @@ func (store *preimageStore) preimage(hash common.Hash) []byte {
 	store.lock.RUnlock()
 
 	if preimage != nil {
+		store.hits.Add(1)
 		return preimage
 	}
+	store.misses.Add(1)
 	return rawdb.ReadPreimage(store.disk, hash)
 }
 
@@ -74,7 +109,10 @@ func (store *preimageStore) commit(force bool) error {
 	store.lock.Lock()
 	defer store.lock.Unlock()
 
-	if store.preimagesSize <= 4*1024*1024 && !force {
+	if store.preimagesSize <= store.limit && !force {
+		return nil
+	}
+	if len(store.preimages) == 0 {
 		return nil
 	}
 	batch := store.disk.NewBatch()
@@ -82,6 +120,8 @@ func (store *preimageStore) commit(force bool) error {
 	if err := batch.Write(); err != nil {
 		return err
 	}
+	store.flushes++
+	store.flushed += len(store.preimages)
 	store.preimages, store.preimagesSize = make(map[common.Hash][]byte), 0
 	return nil
 }
@@ -93,3 +133,18 @@ func (store *preimageStore) size() common.StorageSize {
 
 	return store.preimagesSize
 }
+
+// stats returns the activity of the store.
+func (store *preimageStore) stats() *PreimageStats {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return &PreimageStats{
+		Size:    store.preimagesSize,
+		Peak:    store.peakSize,
+		Hits:    store.hits.Load(),
+		Misses:  store.misses.Load(),
+		Flushes: store.flushes,
+		Flushed: store.flushed,
+	}
+}